@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -36,6 +37,10 @@ type Config struct {
 	JWTSecret                       string
 	JWTAccessTokenExpirationMinutes time.Duration
 	JWTRefreshTokenExpirationDays   time.Duration
+	JWTSigningAlgorithm             string // RS256, EdDSA or HS256 (legacy shared secret)
+	JWTKeysDir                      string
+	JWTKeyRotationInterval          time.Duration
+	JWTAcceptLegacyHS256            bool // Keep accepting HS256 tokens while migrating to asymmetric keys
 
 	SMTPHost        string
 	SMTPPort        int
@@ -44,9 +49,34 @@ type Config struct {
 	SMTPSenderEmail string
 
 	OTPExpirationMinutes time.Duration
-	UploadsDir           string
-	MaxUploadSizeMB      int64
-	InternalAPIKey       string
+	TOTPIssuer           string
+	TOTPEnrollmentExpiry time.Duration
+	PasswordResetExpiry  time.Duration
+	FrontendBaseURL      string
+
+	EmailVerificationExpiry         time.Duration
+	EmailVerificationResendCooldown time.Duration
+	OrganizationInvitationExpiry    time.Duration
+	StaffInvitationExpiry           time.Duration
+
+	LoginFailureWindow      time.Duration
+	LoginMaxAccountFailures int
+	LoginMaxIPFailures      int
+	LoginLockoutDuration    time.Duration
+
+	Staff2FARequiredRoles []string // Staff roles that may not use the admin API without a verified second factor
+	ImpersonationExpiry   time.Duration
+
+	FinancingQuoteValidity time.Duration // How long a financing quote given to a customer stays valid
+
+	AuthCookieMode     bool // Deliver tokens in HttpOnly cookies instead of the response body
+	AuthCookieDomain   string
+	AuthCookieSecure   bool
+	AuthCookieSameSite string
+
+	UploadsDir      string
+	MaxUploadSizeMB int64
+	InternalAPIKey  string
 }
 
 // LoadConfig loads configuration from .env file or environment variables
@@ -76,7 +106,23 @@ func LoadConfig(path string) (*Config, error) {
 	smtpPort, _ := strconv.Atoi(getEnv("SMTP_PORT", "465"))
 	accessTokenExpMinutes, _ := strconv.Atoi(getEnv("JWT_ACCESS_TOKEN_EXPIRATION_MINUTES", "15"))
 	refreshTokenExpDays, _ := strconv.Atoi(getEnv("JWT_REFRESH_TOKEN_EXPIRATION_DAYS", "7"))
+	jwtKeyRotationDays, _ := strconv.Atoi(getEnv("JWT_KEY_ROTATION_DAYS", "30"))
+	jwtAcceptLegacyHS256, _ := strconv.ParseBool(getEnv("JWT_ACCEPT_LEGACY_HS256", "false"))
 	otpExpMinutes, _ := strconv.Atoi(getEnv("OTP_EXPIRATION_MINUTES", "5"))
+	totpEnrollmentExpMinutes, _ := strconv.Atoi(getEnv("TOTP_ENROLLMENT_EXPIRATION_MINUTES", "10"))
+	passwordResetExpMinutes, _ := strconv.Atoi(getEnv("PASSWORD_RESET_EXPIRATION_MINUTES", "30"))
+	emailVerificationExpHours, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_EXPIRATION_HOURS", "24"))
+	emailVerificationCooldownSeconds, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECONDS", "60"))
+	orgInvitationExpHours, _ := strconv.Atoi(getEnv("ORG_INVITATION_EXPIRATION_HOURS", "72"))
+	staffInvitationExpHours, _ := strconv.Atoi(getEnv("STAFF_INVITATION_EXPIRATION_HOURS", "48"))
+	impersonationExpMinutes, _ := strconv.Atoi(getEnv("IMPERSONATION_EXPIRATION_MINUTES", "15"))
+	financingQuoteValidityHours, _ := strconv.Atoi(getEnv("FINANCING_QUOTE_VALIDITY_HOURS", "24"))
+	loginFailureWindowMinutes, _ := strconv.Atoi(getEnv("LOGIN_FAILURE_WINDOW_MINUTES", "15"))
+	loginMaxAccountFailures, _ := strconv.Atoi(getEnv("LOGIN_MAX_FAILURES_PER_ACCOUNT", "5"))
+	loginMaxIPFailures, _ := strconv.Atoi(getEnv("LOGIN_MAX_FAILURES_PER_IP", "20"))
+	loginLockoutMinutes, _ := strconv.Atoi(getEnv("LOGIN_LOCKOUT_MINUTES", "15"))
+	authCookieMode, _ := strconv.ParseBool(getEnv("AUTH_COOKIE_MODE", "false"))
+	authCookieSecure, _ := strconv.ParseBool(getEnv("AUTH_COOKIE_SECURE", "true"))
 	maxUploadSizeMB, _ := strconv.ParseInt(getEnv("MAX_UPLOAD_SIZE_MB", "10"), 10, 64)
 
 	// Construct RedisAddr from REDIS_HOST and REDIS_PORT
@@ -107,6 +153,10 @@ func LoadConfig(path string) (*Config, error) {
 		JWTSecret:                       getEnv("JWT_SECRET", "supersecretkey"),
 		JWTAccessTokenExpirationMinutes: time.Duration(accessTokenExpMinutes) * time.Minute,
 		JWTRefreshTokenExpirationDays:   time.Duration(refreshTokenExpDays) * 24 * time.Hour,
+		JWTSigningAlgorithm:             getEnv("JWT_SIGNING_ALG", "RS256"),
+		JWTKeysDir:                      getEnv("JWT_KEYS_DIR", "./keys"),
+		JWTKeyRotationInterval:          time.Duration(jwtKeyRotationDays) * 24 * time.Hour,
+		JWTAcceptLegacyHS256:            jwtAcceptLegacyHS256,
 
 		SMTPHost:        getEnv("SMTP_HOST", "smtp.gmail.com"),
 		SMTPPort:        smtpPort,
@@ -115,9 +165,34 @@ func LoadConfig(path string) (*Config, error) {
 		SMTPSenderEmail: getEnv("SMTPSenderEmail", "Invoice App <no-reply@syentia.io>"),
 
 		OTPExpirationMinutes: time.Duration(otpExpMinutes) * time.Minute,
-		UploadsDir:           getEnv("UPLOADS_DIR", "./uploads"),
-		MaxUploadSizeMB:      maxUploadSizeMB,
-		InternalAPIKey:       getEnv("INTERNAL_API_KEY", "default-internal-key-please-change"),
+		TOTPIssuer:           getEnv("TOTP_ISSUER", "Invoice Financing App"),
+		TOTPEnrollmentExpiry: time.Duration(totpEnrollmentExpMinutes) * time.Minute,
+		PasswordResetExpiry:  time.Duration(passwordResetExpMinutes) * time.Minute,
+		FrontendBaseURL:      getEnv("FRONTEND_BASE_URL", "http://localhost:3001"),
+
+		EmailVerificationExpiry:         time.Duration(emailVerificationExpHours) * time.Hour,
+		EmailVerificationResendCooldown: time.Duration(emailVerificationCooldownSeconds) * time.Second,
+		OrganizationInvitationExpiry:    time.Duration(orgInvitationExpHours) * time.Hour,
+		StaffInvitationExpiry:           time.Duration(staffInvitationExpHours) * time.Hour,
+
+		LoginFailureWindow:      time.Duration(loginFailureWindowMinutes) * time.Minute,
+		LoginMaxAccountFailures: loginMaxAccountFailures,
+		LoginMaxIPFailures:      loginMaxIPFailures,
+		LoginLockoutDuration:    time.Duration(loginLockoutMinutes) * time.Minute,
+
+		Staff2FARequiredRoles: getEnvList("STAFF_2FA_REQUIRED_ROLES", "admin,kyc_reviewer,finance_manager"),
+		ImpersonationExpiry:   time.Duration(impersonationExpMinutes) * time.Minute,
+
+		FinancingQuoteValidity: time.Duration(financingQuoteValidityHours) * time.Hour,
+
+		AuthCookieMode:     authCookieMode,
+		AuthCookieDomain:   getEnv("AUTH_COOKIE_DOMAIN", ""),
+		AuthCookieSecure:   authCookieSecure,
+		AuthCookieSameSite: getEnv("AUTH_COOKIE_SAMESITE", "Strict"),
+
+		UploadsDir:      getEnv("UPLOADS_DIR", "./uploads"),
+		MaxUploadSizeMB: maxUploadSizeMB,
+		InternalAPIKey:  getEnv("INTERNAL_API_KEY", "default-internal-key-please-change"),
 	}
 
 	cfg.DSN = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=UTC",
@@ -134,7 +209,8 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	cfg.RedisDB = redisDB
 
-	if cfg.JWTSecret == "supersecretkey" || cfg.JWTSecret == "your_very_secret_key_for_jwt_change_this_please" || cfg.JWTSecret == "your_very_secret_key_for_jwt_change_this" {
+	usesJWTSecret := cfg.JWTSigningAlgorithm == "HS256" || cfg.JWTAcceptLegacyHS256
+	if usesJWTSecret && (cfg.JWTSecret == "supersecretkey" || cfg.JWTSecret == "your_very_secret_key_for_jwt_change_this_please" || cfg.JWTSecret == "your_very_secret_key_for_jwt_change_this") {
 		fmt.Println("WARNING: JWT_SECRET is set to a default/example value. Please change this for production!")
 	}
 	if cfg.InternalAPIKey == "default-internal-key-please-change" {
@@ -150,3 +226,15 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList reads a comma-separated list, dropping blank entries. An empty value yields an
+// empty list rather than the default.
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -2,6 +2,7 @@ package dtos
 
 import (
 	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
 	"time"
 )
 
@@ -26,6 +27,9 @@ type AdminKYCDetailResponse struct {
 }
 
 // Staff DTOs for Admin
+
+// CreateStaffRequest is only used to bootstrap the superadmin from the environment; other staff
+// are onboarded through invitations.
 type CreateStaffRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	FirstName string `json:"firstName" validate:"required,min=2"`
@@ -54,6 +58,34 @@ type StaffResponse struct {
 	UpdatedAt   time.Time  `json:"updatedAt"`
 }
 
+// Staff Invitation DTOs
+type InviteStaffRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=admin kyc_reviewer finance_manager"`
+}
+
+type StaffInvitationResponse struct {
+	ID             uint      `json:"id"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	InvitedByEmail string    `json:"invitedByEmail,omitempty"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	Expired        bool      `json:"expired"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type AcceptStaffInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	FirstName string `json:"firstName" validate:"required,min=2"`
+	LastName  string `json:"lastName" validate:"required,min=2"`
+	Password  string `json:"password" validate:"required,min=8"`
+	TOTPCode  string `json:"totpCode" validate:"required,len=6,numeric"` // First code from the authenticator set up for the invitation
+}
+
+type BeginStaffInvitation2FARequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
 // Activity Log DTOs
 type ActivityLogResponse struct {
 	ID         uint      `json:"id"`
@@ -71,3 +103,67 @@ type ActivityLogListResponse struct {
 	Page     int                   `json:"page"`
 	PageSize int                   `json:"pageSize"`
 }
+
+// Login Lockout DTOs
+type LockedAccountResponse struct {
+	Email       string    `json:"email"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+type AdminUnlockAccountRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// Permission DTOs
+type PermissionResponse struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+type RolePermissionsResponse struct {
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"required,dive,required"`
+}
+
+// Approval limit DTOs
+type ApprovalLimitResponse struct {
+	ID        uint         `json:"id"`
+	Role      string       `json:"role"`
+	Currency  string       `json:"currency"`
+	MaxAmount money.Amount `json:"maxAmount"`
+}
+
+type SetApprovalLimitRequest struct {
+	Role      string       `json:"role" validate:"required,oneof=admin kyc_reviewer finance_manager"`
+	Currency  string       `json:"currency" validate:"required,len=3,alpha"`
+	MaxAmount money.Amount `json:"maxAmount" validate:"required,gt=0"`
+}
+
+type InvoiceEscalationResponse struct {
+	ID                     uint         `json:"id"`
+	InvoiceID              uint         `json:"invoiceId"`
+	InvoiceNumber          string       `json:"invoiceNumber,omitempty"`
+	InvoiceStatus          string       `json:"invoiceStatus"`
+	Action                 string       `json:"action"`
+	Amount                 money.Amount `json:"amount"`
+	Currency               string       `json:"currency"`
+	Role                   string       `json:"role"`
+	LimitAmount            money.Amount `json:"limitAmount"`
+	EscalatedByID          uint         `json:"escalatedById"`
+	EscalatedByEmail       string       `json:"escalatedByEmail,omitempty"`
+	DisbursementApprovalID *uint        `json:"disbursementApprovalId,omitempty"`
+	CreatedAt              time.Time    `json:"createdAt"`
+}
+
+// Impersonation DTOs
+type ImpersonationResponse struct {
+	AccessToken          string `json:"accessToken"`
+	AccessTokenExpiresAt int64  `json:"accessTokenExpiresAt"`
+	SessionID            string `json:"sessionId"`
+	UserID               uint   `json:"userId"`
+	ReadOnly             bool   `json:"readOnly"`
+}
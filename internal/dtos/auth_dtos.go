@@ -1,5 +1,7 @@
 package dtos
 
+import "time"
+
 type RegisterUserRequest struct {
 	Email       string `json:"email" validate:"required,email"`
 	FirstName   string `json:"firstName" validate:"required,min=2,max=50"`
@@ -9,13 +11,15 @@ type RegisterUserRequest struct {
 }
 
 type UserResponse struct {
-	ID           uint   `json:"id"`
-	Email        string `json:"email"`
-	FirstName    string `json:"firstName,omitempty"`
-	LastName     string `json:"lastName,omitempty"`
-	CompanyName  string `json:"companyName,omitempty"`
-	IsActive     bool   `json:"isActive"`
-	TwoFAEnabled bool   `json:"twoFAEnabled,omitempty"`
+	ID            uint   `json:"id"`
+	Email         string `json:"email"`
+	FirstName     string `json:"firstName,omitempty"`
+	LastName      string `json:"lastName,omitempty"`
+	CompanyName   string `json:"companyName,omitempty"`
+	IsActive      bool   `json:"isActive"`
+	EmailVerified bool   `json:"emailVerified"`
+	TwoFAEnabled  bool   `json:"twoFAEnabled,omitempty"`
+	TwoFAMethod   string `json:"twoFAMethod,omitempty"`
 }
 
 type RegisterUserResponse struct {
@@ -29,25 +33,31 @@ type LoginUserRequest struct {
 }
 
 type LoginUserResponse struct {
-	User                 *UserResponse `json:"user,omitempty"` // Basic info for the logged-in entity
-	AccessToken          string        `json:"accessToken,omitempty"`
-	RefreshToken         string        `json:"refreshToken,omitempty"`
-	Message              string        `json:"message"`
-	TwoFARequired        bool          `json:"twoFARequired"` // True if user 2FA (not staff 2FA) is triggered
-	AccessTokenExpiresAt int64         `json:"accessTokenExpiresAt,omitempty"`
-	Role                 string        `json:"role"`         // e.g., "user", "admin", "super_admin"
-	RedirectPath         string        `json:"redirectPath"` // Suggested frontend redirect path, e.g., "/home", "/2fa", "/admin"
+	User                    *UserResponse `json:"user,omitempty"` // Basic info for the logged-in entity
+	AccessToken             string        `json:"accessToken,omitempty"`
+	RefreshToken            string        `json:"refreshToken,omitempty"`
+	Message                 string        `json:"message"`
+	TwoFARequired           bool          `json:"twoFARequired"`                     // True if a second factor must be verified before tokens are issued
+	TwoFAMethod             string        `json:"twoFAMethod,omitempty"`             // "email" or "totp" when TwoFARequired is true
+	TwoFAToken              string        `json:"twoFAToken,omitempty"`              // Proves the password step; send it back with the second factor
+	TwoFAEnrollmentRequired bool          `json:"twoFAEnrollmentRequired,omitempty"` // Staff whose role requires 2FA must enroll before signing in
+	EnrollmentToken         string        `json:"enrollmentToken,omitempty"`         // Authorises the enrollment endpoints when TwoFAEnrollmentRequired is true
+	AccessTokenExpiresAt    int64         `json:"accessTokenExpiresAt,omitempty"`
+	Role                    string        `json:"role"`         // e.g., "user", "admin", "super_admin"
+	RedirectPath            string        `json:"redirectPath"` // Suggested frontend redirect path, e.g., "/home", "/2fa", "/admin"
 }
 
 type RefreshTokenRequest struct {
-	RefreshToken string `json:"refreshToken" validate:"required"`
+	RefreshToken string `json:"refreshToken"` // Optional in cookie mode; the refresh_token cookie is used instead
 }
 
 type RefreshTokenResponse struct {
-	AccessToken          string `json:"accessToken"`
-	RefreshToken         string `json:"refreshToken"`
+	AccessToken          string `json:"accessToken,omitempty"`
+	RefreshToken         string `json:"refreshToken,omitempty"`
 	AccessTokenExpiresAt int64  `json:"accessTokenExpiresAt"`
 	Message              string `json:"message"`
+	Role                 string `json:"role"`
+	RedirectPath         string `json:"redirectPath"`
 }
 
 type RequestOTPRequest struct {
@@ -58,17 +68,22 @@ type RequestOTPResponse struct {
 	Message string `json:"message"`
 }
 
+// VerifyOTPRequest carries either a 6-digit OTP (email or authenticator) or a single-use recovery code.
 type VerifyOTPRequest struct {
-	Email string `json:"email" validate:"required,email"`
-	OTP   string `json:"otp" validate:"required,len=6,numeric"`
+	Email        string `json:"email" validate:"required,email"`
+	TwoFAToken   string `json:"twoFAToken" validate:"required,max=128"` // From the login response; valid for one attempt
+	OTP          string `json:"otp" validate:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode" validate:"omitempty,max=32"`
 }
 
 type VerifyOTPResponse struct {
 	User                 UserResponse `json:"user"`
-	AccessToken          string       `json:"accessToken"`
-	RefreshToken         string       `json:"refreshToken"`
+	AccessToken          string       `json:"accessToken,omitempty"`
+	RefreshToken         string       `json:"refreshToken,omitempty"`
 	Message              string       `json:"message"`
 	AccessTokenExpiresAt int64        `json:"accessTokenExpiresAt"`
+	Role                 string       `json:"role,omitempty"`
+	RedirectPath         string       `json:"redirectPath,omitempty"`
 }
 
 type Enable2FARequest struct {
@@ -76,6 +91,96 @@ type Enable2FARequest struct {
 }
 
 type Enable2FAResponse struct {
-	Message      string `json:"message"`
-	TwoFAEnabled bool   `json:"twoFaEnabled"`
+	Message       string   `json:"message"`
+	TwoFAEnabled  bool     `json:"twoFaEnabled"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"` // Only returned once, when 2FA is first enabled
+}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauthUrl"`
+	QRCodePNG  string `json:"qrCodePng"` // Base64-encoded PNG
+	ExpiresIn  int    `json:"expiresIn"` // Seconds until the pending secret must be confirmed
+	Message    string `json:"message"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// StaffTwoFAEnrollmentRequest starts the forced second-factor enrollment of a staff member,
+// authorised by the enrollment token returned from login.
+type StaffTwoFAEnrollmentRequest struct {
+	EnrollmentToken string `json:"enrollmentToken" validate:"required"`
+	Method          string `json:"method" validate:"required,oneof=email totp"`
+}
+
+type StaffTwoFAEnrollmentResponse struct {
+	Method     string `json:"method"`
+	Message    string `json:"message"`
+	Secret     string `json:"secret,omitempty"`     // TOTP only
+	OTPAuthURL string `json:"otpauthUrl,omitempty"` // TOTP only
+	QRCodePNG  string `json:"qrCodePng,omitempty"`  // TOTP only; base64-encoded PNG
+	ExpiresIn  int    `json:"expiresIn"`            // Seconds until the code or pending secret expires
+}
+
+type StaffTwoFAConfirmRequest struct {
+	EnrollmentToken string `json:"enrollmentToken" validate:"required"`
+	Method          string `json:"method" validate:"required,oneof=email totp"`
+	Code            string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type Update2FAMethodRequest struct {
+	Method string `json:"method" validate:"required,oneof=email totp"`
+}
+
+type Update2FAMethodResponse struct {
+	Message       string   `json:"message"`
+	TwoFAEnabled  bool     `json:"twoFaEnabled"`
+	TwoFAMethod   string   `json:"twoFaMethod"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+type RecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=8,max=72"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,max=72"`
+}
+
+// ChangePasswordResponse returns a fresh token pair, since every previously issued token is revoked.
+type ChangePasswordResponse struct {
+	Message              string `json:"message"`
+	AccessToken          string `json:"accessToken,omitempty"`
+	RefreshToken         string `json:"refreshToken,omitempty"`
+	AccessTokenExpiresAt int64  `json:"accessTokenExpiresAt"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
 }
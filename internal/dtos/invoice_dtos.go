@@ -2,8 +2,11 @@ package dtos
 
 import (
 	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
 	"mime/multipart"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type InvoiceUploadRequest struct {
@@ -11,28 +14,42 @@ type InvoiceUploadRequest struct {
 }
 
 type InvoiceResponse struct {
-	ID                      uint                 `json:"id"`
-	UserID                  uint                 `json:"userId"`
-	InvoiceNumber           string               `json:"invoiceNumber,omitempty"` // omitempty if null
-	IssuerName              string               `json:"issuerName,omitempty"`
-	IssuerBankAccount       string               `json:"issuerBankAccount,omitempty"`
-	IssuerBankName          string               `json:"issuerBankName,omitempty"`
-	DebtorName              string               `json:"debtorName,omitempty"`
-	Amount                  float64              `json:"amount,omitempty"`   // omitempty if null
-	Currency                string               `json:"currency,omitempty"` // omitempty if null
-	DueDate                 *time.Time           `json:"dueDate,omitempty"`
-	Status                  models.InvoiceStatus `json:"status"`
-	OriginalFilePath        string               `json:"originalFilePath,omitempty"`
-	JSONData                string               `json:"jsonData,omitempty"`
-	UploadedAt              time.Time            `json:"uploadedAt"`
-	ApprovedAt              *time.Time           `json:"approvedAt,omitempty"`
-	DisbursedAt             *time.Time           `json:"disbursedAt,omitempty"`
-	FinancingFeePercentage  *float64             `json:"financingFeePercentage,omitempty"`
-	FinancedAmount          *float64             `json:"financedAmount,omitempty"`
-	DisbursementReceiptPath string               `json:"disbursementReceiptPath,omitempty"`
-	ProcessingError         *string              `json:"processingError,omitempty"`
-	CreatedAt               time.Time            `json:"createdAt"`
-	UpdatedAt               time.Time            `json:"updatedAt"`
+	ID                      uint                      `json:"id"`
+	UserID                  uint                      `json:"userId"`
+	OrganizationID          *uint                     `json:"organizationId,omitempty"`
+	InvoiceNumber           string                    `json:"invoiceNumber,omitempty"` // omitempty if null
+	IssuerName              string                    `json:"issuerName,omitempty"`
+	IssuerBankAccount       string                    `json:"issuerBankAccount,omitempty"`
+	IssuerBankName          string                    `json:"issuerBankName,omitempty"`
+	DebtorName              string                    `json:"debtorName,omitempty"`
+	Amount                  money.Amount              `json:"amount"`
+	Currency                string                    `json:"currency,omitempty"` // omitempty if null
+	DueDate                 *time.Time                `json:"dueDate,omitempty"`
+	Status                  models.InvoiceStatus      `json:"status"`
+	OriginalFilePath        string                    `json:"originalFilePath,omitempty"`
+	JSONData                string                    `json:"jsonData,omitempty"`
+	UploadedAt              time.Time                 `json:"uploadedAt"`
+	ApprovedAt              *time.Time                `json:"approvedAt,omitempty"`
+	DisbursedAt             *time.Time                `json:"disbursedAt,omitempty"`
+	FinancingFeePercentage  *decimal.Decimal          `json:"financingFeePercentage,omitempty"`
+	FinancedAmount          *money.Amount             `json:"financedAmount,omitempty"`
+	Pricing                 *PricingBreakdownResponse `json:"pricing,omitempty"`
+	DisbursementReceiptPath string                    `json:"disbursementReceiptPath,omitempty"`
+	ProcessingError         *string                   `json:"processingError,omitempty"`
+	CreatedAt               time.Time                 `json:"createdAt"`
+	UpdatedAt               time.Time                 `json:"updatedAt"`
+}
+
+// InvoiceStatusHistoryResponse is one entry in an invoice's status history. FromStatus is
+// empty for the entry written on upload.
+type InvoiceStatusHistoryResponse struct {
+	ID         uint                 `json:"id"`
+	FromStatus models.InvoiceStatus `json:"fromStatus,omitempty"`
+	ToStatus   models.InvoiceStatus `json:"toStatus"`
+	ActorType  string               `json:"actorType"`
+	ActorID    *uint                `json:"actorId,omitempty"`
+	Reason     *string              `json:"reason,omitempty"`
+	CreatedAt  time.Time            `json:"createdAt"`
 }
 
 type InvoiceListResponse struct {
@@ -42,11 +59,40 @@ type InvoiceListResponse struct {
 	PageSize int               `json:"pageSize"`
 }
 
+// AdminInvoiceUpdateRequest changes an invoice's status. Disbursements go through
+// RequestDisbursementRequest instead so that a second staff member has to approve them.
 type AdminInvoiceUpdateRequest struct {
-	Status                 models.InvoiceStatus `json:"status" validate:"required,oneof=approved rejected disbursed repaid"`
-	FinancingFeePercentage *float64             `json:"financingFeePercentage,omitempty" validate:"omitempty,gt=0,lte=100"`
-	FinancedAmount         *float64             `json:"financedAmount,omitempty" validate:"omitempty,gt=0"`
-	RejectionReason        *string              `json:"rejectionReason,omitempty"`
+	Status          models.InvoiceStatus `json:"status" validate:"required,oneof=approved rejected repaid"`
+	RejectionReason *string              `json:"rejectionReason,omitempty"`
+}
+
+// RequestDisbursementRequest asks for an approved invoice to be disbursed on the terms of its
+// pricing plan. Override replaces some of those terms and must give a reason.
+type RequestDisbursementRequest struct {
+	Override *PricingOverride `json:"override,omitempty"`
+}
+
+type RejectDisbursementRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type DisbursementApprovalResponse struct {
+	ID                     uint                              `json:"id"`
+	InvoiceID              uint                              `json:"invoiceId"`
+	InvoiceNumber          string                            `json:"invoiceNumber,omitempty"`
+	InvoiceAmount          money.Amount                      `json:"invoiceAmount"`
+	Currency               string                            `json:"currency,omitempty"`
+	FinancedAmount         money.Amount                      `json:"financedAmount"`
+	FinancingFeePercentage decimal.Decimal                   `json:"financingFeePercentage"`
+	Pricing                *PricingBreakdownResponse         `json:"pricing,omitempty"`
+	Status                 models.DisbursementApprovalStatus `json:"status"`
+	RequestedByID          uint                              `json:"requestedById"`
+	RequestedByEmail       string                            `json:"requestedByEmail,omitempty"`
+	ReviewedByID           *uint                             `json:"reviewedById,omitempty"`
+	ReviewedAt             *time.Time                        `json:"reviewedAt,omitempty"`
+	RejectionReason        *string                           `json:"rejectionReason,omitempty"`
+	TransactionID          *uint                             `json:"transactionId,omitempty"`
+	CreatedAt              time.Time                         `json:"createdAt"`
 }
 
 type AdminUploadReceiptRequest struct {
@@ -57,13 +103,13 @@ type AdminUploadReceiptRequest struct {
 type UpdateInvoiceProcessedDataRequest struct {
 	JSONData                   string                `json:"jsonData" validate:"required,json"`
 	ExtractedInvoiceNumber     *string               `json:"extractedInvoiceNumber,omitempty"`
-	ExtractedAmount            *float64              `json:"extractedAmount,omitempty" validate:"omitempty,gt=0"`
+	ExtractedAmount            *money.Amount         `json:"extractedAmount,omitempty" validate:"omitempty,gt=0"`
 	ExtractedCurrency          *string               `json:"extractedCurrency,omitempty" validate:"omitempty,len=3"`
 	ExtractedDueDate           *string               `json:"extractedDueDate,omitempty" validate:"omitempty,datetime=2006-01-02"` // Expect YYYY-MM-DD
 	ExtractedDebtorName        *string               `json:"extractedDebtorName,omitempty"`
 	ExtractedIssuerName        *string               `json:"extractedIssuerName,omitempty"`
 	ExtractedIssuerBankAccount *string               `json:"extractedIssuerBankAccount,omitempty"`
 	ExtractedIssuerBankName    *string               `json:"extractedIssuerBankName,omitempty"`
-	ProcessingError            *string               `json:"processingError,omitempty"` // For n8n to report errors
-	NewStatus                  *models.InvoiceStatus `json:"newStatus,omitempty"`       // Optional: n8n can suggest a new status
+	ProcessingError            *string               `json:"processingError,omitempty"`                                                                            // For n8n to report errors
+	NewStatus                  *models.InvoiceStatus `json:"newStatus,omitempty" validate:"omitempty,oneof=pending_review pending_admin_review processing_failed"` // Optional: n8n can suggest a processing status; decisions stay with staff
 }
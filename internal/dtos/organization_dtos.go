@@ -0,0 +1,57 @@
+package dtos
+
+import (
+	"time"
+)
+
+type OrganizationResponse struct {
+	ID                uint      `json:"id"`
+	Name              string    `json:"name"`
+	BankAccountName   string    `json:"bankAccountName,omitempty"`
+	BankAccountNumber string    `json:"bankAccountNumber,omitempty"`
+	BankName          string    `json:"bankName,omitempty"`
+	KYCStatus         string    `json:"kycStatus"`
+	Role              string    `json:"role"` // The caller's role in the organisation
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name              string `json:"name" validate:"omitempty,min=2,max=100"`
+	BankAccountName   string `json:"bankAccountName" validate:"omitempty,max=255"`
+	BankAccountNumber string `json:"bankAccountNumber" validate:"omitempty,max=100"`
+	BankName          string `json:"bankName" validate:"omitempty,max=100"`
+}
+
+type OrganizationMemberResponse struct {
+	UserID    uint      `json:"userId"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joinedAt"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner uploader viewer"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner uploader viewer"`
+}
+
+type OrganizationInvitationResponse struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	FirstName string `json:"firstName" validate:"required,min=2,max=50"`
+	LastName  string `json:"lastName" validate:"required,min=2,max=50"`
+	Password  string `json:"password" validate:"required,min=8,max=72"`
+}
@@ -1,12 +1,18 @@
 package dtos
 
+import (
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
+	"time"
+)
+
 type DisbursementRequest struct {
-	InvoiceID         uint    `json:"invoiceId" validate:"required"`
-	Amount            float64 `json:"amount" validate:"required,gt=0"`
-	Currency          string  `json:"currency" validate:"required,len=3"`
-	BankAccountNumber string  `json:"bankAccountNumber" validate:"required"`
-	BankName          string  `json:"bankName" validate:"required"`
-	RecipientName     string  `json:"recipientName" validate:"required"`
+	InvoiceID         uint         `json:"invoiceId" validate:"required"`
+	Amount            money.Amount `json:"amount" validate:"required,gt=0"`
+	Currency          string       `json:"currency" validate:"required,len=3"`
+	BankAccountNumber string       `json:"bankAccountNumber" validate:"required"`
+	BankName          string       `json:"bankName" validate:"required"`
+	RecipientName     string       `json:"recipientName" validate:"required"`
 }
 
 type DisbursementResponse struct {
@@ -16,11 +22,12 @@ type DisbursementResponse struct {
 }
 
 type RepaymentRequest struct {
-	InvoiceID          uint    `json:"invoiceId" validate:"required"`
-	UserID             uint    `json:"userId" validate:"required"`
-	Amount             float64 `json:"amount" validate:"required,gt=0"`
-	Currency           string  `json:"currency" validate:"required,len=3"`
-	PaymentMethodToken string  `json:"paymentMethodToken" validate:"required"`
+	InvoiceID          uint         `json:"invoiceId" validate:"required"`
+	UserID             uint         `json:"userId" validate:"required"`
+	Amount             money.Amount `json:"amount" validate:"required,gt=0"`
+	Currency           string       `json:"currency" validate:"required,len=3"`
+	PaymentMethodToken string       `json:"paymentMethodToken" validate:"required"`
+	IdempotencyKey     string       `json:"idempotencyKey,omitempty"` // Retries with the same key charge only once
 }
 
 type RepaymentResponse struct {
@@ -30,9 +37,51 @@ type RepaymentResponse struct {
 }
 
 type PaymentStatusResponse struct {
-	TransactionID string  `json:"transactionId"`
-	Status        string  `json:"status"`
-	Message       string  `json:"message"`
-	Amount        float64 `json:"amount,omitempty"`
-	Currency      string  `json:"currency,omitempty"`
+	TransactionID string       `json:"transactionId"`
+	Status        string       `json:"status"`
+	Message       string       `json:"message"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency,omitempty"`
+}
+
+// InvoiceRepaymentRequest is a customer paying back (part of) the advance on a disbursed
+// invoice. The amount is in the invoice currency.
+type InvoiceRepaymentRequest struct {
+	Amount             money.Amount `json:"amount" validate:"gt=0"`
+	PaymentMethodToken string       `json:"paymentMethodToken" validate:"required,max=255"`
+}
+
+type InvoiceRepaymentResponse struct {
+	TransactionID      uint                 `json:"transactionId"`
+	ReferenceID        string               `json:"referenceId"` // Payment provider's transaction ID
+	InvoiceID          uint                 `json:"invoiceId"`
+	Amount             money.Amount         `json:"amount"`
+	Currency           string               `json:"currency"`
+	InvoiceStatus      models.InvoiceStatus `json:"invoiceStatus"`
+	OutstandingBalance money.Amount         `json:"outstandingBalance"`
+	PaidAt             time.Time            `json:"paidAt"`
+}
+
+// OutstandingBalanceResponse is what is still owed on a disbursed invoice.
+type OutstandingBalanceResponse struct {
+	InvoiceID         uint                 `json:"invoiceId"`
+	InvoiceNumber     string               `json:"invoiceNumber,omitempty"`
+	OrganizationID    *uint                `json:"organizationId,omitempty"`
+	UserEmail         string               `json:"userEmail,omitempty"`
+	DebtorName        string               `json:"debtorName,omitempty"`
+	Currency          string               `json:"currency"`
+	Status            models.InvoiceStatus `json:"status"`
+	DisbursedAt       *time.Time           `json:"disbursedAt,omitempty"`
+	DueDate           *time.Time           `json:"dueDate,omitempty"`
+	Overdue           bool                 `json:"overdue"`
+	FinancedAmount    money.Amount         `json:"financedAmount"`
+	RepaidAmount      money.Amount         `json:"repaidAmount"`
+	OutstandingAmount money.Amount         `json:"outstandingAmount"`
+}
+
+type OutstandingBalanceListResponse struct {
+	Balances []OutstandingBalanceResponse `json:"balances"`
+	Total    int64                        `json:"total"`
+	Page     int                          `json:"page"`
+	PageSize int                          `json:"pageSize"`
 }
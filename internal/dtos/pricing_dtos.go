@@ -0,0 +1,102 @@
+package dtos
+
+import (
+	"invoiceB2B/internal/money"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PricingOverride replaces some of a pricing plan's terms for one invoice. Fields left out
+// keep the plan's value; a reason is always required.
+type PricingOverride struct {
+	AdvanceRatePercentage  *decimal.Decimal `json:"advanceRatePercentage,omitempty" validate:"omitempty,gt=0,lte=100"`
+	DiscountRatePercentage *decimal.Decimal `json:"discountRatePercentage,omitempty" validate:"omitempty,gte=0,lte=100"`
+	FlatFee                *money.Amount    `json:"flatFee,omitempty" validate:"omitempty,gte=0"`
+	Reason                 string           `json:"reason" validate:"required,max=1000"`
+}
+
+type PricingBreakdownResponse struct {
+	PlanID                 *uint           `json:"planId,omitempty"`
+	RiskTier               string          `json:"riskTier"`
+	AdvanceRatePercentage  decimal.Decimal `json:"advanceRatePercentage"`
+	DiscountRatePercentage decimal.Decimal `json:"discountRatePercentage"` // Annual
+	DiscountDays           int             `json:"discountDays"`
+	AdvanceAmount          money.Amount    `json:"advanceAmount"`
+	DiscountFee            money.Amount    `json:"discountFee"`
+	FlatFee                money.Amount    `json:"flatFee"`
+	TotalFees              money.Amount    `json:"totalFees"`
+	NetDisbursement        money.Amount    `json:"netDisbursement"`
+	Overridden             bool            `json:"overridden"`
+	OverrideReason         *string         `json:"overrideReason,omitempty"`
+	PricedAt               *time.Time      `json:"pricedAt,omitempty"`
+}
+
+type PricingPlanResponse struct {
+	ID                     uint            `json:"id"`
+	Name                   string          `json:"name"`
+	RiskTier               string          `json:"riskTier"`
+	Currency               string          `json:"currency"`
+	AdvanceRatePercentage  decimal.Decimal `json:"advanceRatePercentage"`
+	DiscountRatePercentage decimal.Decimal `json:"discountRatePercentage"`
+	MinimumDays            int             `json:"minimumDays"`
+	FlatFee                money.Amount    `json:"flatFee"`
+	UpdatedAt              time.Time       `json:"updatedAt"`
+}
+
+type SetPricingPlanRequest struct {
+	Name                   string          `json:"name" validate:"required,max=100"`
+	RiskTier               string          `json:"riskTier" validate:"required,oneof=low medium high"`
+	Currency               string          `json:"currency" validate:"required,len=3,alpha"`
+	AdvanceRatePercentage  decimal.Decimal `json:"advanceRatePercentage" validate:"gt=0,lte=100"`
+	DiscountRatePercentage decimal.Decimal `json:"discountRatePercentage" validate:"gte=0,lte=100"`
+	MinimumDays            int             `json:"minimumDays" validate:"gte=0,lte=365"`
+	FlatFee                money.Amount    `json:"flatFee" validate:"gte=0"`
+}
+
+type DebtorRiskRatingResponse struct {
+	ID         uint      `json:"id"`
+	DebtorName string    `json:"debtorName"`
+	RiskTier   string    `json:"riskTier"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type SetDebtorRiskRatingRequest struct {
+	DebtorName string `json:"debtorName" validate:"required,max=255"`
+	RiskTier   string `json:"riskTier" validate:"required,oneof=low medium high"`
+}
+
+type SetRiskTierRequest struct {
+	RiskTier string `json:"riskTier" validate:"required,oneof=low medium high"`
+}
+
+// FinancingQuoteRequest asks what financing an invoice would get before it is uploaded.
+type FinancingQuoteRequest struct {
+	Amount     money.Amount `json:"amount" validate:"gt=0"`
+	Currency   string       `json:"currency" validate:"required,len=3,alpha"`
+	DebtorName string       `json:"debtorName" validate:"required,max=255"`
+	DueDate    string       `json:"dueDate" validate:"required,datetime=2006-01-02"` // Expect YYYY-MM-DD
+}
+
+// FinancingQuoteResponse is an estimate, valid until ExpiresAt; the final terms are set when
+// the invoice is disbursed. The advance is repaid on the invoice due date. Quotes shown to
+// impersonating staff are not saved and have no ID.
+type FinancingQuoteResponse struct {
+	ID                     uint            `json:"id,omitempty"`
+	InvoiceID              *uint           `json:"invoiceId,omitempty"`
+	DebtorName             string          `json:"debtorName"`
+	Amount                 money.Amount    `json:"amount"`
+	Currency               string          `json:"currency"`
+	AdvanceRatePercentage  decimal.Decimal `json:"advanceRatePercentage"`
+	AdvanceAmount          money.Amount    `json:"advanceAmount"`
+	DiscountRatePercentage decimal.Decimal `json:"discountRatePercentage"` // Annual
+	DiscountDays           int             `json:"discountDays"`
+	DiscountFee            money.Amount    `json:"discountFee"`
+	FlatFee                money.Amount    `json:"flatFee"`
+	TotalFees              money.Amount    `json:"totalFees"`
+	NetDisbursement        money.Amount    `json:"netDisbursement"`
+	RepaymentDate          time.Time       `json:"repaymentDate"`
+	RepaymentAmount        money.Amount    `json:"repaymentAmount"`
+	CreatedAt              time.Time       `json:"createdAt"`
+	ExpiresAt              time.Time       `json:"expiresAt"`
+}
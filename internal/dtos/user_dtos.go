@@ -5,16 +5,20 @@ import (
 )
 
 type UserProfileResponse struct {
-	ID           uint      `json:"id"`
-	Email        string    `json:"email"`
-	FirstName    string    `json:"firstName"`
-	LastName     string    `json:"lastName"`
-	CompanyName  string    `json:"companyName"`
-	IsActive     bool      `json:"isActive"`
-	TwoFAEnabled bool      `json:"twoFaEnabled"`
-	KYCStatus    string    `json:"kycStatus"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID              uint       `json:"id"`
+	Email           string     `json:"email"`
+	FirstName       string     `json:"firstName"`
+	LastName        string     `json:"lastName"`
+	CompanyName     string     `json:"companyName"`
+	IsActive        bool       `json:"isActive"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	TwoFAEnabled    bool       `json:"twoFaEnabled"`
+	TwoFAMethod     string     `json:"twoFaMethod"`
+	KYCStatus       string     `json:"kycStatus"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	Impersonated    bool       `json:"impersonated"` // A staff member is viewing the account; the dashboard shows a banner
+	ImpersonatedBy  *uint      `json:"impersonatedBy,omitempty"`
 }
 
 type UpdateUserProfileRequest struct {
@@ -39,3 +43,28 @@ type KYCStatusResponse struct {
 	RejectionReason *string    `json:"rejectionReason,omitempty"`
 	Message         string     `json:"message"`
 }
+
+// API key DTOs
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=invoices:read invoices:write repayments:write"`
+	ExpiresInDays *int     `json:"expiresInDays,omitempty" validate:"omitempty,min=1,max=730"`
+}
+
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIp,omitempty"`
+	Expired    bool       `json:"expired"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreateAPIKeyResponse carries the full key, which is shown only once.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
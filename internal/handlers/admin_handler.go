@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"invoiceB2B/internal/config"
 	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
 	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
 	"path/filepath"
@@ -15,17 +18,25 @@ import (
 
 // AdminHandler handles HTTP requests for admin-related operations.
 type AdminHandler struct {
-	adminService services.AdminService
-	fileService  services.FileService // fileService is used for receipt uploads
-	validate     *validator.Validate
+	adminService      services.AdminService
+	authService       services.AuthService
+	permissionService services.PermissionService
+	pricingService    services.PricingService
+	fileService       services.FileService // fileService is used for receipt uploads
+	validate          *validator.Validate
+	cfg               *config.Config
 }
 
 // NewAdminHandler creates a new AdminHandler.
-func NewAdminHandler(adminService services.AdminService, fileService services.FileService, validate *validator.Validate) *AdminHandler {
+func NewAdminHandler(adminService services.AdminService, authService services.AuthService, permissionService services.PermissionService, pricingService services.PricingService, fileService services.FileService, validate *validator.Validate, cfg *config.Config) *AdminHandler {
 	return &AdminHandler{
-		adminService: adminService,
-		fileService:  fileService,
-		validate:     validate,
+		adminService:      adminService,
+		authService:       authService,
+		permissionService: permissionService,
+		pricingService:    pricingService,
+		fileService:       fileService,
+		validate:          validate,
+		cfg:               cfg,
 	}
 }
 
@@ -61,6 +72,62 @@ func (h *AdminHandler) GetAdminProfile(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(staffProfile)
 }
 
+// ChangePassword lets the logged-in staff member change their own password.
+func (h *AdminHandler) ChangePassword(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+
+	var req dtos.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	resp, err := h.authService.ChangeStaffPassword(c.Context(), staffID, req.CurrentPassword, req.NewPassword, clientInfo(c))
+	if err != nil {
+		return handleChangePasswordError(c, err)
+	}
+
+	return changePasswordResponse(c, h.cfg, resp)
+}
+
+// ListOwnSessions lists the logged-in staff member's active sessions.
+func (h *AdminHandler) ListOwnSessions(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	currentSessionID, _ := c.Locals("session_id").(string)
+
+	sessions, err := h.authService.ListSessions(c.Context(), services.PrincipalTypeStaff, staffID, currentSessionID)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve sessions.", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(sessions)
+}
+
+// RevokeOwnSession signs out one of the logged-in staff member's sessions.
+func (h *AdminHandler) RevokeOwnSession(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+
+	if err := h.authService.RevokeSession(c.Context(), services.PrincipalTypeStaff, staffID, c.Params("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Session not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to revoke session.", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session signed out successfully."})
+}
+
 // --- Admin User & KYC Management ---
 
 // GetAllUsers retrieves a paginated list of all users.
@@ -101,6 +168,56 @@ func (h *AdminHandler) GetUserByID(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(user)
 }
 
+// RevokeUserSessions signs a customer out of every device.
+func (h *AdminHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format.", err)
+	}
+
+	if err := h.authService.RevokeAllUserSessions(c.Context(), staffID, uint(userID)); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "User not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to revoke user sessions.", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "All sessions for this user have been signed out."})
+}
+
+// GetLockedAccounts lists accounts currently locked out after failed logins.
+func (h *AdminHandler) GetLockedAccounts(c *fiber.Ctx) error {
+	locked, err := h.adminService.GetLockedAccounts(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve locked accounts.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(locked)
+}
+
+// UnlockAccount lifts a login lockout before it expires.
+func (h *AdminHandler) UnlockAccount(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	var req dtos.AdminUnlockAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.adminService.UnlockAccount(c.Context(), staffID, req.Email); err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to unlock account.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account unlocked successfully."})
+}
+
 // GetUserKYCDetail retrieves KYC details for a specific user.
 func (h *AdminHandler) GetUserKYCDetail(c *fiber.Ctx) error {
 	userIDStr := c.Params("id")
@@ -166,6 +283,96 @@ func (h *AdminHandler) ReviewKYC(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(updatedKYC)
 }
 
+// GetOrganizationKYCDetail retrieves the KYC record of an organisation.
+func (h *AdminHandler) GetOrganizationKYCDetail(c *fiber.Ctx) error {
+	organizationID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid organization ID format.", err)
+	}
+
+	kycDetail, err := h.adminService.GetOrganizationKYCDetail(c.Context(), uint(organizationID))
+	if err != nil {
+		if errors.Is(err, services.ErrKYCNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "KYC details not found for organization.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve KYC details.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(kycDetail)
+}
+
+// ReviewOrganizationKYC approves or rejects an organisation's KYC application.
+func (h *AdminHandler) ReviewOrganizationKYC(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	organizationID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid organization ID format.", err)
+	}
+
+	var req dtos.AdminKYCReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+	if req.Status == models.KYCRejected && (req.RejectionReason == nil || strings.TrimSpace(*req.RejectionReason) == "") {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Rejection reason is required when rejecting KYC.", nil)
+	}
+
+	updatedKYC, err := h.adminService.ReviewOrganizationKYC(c.Context(), uint(organizationID), staffID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrKYCNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "KYC details not found for organization.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to update KYC status.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(updatedKYC)
+}
+
+// ImpersonateUser starts a read-only "view as user" session and returns its access token.
+func (h *AdminHandler) ImpersonateUser(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format.", err)
+	}
+
+	impersonation, err := h.authService.StartImpersonation(c.Context(), staffID, uint(userID), clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "User not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to start impersonation.", err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(impersonation)
+}
+
+// StopImpersonation ends a "view as user" session before its token expires.
+func (h *AdminHandler) StopImpersonation(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format.", err)
+	}
+
+	if err := h.authService.StopImpersonation(c.Context(), staffID, uint(userID), c.Params("sessionId")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Impersonation session not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to stop impersonation.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Impersonation ended."})
+}
+
 // --- Admin Invoice Management ---
 
 // GetAllInvoices retrieves a paginated list of all invoices, with optional filters.
@@ -185,6 +392,20 @@ func (h *AdminHandler) GetAllInvoices(c *fiber.Ctx) error {
 	})
 }
 
+// GetOutstandingBalances lists disbursed invoices that have not been fully repaid.
+func (h *AdminHandler) GetOutstandingBalances(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize", "10"))
+
+	balances, total, err := h.adminService.GetOutstandingBalances(c.Context(), page, pageSize)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve outstanding balances.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dtos.OutstandingBalanceListResponse{
+		Balances: balances, Total: total, Page: page, PageSize: pageSize,
+	})
+}
+
 // GetInvoiceDetail retrieves details for a specific invoice.
 func (h *AdminHandler) GetInvoiceDetail(c *fiber.Ctx) error {
 	invoiceIDStr := c.Params("id")
@@ -202,6 +423,22 @@ func (h *AdminHandler) GetInvoiceDetail(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(invoice)
 }
 
+// GetInvoiceHistory lists every status change of an invoice, oldest first.
+func (h *AdminHandler) GetInvoiceHistory(c *fiber.Ctx) error {
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invoice ID format.", err)
+	}
+	history, err := h.adminService.GetInvoiceHistory(c.Context(), uint(invoiceID))
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve invoice history.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(history)
+}
+
 // UpdateInvoiceStatus allows an admin to update the status of an invoice.
 func (h *AdminHandler) UpdateInvoiceStatus(c *fiber.Ctx) error {
 	claims, ok := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
@@ -235,15 +472,39 @@ func (h *AdminHandler) UpdateInvoiceStatus(c *fiber.Ctx) error {
 	if errs := h.validate.Struct(req); errs != nil {
 		return utils.HandleValidationError(c, errs)
 	}
+	// The route only requires invoice:read; the permission needed depends on the target status
+	if permission := invoiceStatusPermission(req.Status); !utils.HasStaffPermission(c, permission) {
+		return utils.HandleError(c, fiber.StatusForbidden, "Access denied. Missing permission: "+permission, nil)
+	}
 
 	updatedInvoice, err := h.adminService.UpdateInvoiceStatus(c.Context(), uint(invoiceID), adminStaffID, req)
 	if err != nil {
+		var limitErr *services.ApprovalLimitExceededError
+		if errors.As(err, &limitErr) {
+			return approvalLimitExceeded(c, limitErr)
+		}
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found.", err)
+		}
+		if errors.Is(err, models.ErrInvalidInvoiceTransition) {
+			return utils.HandleError(c, fiber.StatusConflict, "Invoice cannot move to "+string(req.Status)+" from its current status.", err)
+		}
 		// Provide more specific error messages based on service errors if possible
 		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to update invoice status.", err)
 	}
 	return c.Status(fiber.StatusOK).JSON(updatedInvoice)
 }
 
+// invoiceStatusPermission returns the permission required to move an invoice to status.
+func invoiceStatusPermission(status models.InvoiceStatus) string {
+	switch status {
+	case models.InvoiceRepaid:
+		return models.PermInvoiceDisburse
+	default:
+		return models.PermInvoiceApprove
+	}
+}
+
 // UploadDisbursementReceipt allows an admin to upload a disbursement receipt for an invoice.
 func (h *AdminHandler) UploadDisbursementReceipt(c *fiber.Ctx) error {
 	claims, ok := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
@@ -343,25 +604,481 @@ func (h *AdminHandler) DownloadInvoicePDF(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(pdfResponse)
 }
 
-// --- Admin Staff Management ---
+// --- Disbursement Approvals ---
+
+// RequestDisbursement proposes a disbursement for an approved invoice. It only takes effect
+// once a different staff member approves it.
+func (h *AdminHandler) RequestDisbursement(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invoice ID format.", err)
+	}
+	var req dtos.RequestDisbursementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	approval, err := h.adminService.RequestDisbursement(c.Context(), uint(invoiceID), staffID, req)
+	if err != nil {
+		return handleDisbursementError(c, err, "Failed to request disbursement.")
+	}
+	return c.Status(fiber.StatusCreated).JSON(approval)
+}
+
+// GetPendingDisbursements lists disbursement requests waiting for a second staff member.
+func (h *AdminHandler) GetPendingDisbursements(c *fiber.Ctx) error {
+	approvals, err := h.adminService.GetPendingDisbursements(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve pending disbursements.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(approvals)
+}
+
+// ApproveDisbursement confirms another staff member's disbursement request and pays it out.
+func (h *AdminHandler) ApproveDisbursement(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	approvalID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid disbursement request ID format.", err)
+	}
+
+	approval, err := h.adminService.ApproveDisbursement(c.Context(), uint(approvalID), staffID)
+	if err != nil {
+		return handleDisbursementError(c, err, "Failed to approve disbursement.")
+	}
+	return c.Status(fiber.StatusOK).JSON(approval)
+}
+
+// RejectDisbursement closes a disbursement request without paying out.
+func (h *AdminHandler) RejectDisbursement(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	approvalID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid disbursement request ID format.", err)
+	}
+	var req dtos.RejectDisbursementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	approval, err := h.adminService.RejectDisbursement(c.Context(), uint(approvalID), staffID, req)
+	if err != nil {
+		return handleDisbursementError(c, err, "Failed to reject disbursement.")
+	}
+	return c.Status(fiber.StatusOK).JSON(approval)
+}
+
+func handleDisbursementError(c *fiber.Ctx, err error, fallback string) error {
+	var limitErr *services.ApprovalLimitExceededError
+	switch {
+	case errors.As(err, &limitErr):
+		return approvalLimitExceeded(c, limitErr)
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found.", err)
+	case errors.Is(err, services.ErrDisbursementNotFound):
+		return utils.HandleError(c, fiber.StatusNotFound, "Disbursement request not found or no longer pending.", err)
+	case errors.Is(err, services.ErrDisbursementAlreadyPending):
+		return utils.HandleError(c, fiber.StatusConflict, err.Error(), err)
+	case errors.Is(err, services.ErrDisbursementSelfApproval):
+		return utils.HandleError(c, fiber.StatusForbidden, err.Error(), err)
+	case errors.Is(err, services.ErrInvoiceNotApprovedForDisbursement), errors.Is(err, services.ErrFinancedAmountExceedsInvoice):
+		return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+	case isPricingError(err):
+		return handlePricingError(c, err, fallback)
+	default:
+		return utils.HandleError(c, fiber.StatusInternalServerError, fallback, err)
+	}
+}
+
+// --- Pricing ---
+
+func isPricingError(err error) bool {
+	return errors.Is(err, services.ErrNoPricingPlan) ||
+		errors.Is(err, services.ErrPricingInputIncomplete) ||
+		errors.Is(err, services.ErrPricingNoNetPayout)
+}
+
+func handlePricingError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrNoPricingPlan), errors.Is(err, services.ErrPricingNoNetPayout):
+		return utils.HandleError(c, fiber.StatusUnprocessableEntity, err.Error(), err)
+	case errors.Is(err, services.ErrPricingInputIncomplete):
+		return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+	default:
+		return utils.HandleError(c, fiber.StatusInternalServerError, fallback, err)
+	}
+}
 
-// CreateStaff allows an admin to create a new staff member.
-func (h *AdminHandler) CreateStaff(c *fiber.Ctx) error {
-	var req dtos.CreateStaffRequest
+// GetInvoicePricing previews the pricing breakdown an invoice would be disbursed on.
+func (h *AdminHandler) GetInvoicePricing(c *fiber.Ctx) error {
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invoice ID format.", err)
+	}
+	pricing, err := h.adminService.GetInvoicePricing(c.Context(), uint(invoiceID))
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found.", err)
+		}
+		return handlePricingError(c, err, "Failed to price invoice.")
+	}
+	return c.Status(fiber.StatusOK).JSON(pricing)
+}
+
+// GetPricingPlans lists the pricing plans of all risk tiers and currencies.
+func (h *AdminHandler) GetPricingPlans(c *fiber.Ctx) error {
+	plans, err := h.pricingService.ListPlans(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve pricing plans.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(plans)
+}
+
+// SetPricingPlan creates or replaces the pricing plan for a risk tier and currency.
+func (h *AdminHandler) SetPricingPlan(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	var req dtos.SetPricingPlanRequest
 	if err := c.BodyParser(&req); err != nil {
 		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
 	}
 	if errs := h.validate.Struct(req); errs != nil {
 		return utils.HandleValidationError(c, errs)
 	}
-	staff, err := h.adminService.CreateStaff(c.Context(), req)
+
+	plan, err := h.pricingService.SetPlan(c.Context(), staffID, req)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to save pricing plan.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(plan)
+}
+
+// DeletePricingPlan removes a pricing plan.
+func (h *AdminHandler) DeletePricingPlan(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	planID, err := strconv.ParseUint(c.Params("id"), 10, 64)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
-			return utils.HandleError(c, fiber.StatusConflict, "Staff with this email already exists.", err)
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid pricing plan ID format.", err)
+	}
+
+	if err := h.pricingService.DeletePlan(c.Context(), staffID, uint(planID)); err != nil {
+		if errors.Is(err, services.ErrPricingPlanNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Pricing plan not found.", err)
 		}
-		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to create staff.", err)
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to delete pricing plan.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Pricing plan deleted."})
+}
+
+// GetDebtorRatings lists the risk tiers assigned to debtors.
+func (h *AdminHandler) GetDebtorRatings(c *fiber.Ctx) error {
+	ratings, err := h.pricingService.ListDebtorRatings(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve debtor risk ratings.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(ratings)
+}
+
+// SetDebtorRating creates or replaces the risk tier of a debtor.
+func (h *AdminHandler) SetDebtorRating(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	var req dtos.SetDebtorRiskRatingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	rating, err := h.pricingService.SetDebtorRating(c.Context(), staffID, req)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to save debtor risk rating.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(rating)
+}
+
+// DeleteDebtorRating removes a debtor's risk tier.
+func (h *AdminHandler) DeleteDebtorRating(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	ratingID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid debtor risk rating ID format.", err)
+	}
+
+	if err := h.pricingService.DeleteDebtorRating(c.Context(), staffID, uint(ratingID)); err != nil {
+		if errors.Is(err, services.ErrDebtorRatingNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Debtor risk rating not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to delete debtor risk rating.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Debtor risk rating deleted."})
+}
+
+// SetOrganizationRiskTier sets the risk tier a customer organisation is priced at.
+func (h *AdminHandler) SetOrganizationRiskTier(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	organizationID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid organization ID format.", err)
+	}
+	var req dtos.SetRiskTierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.pricingService.SetOrganizationRiskTier(c.Context(), staffID, uint(organizationID), req.RiskTier); err != nil {
+		if errors.Is(err, services.ErrOrganizationNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Organization not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to set organization risk tier.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Organization risk tier updated."})
+}
+
+// --- Approval Limits & Escalations ---
+
+func approvalLimitExceeded(c *fiber.Ctx, err *services.ApprovalLimitExceededError) error {
+	return utils.HandleError(c, fiber.StatusForbidden, err.Error()+". The invoice has been escalated for approval.", err)
+}
+
+// GetApprovalLimits lists the monetary approval limits of all staff roles.
+func (h *AdminHandler) GetApprovalLimits(c *fiber.Ctx) error {
+	limits, err := h.adminService.GetApprovalLimits(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve approval limits.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(limits)
+}
+
+// SetApprovalLimit creates or replaces a role's approval limit for one currency.
+func (h *AdminHandler) SetApprovalLimit(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	var req dtos.SetApprovalLimitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	limit, err := h.adminService.SetApprovalLimit(c.Context(), staffID, req)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to save approval limit.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(limit)
+}
+
+// DeleteApprovalLimit removes an approval limit.
+func (h *AdminHandler) DeleteApprovalLimit(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	limitID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid approval limit ID format.", err)
+	}
+
+	if err := h.adminService.DeleteApprovalLimit(c.Context(), staffID, uint(limitID)); err != nil {
+		if errors.Is(err, services.ErrApprovalLimitNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Approval limit not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to delete approval limit.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Approval limit deleted."})
+}
+
+// GetEscalations lists invoices waiting for a staff member with a higher approval limit.
+func (h *AdminHandler) GetEscalations(c *fiber.Ctx) error {
+	escalations, err := h.adminService.GetOpenEscalations(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve escalated invoices.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(escalations)
+}
+
+// --- Admin Staff Management ---
+
+// ResetStaff2FA clears a staff member's second factor so they can enroll again, e.g. after
+// losing their authenticator device.
+func (h *AdminHandler) ResetStaff2FA(c *fiber.Ctx) error {
+	adminStaffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	staffID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid staff ID format.", err)
+	}
+
+	if err := h.authService.ResetStaff2FA(c.Context(), adminStaffID, uint(staffID)); err != nil {
+		if errors.Is(err, services.ErrStaffNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Staff member not found.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to reset staff 2FA.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Two-factor authentication reset. The staff member has been signed out."})
+}
+
+// InviteStaff sends a staff invitation to the given email with the chosen role.
+func (h *AdminHandler) InviteStaff(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	var req dtos.InviteStaffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	invitation, err := h.adminService.InviteStaff(c.Context(), staffID, req)
+	if err != nil {
+		return handleStaffInvitationError(c, err, "Failed to invite staff member.")
+	}
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+// GetStaffInvitations lists staff invitations that are still pending.
+func (h *AdminHandler) GetStaffInvitations(c *fiber.Ctx) error {
+	invitations, err := h.adminService.GetPendingStaffInvitations(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve staff invitations.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(invitations)
+}
+
+// ResendStaffInvitation emails a pending invitation again with a fresh link.
+func (h *AdminHandler) ResendStaffInvitation(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	invitationID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invitation ID format.", err)
+	}
+
+	invitation, err := h.adminService.ResendStaffInvitation(c.Context(), staffID, uint(invitationID))
+	if err != nil {
+		return handleStaffInvitationError(c, err, "Failed to resend staff invitation.")
+	}
+	return c.Status(fiber.StatusOK).JSON(invitation)
+}
+
+// RevokeStaffInvitation cancels a pending invitation so its link can no longer be used.
+func (h *AdminHandler) RevokeStaffInvitation(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	invitationID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invitation ID format.", err)
+	}
+
+	if err := h.adminService.RevokeStaffInvitation(c.Context(), staffID, uint(invitationID)); err != nil {
+		return handleStaffInvitationError(c, err, "Failed to revoke staff invitation.")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Staff invitation revoked."})
+}
+
+// BeginStaffInvitation2FA returns the authenticator secret and QR code an invitee must set up
+// before accepting. Like AcceptStaffInvitation it is public.
+func (h *AdminHandler) BeginStaffInvitation2FA(c *fiber.Ctx) error {
+	var req dtos.BeginStaffInvitation2FARequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	enrollment, err := h.adminService.BeginStaffInvitation2FA(c.Context(), req.Token)
+	if err != nil {
+		return handleStaffInvitationError(c, err, "Failed to start 2FA setup.")
+	}
+	return c.Status(fiber.StatusOK).JSON(enrollment)
+}
+
+// AcceptStaffInvitation creates the invitee's staff account. It is public: the emailed token
+// is the credential.
+func (h *AdminHandler) AcceptStaffInvitation(c *fiber.Ctx) error {
+	var req dtos.AcceptStaffInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	staff, err := h.adminService.AcceptStaffInvitation(c.Context(), req)
+	if err != nil {
+		return handleStaffInvitationError(c, err, "Failed to accept staff invitation.")
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Invitation accepted and 2FA enabled. You can now log in.",
+		"staff":   staff,
+	})
+}
+
+func handleStaffInvitationError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrStaffExists):
+		return utils.HandleError(c, fiber.StatusConflict, "Staff with this email already exists.", err)
+	case errors.Is(err, services.ErrInvitationAlreadyPending):
+		return utils.HandleError(c, fiber.StatusConflict, "An invitation is already pending for this email.", err)
+	case errors.Is(err, services.ErrInvitationNotFound):
+		return utils.HandleError(c, fiber.StatusNotFound, "Staff invitation not found.", err)
+	case errors.Is(err, services.ErrInvitationInvalid):
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invitation is invalid or has expired.", err)
+	case errors.Is(err, services.ErrWeakPassword):
+		return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, services.ErrTOTPEnrollmentExpired):
+		return utils.HandleError(c, fiber.StatusBadRequest, "2FA setup has expired, please start it again.", err)
+	case errors.Is(err, services.ErrOTPInvalidOrExpired):
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid authenticator code.", err)
+	default:
+		return utils.HandleError(c, fiber.StatusInternalServerError, fallback, err)
 	}
-	return c.Status(fiber.StatusCreated).JSON(staff)
 }
 
 // GetAllStaff retrieves a paginated list of all staff members.
@@ -489,3 +1206,47 @@ func (h *AdminHandler) GetAdminDashboardAnalytics(c *fiber.Ctx) error {
 	}
 	return c.Status(fiber.StatusOK).JSON(analytics)
 }
+
+// --- Role Permissions ---
+
+// GetPermissionCatalogue lists every permission that can be granted to a staff role.
+func (h *AdminHandler) GetPermissionCatalogue(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(h.permissionService.GetCatalogue())
+}
+
+// GetRolePermissions lists the permissions currently held by each staff role.
+func (h *AdminHandler) GetRolePermissions(c *fiber.Ctx) error {
+	roles, err := h.permissionService.GetRolePermissions(c.Context())
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve role permissions.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(roles)
+}
+
+// UpdateRolePermissions replaces the permissions of a staff role.
+func (h *AdminHandler) UpdateRolePermissions(c *fiber.Ctx) error {
+	staffID, ok := c.Locals("staff_id").(uint)
+	if !ok {
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Staff ID not found in context.", nil)
+	}
+	var req dtos.UpdateRolePermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	role, err := h.permissionService.UpdateRolePermissions(c.Context(), staffID, c.Params("role"), req.Permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownStaffRole):
+			return utils.HandleError(c, fiber.StatusNotFound, "Staff role not found.", err)
+		case errors.Is(err, services.ErrUnknownPermission), errors.Is(err, services.ErrPermissionLockout):
+			return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+		default:
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to update role permissions.", err)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(role)
+}
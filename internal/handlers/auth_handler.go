@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"invoiceB2B/internal/config"
 	"invoiceB2B/internal/dtos"
 	"invoiceB2B/internal/models"
 	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
 	"log"
+	"math"
+	"strconv"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -15,12 +19,14 @@ import (
 type AuthHandler struct {
 	authService services.AuthService
 	validate    *validator.Validate
+	cfg         *config.Config
 }
 
-func NewAuthHandler(authService services.AuthService, validate *validator.Validate) *AuthHandler {
+func NewAuthHandler(authService services.AuthService, validate *validator.Validate, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
 		validate:    validate,
+		cfg:         cfg,
 	}
 }
 
@@ -49,15 +55,16 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusCreated).JSON(dtos.RegisterUserResponse{
 		User: dtos.UserResponse{
-			ID:           createdUser.ID,
-			Email:        createdUser.Email,
-			FirstName:    createdUser.FirstName,
-			LastName:     createdUser.LastName,
-			CompanyName:  createdUser.CompanyName,
-			IsActive:     createdUser.IsActive,
-			TwoFAEnabled: createdUser.TwoFAEnabled,
+			ID:            createdUser.ID,
+			Email:         createdUser.Email,
+			FirstName:     createdUser.FirstName,
+			LastName:      createdUser.LastName,
+			CompanyName:   createdUser.CompanyName,
+			IsActive:      createdUser.IsActive,
+			EmailVerified: createdUser.IsEmailVerified(),
+			TwoFAEnabled:  createdUser.TwoFAEnabled,
 		},
-		Message: "User registered successfully. A KYC record has been initiated.",
+		Message: "User registered successfully. Please check your inbox to verify your email address.",
 	})
 }
 
@@ -71,11 +78,21 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// The authService.LoginUser now returns the dtos.LoginUserResponse directly
-	loginResponse, err := h.authService.LoginUser(c.Context(), req.Email, req.Password)
+	loginResponse, err := h.authService.LoginUser(c.Context(), req.Email, req.Password, clientInfo(c))
 	if err != nil {
+		if attemptErr := (*services.LoginAttemptError)(nil); errors.As(err, &attemptErr) {
+			return loginAttemptRefused(c, attemptErr)
+		}
 		return utils.HandleError(c, fiber.StatusUnauthorized, "Login failed", err)
 	}
 
+	if loginResponse.AccessToken != "" && h.cfg.AuthCookieMode {
+		if err := utils.SetAuthCookies(c, h.cfg, loginResponse.AccessToken, loginResponse.AccessTokenExpiresAt, loginResponse.RefreshToken); err != nil {
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Login failed", err)
+		}
+		loginResponse.AccessToken, loginResponse.RefreshToken = "", ""
+	}
+
 	// loginResponse already contains all necessary fields including Role and RedirectPath
 	return c.Status(fiber.StatusOK).JSON(loginResponse)
 }
@@ -88,30 +105,113 @@ func (h *AuthHandler) Verify2FA(c *fiber.Ctx) error {
 		return utils.HandleValidationError(c, errs)
 	}
 
-	result, err := h.authService.VerifyOTP(c.Context(), req.Email, req.OTP)
+	if (req.OTP == "") == (req.RecoveryCode == "") {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Provide either an OTP or a recovery code", nil)
+	}
+
+	var result *dtos.LoginUserResponse
+	var err error
+	if req.RecoveryCode != "" {
+		result, err = h.authService.VerifyRecoveryCode(c.Context(), req.Email, req.TwoFAToken, req.RecoveryCode, clientInfo(c))
+	} else {
+		result, err = h.authService.VerifyOTP(c.Context(), req.Email, req.TwoFAToken, req.OTP, clientInfo(c))
+	}
 	if err != nil {
+		if attemptErr := (*services.LoginAttemptError)(nil); errors.As(err, &attemptErr) {
+			return loginAttemptRefused(c, attemptErr)
+		}
+		if req.RecoveryCode != "" {
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Recovery code verification failed", err)
+		}
 		return utils.HandleError(c, fiber.StatusUnauthorized, "OTP verification failed", err)
 	}
 
+	if h.cfg.AuthCookieMode {
+		if err := utils.SetAuthCookies(c, h.cfg, result.AccessToken, result.AccessTokenExpiresAt, result.RefreshToken); err != nil {
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to start session", err)
+		}
+		result.AccessToken, result.RefreshToken = "", ""
+	}
+
 	// The result from authService.VerifyOTP is *dtos.LoginUserResponse
 	// So we can directly return it or its fields.
 	return c.Status(fiber.StatusOK).JSON(dtos.VerifyOTPResponse{ // Using VerifyOTPResponse DTO
 		User: dtos.UserResponse{
-			ID:           result.User.ID,
-			Email:        result.User.Email,
-			FirstName:    result.User.FirstName,
-			LastName:     result.User.LastName,
-			CompanyName:  result.User.CompanyName,
-			IsActive:     result.User.IsActive,
-			TwoFAEnabled: result.User.TwoFAEnabled,
+			ID:            result.User.ID,
+			Email:         result.User.Email,
+			FirstName:     result.User.FirstName,
+			LastName:      result.User.LastName,
+			CompanyName:   result.User.CompanyName,
+			IsActive:      result.User.IsActive,
+			EmailVerified: result.User.EmailVerified,
+			TwoFAEnabled:  result.User.TwoFAEnabled,
+			TwoFAMethod:   result.User.TwoFAMethod,
 		},
 		AccessToken:          result.AccessToken,
 		RefreshToken:         result.RefreshToken,
-		Message:              "OTP verified successfully. Login complete.",
+		Message:              result.Message,
 		AccessTokenExpiresAt: result.AccessTokenExpiresAt,
+		Role:                 result.Role,
+		RedirectPath:         result.RedirectPath,
 	})
 }
 
+// BeginStaff2FAEnrollment starts the second-factor setup that staff under the 2FA policy must
+// complete before their first login finishes.
+func (h *AuthHandler) BeginStaff2FAEnrollment(c *fiber.Ctx) error {
+	var req dtos.StaffTwoFAEnrollmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	resp, err := h.authService.BeginStaff2FAEnrollment(c.Context(), req.EnrollmentToken, req.Method)
+	if err != nil {
+		if errors.Is(err, services.ErrStaffEnrollmentInvalid) || errors.Is(err, services.ErrAccountNotActive) {
+			return utils.HandleError(c, fiber.StatusUnauthorized, err.Error(), err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to start 2FA enrollment", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// ConfirmStaff2FAEnrollment verifies the first code, enables 2FA and completes the login.
+func (h *AuthHandler) ConfirmStaff2FAEnrollment(c *fiber.Ctx) error {
+	var req dtos.StaffTwoFAConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	result, err := h.authService.ConfirmStaff2FAEnrollment(c.Context(), req.EnrollmentToken, req.Method, req.Code, clientInfo(c))
+	if err != nil {
+		if attemptErr := (*services.LoginAttemptError)(nil); errors.As(err, &attemptErr) {
+			return loginAttemptRefused(c, attemptErr)
+		}
+		switch {
+		case errors.Is(err, services.ErrStaffEnrollmentInvalid), errors.Is(err, services.ErrAccountNotActive):
+			return utils.HandleError(c, fiber.StatusUnauthorized, err.Error(), err)
+		case errors.Is(err, services.ErrTOTPEnrollmentExpired):
+			return utils.HandleError(c, fiber.StatusBadRequest, "Authenticator enrollment has expired. Please start again.", err)
+		case errors.Is(err, services.ErrOTPInvalidOrExpired):
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Invalid or expired code", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to confirm 2FA enrollment", err)
+	}
+
+	if h.cfg.AuthCookieMode {
+		if err := utils.SetAuthCookies(c, h.cfg, result.AccessToken, result.AccessTokenExpiresAt, result.RefreshToken); err != nil {
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to start session", err)
+		}
+		result.AccessToken, result.RefreshToken = "", ""
+	}
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	var req dtos.RefreshTokenRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -121,17 +221,30 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		return utils.HandleValidationError(c, errs)
 	}
 
-	newTokens, err := h.authService.RefreshToken(c.Context(), req.RefreshToken)
+	refreshToken := req.RefreshToken
+	if refreshToken == "" {
+		refreshToken = c.Cookies(utils.RefreshTokenCookie)
+		if refreshToken == "" {
+			return utils.HandleError(c, fiber.StatusBadRequest, "Refresh token is required", nil)
+		}
+		if !utils.ValidCSRFToken(c) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Missing or invalid CSRF token", nil)
+		}
+	}
+
+	newTokens, err := h.authService.RefreshToken(c.Context(), refreshToken, clientInfo(c))
 	if err != nil {
 		return utils.HandleError(c, fiber.StatusUnauthorized, "Failed to refresh token", err)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(dtos.RefreshTokenResponse{
-		AccessToken:          newTokens.AccessToken,
-		RefreshToken:         newTokens.RefreshToken,
-		AccessTokenExpiresAt: newTokens.AccessTokenExpiresAt,
-		Message:              "Token refreshed successfully.",
-	})
+	if h.cfg.AuthCookieMode {
+		if err := utils.SetAuthCookies(c, h.cfg, newTokens.AccessToken, newTokens.AccessTokenExpiresAt, newTokens.RefreshToken); err != nil {
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to refresh token", err)
+		}
+		newTokens.AccessToken, newTokens.RefreshToken = "", ""
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newTokens)
 }
 
 func (h *AuthHandler) Enable2FA(c *fiber.Ctx) error {
@@ -143,7 +256,7 @@ func (h *AuthHandler) Enable2FA(c *fiber.Ctx) error {
 		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
 	}
 
-	err := h.authService.Toggle2FA(c.Context(), userIDStr, req.Enable)
+	recoveryCodes, err := h.authService.Toggle2FA(c.Context(), userIDStr, req.Enable)
 	if err != nil {
 		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to update 2FA status", err)
 	}
@@ -152,13 +265,211 @@ func (h *AuthHandler) Enable2FA(c *fiber.Ctx) error {
 	if req.Enable {
 		message = "2FA enabled successfully. Future logins will require OTP."
 	}
+	if len(recoveryCodes) > 0 {
+		message += " Store your recovery codes somewhere safe; they will not be shown again."
+	}
 
 	return c.Status(fiber.StatusOK).JSON(dtos.Enable2FAResponse{
-		Message:      message,
-		TwoFAEnabled: req.Enable,
+		Message:       message,
+		TwoFAEnabled:  req.Enable,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (h *AuthHandler) SetupTOTP(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+
+	setup, err := h.authService.BeginTOTPEnrollment(c.Context(), userIDStr)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to start authenticator enrollment", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(setup)
+}
+
+func (h *AuthHandler) ConfirmTOTP(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+
+	var req dtos.TOTPConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	recoveryCodes, err := h.authService.ConfirmTOTPEnrollment(c.Context(), userIDStr, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTOTPEnrollmentExpired):
+			return utils.HandleError(c, fiber.StatusBadRequest, "Authenticator enrollment has expired. Please start again.", err)
+		case errors.Is(err, services.ErrOTPInvalidOrExpired):
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Invalid authenticator code", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to confirm authenticator enrollment", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dtos.Update2FAMethodResponse{
+		Message:       "Authenticator app enrolled successfully. Future logins will require a code from the app.",
+		TwoFAEnabled:  true,
+		TwoFAMethod:   models.TwoFAMethodTOTP,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (h *AuthHandler) Update2FAMethod(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+
+	var req dtos.Update2FAMethodRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.authService.Set2FAMethod(c.Context(), userIDStr, req.Method); err != nil {
+		switch {
+		case errors.Is(err, services.Err2FANotEnabled):
+			return utils.HandleError(c, fiber.StatusBadRequest, "2FA must be enabled before choosing a method", err)
+		case errors.Is(err, services.ErrTOTPNotEnrolled):
+			return utils.HandleError(c, fiber.StatusBadRequest, "Enroll an authenticator app before selecting it as your 2FA method", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to update 2FA method", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dtos.Update2FAMethodResponse{
+		Message:      "2FA method updated successfully.",
+		TwoFAEnabled: true,
+		TwoFAMethod:  req.Method,
+	})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Context(), userIDStr)
+	if err != nil {
+		if errors.Is(err, services.Err2FANotEnabled) {
+			return utils.HandleError(c, fiber.StatusBadRequest, "2FA must be enabled to generate recovery codes", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to regenerate recovery codes", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dtos.RecoveryCodesResponse{
+		Message:       "New recovery codes generated. Previous codes are no longer valid.",
+		RecoveryCodes: codes,
 	})
 }
 
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req dtos.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.authService.RequestPasswordReset(c.Context(), req.Email); err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to start password reset", err)
+	}
+
+	// Same response whether or not the email is registered, to avoid account enumeration
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "If an account exists for this email, a password reset link has been sent."})
+}
+
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req dtos.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.authService.ResetPassword(c.Context(), req.Token, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, services.ErrResetTokenInvalid):
+			return utils.HandleError(c, fiber.StatusBadRequest, "Password reset link is invalid or has expired", err)
+		case errors.Is(err, services.ErrWeakPassword):
+			return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to reset password", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Password reset successfully. Please log in with your new password."})
+}
+
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req dtos.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.authService.VerifyEmail(c.Context(), req.Token); err != nil {
+		if errors.Is(err, services.ErrVerificationInvalid) {
+			return utils.HandleError(c, fiber.StatusBadRequest, "Verification link is invalid or has expired", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to verify email", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Email address verified successfully."})
+}
+
+// UnlockAccount redeems the link emailed when an account is locked after failed logins.
+func (h *AuthHandler) UnlockAccount(c *fiber.Ctx) error {
+	var req dtos.UnlockAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	if err := h.authService.UnlockAccount(c.Context(), req.Token); err != nil {
+		if errors.Is(err, services.ErrUnlockTokenInvalid) {
+			return utils.HandleError(c, fiber.StatusBadRequest, "Unlock link is invalid or has expired", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to unlock account", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Account unlocked. You can sign in again."})
+}
+
+// loginAttemptRefused answers a throttled or locked-out login with 429 and a Retry-After header.
+func loginAttemptRefused(c *fiber.Ctx, err *services.LoginAttemptError) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	if err.Locked {
+		return utils.HandleError(c, fiber.StatusTooManyRequests, "Account is temporarily locked after repeated failed login attempts. Check your email for an unlock link.", err)
+	}
+	return utils.HandleError(c, fiber.StatusTooManyRequests, "Too many login attempts. Please wait before trying again.", err)
+}
+
+func (h *AuthHandler) ResendVerificationEmail(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+
+	if err := h.authService.ResendVerificationEmail(c.Context(), userIDStr); err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			return utils.HandleError(c, fiber.StatusBadRequest, "Email address is already verified", err)
+		case errors.Is(err, services.ErrVerificationThrottled):
+			return utils.HandleError(c, fiber.StatusTooManyRequests, "A verification email was sent recently. Please wait before requesting another.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to resend verification email", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Verification email sent."})
+}
+
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	claims, ok := c.Locals("user").(*jwt.Token)
 	if !ok {
@@ -172,6 +483,15 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	if err != nil {
 		log.Printf("Error during token invalidation on logout: %v", err)
 	}
+	utils.ClearAuthCookies(c, h.cfg)
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Logged out successfully"})
 }
+
+// clientInfo captures the caller's device details for the session registry.
+func clientInfo(c *fiber.Ctx) services.ClientInfo {
+	return services.ClientInfo{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IPAddress: c.IP(),
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
 	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
 	"strconv"
@@ -43,6 +45,15 @@ func (h *InternalHandler) UpdateInvoiceWithProcessedData(c *fiber.Ctx) error {
 
 	updatedInvoice, err := h.internalService.UpdateInvoiceWithProcessedData(c.Context(), uint(invoiceID), req, ipAddress)
 	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found.", err)
+		}
+		if errors.Is(err, services.ErrInvalidInvoiceStatusForOperation) {
+			return utils.HandleError(c, fiber.StatusConflict, "Invoice data can no longer be changed in its current status.", err)
+		}
+		if errors.Is(err, models.ErrInvalidInvoiceTransition) {
+			return utils.HandleError(c, fiber.StatusConflict, "Invoice status cannot be changed from its current status.", err)
+		}
 		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to update invoice with processed data.", err)
 	}
 
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
 	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
 	"path/filepath"
@@ -55,6 +57,12 @@ func (h *InvoiceHandler) UploadInvoice(c *fiber.Ctx) error {
 
 	invoiceResponse, err := h.invoiceService.CreateInvoice(c.Context(), uint(userID), req)
 	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Please verify your email address before uploading invoices.", err)
+		}
+		if errors.Is(err, services.ErrInvoiceUploadNotAllowed) || errors.Is(err, services.ErrNotOrganizationMember) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Your organization role does not allow uploading invoices.", err)
+		}
 		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to upload invoice.", err)
 	}
 
@@ -71,6 +79,9 @@ func (h *InvoiceHandler) GetUserInvoices(c *fiber.Ctx) error {
 
 	invoices, total, err := h.invoiceService.GetUserInvoices(c.Context(), uint(userID), page, pageSize)
 	if err != nil {
+		if errors.Is(err, services.ErrNotOrganizationMember) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Your account does not belong to an organization.", err)
+		}
 		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve invoices.", err)
 	}
 
@@ -100,6 +111,127 @@ func (h *InvoiceHandler) GetInvoiceByID(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(invoice)
 }
 
+// GetInvoiceHistory lists the status changes of one of the organisation's invoices.
+func (h *InvoiceHandler) GetInvoiceHistory(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := strconv.ParseUint(userIDStr, 10, 64)
+
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invoice ID format.", err)
+	}
+
+	history, err := h.invoiceService.GetInvoiceHistoryForUser(c.Context(), uint(invoiceID), uint(userID))
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) || errors.Is(err, services.ErrInvoiceAccessDenied) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found or access denied.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve invoice history.", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(history)
+}
+
+// QuoteFinancing estimates the advance, fees and net payout for an invoice before it is uploaded.
+func (h *InvoiceHandler) QuoteFinancing(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := strconv.ParseUint(userIDStr, 10, 64)
+
+	var req dtos.FinancingQuoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	quote, err := h.invoiceService.QuoteFinancing(c.Context(), uint(userID), req)
+	if err != nil {
+		if errors.Is(err, services.ErrNotOrganizationMember) {
+			return utils.HandleError(c, fiber.StatusForbidden, "You must belong to an organization to request a quote.", err)
+		}
+		return handleQuoteError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(quote)
+}
+
+// GetInvoiceQuote estimates the financing of an uploaded invoice from its extracted details.
+func (h *InvoiceHandler) GetInvoiceQuote(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := strconv.ParseUint(userIDStr, 10, 64)
+
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invoice ID format.", err)
+	}
+
+	// Impersonation sessions are read-only, so staff see the quote without one being recorded
+	_, impersonated := services.ImpersonatorFromClaims(claims)
+	quote, err := h.invoiceService.GetInvoiceQuoteForUser(c.Context(), uint(invoiceID), uint(userID), !impersonated)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvoiceNotFound), errors.Is(err, services.ErrInvoiceAccessDenied):
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found or access denied.", err)
+		case errors.Is(err, services.ErrInvoiceNotQuotable):
+			return utils.HandleError(c, fiber.StatusConflict, err.Error(), err)
+		case errors.Is(err, services.ErrPricingInputIncomplete):
+			return utils.HandleError(c, fiber.StatusConflict, "The invoice details have not been extracted yet.", err)
+		}
+		return handleQuoteError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(quote)
+}
+
+// RepayInvoice pays back (part of) the advance on a disbursed invoice.
+func (h *InvoiceHandler) RepayInvoice(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := strconv.ParseUint(userIDStr, 10, 64)
+
+	invoiceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invoice ID format.", err)
+	}
+	var req dtos.InvoiceRepaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body.", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	repayment, err := h.invoiceService.RepayInvoice(c.Context(), uint(invoiceID), uint(userID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvoiceNotFound), errors.Is(err, services.ErrInvoiceAccessDenied):
+			return utils.HandleError(c, fiber.StatusNotFound, "Invoice not found or access denied.", err)
+		case errors.Is(err, services.ErrRepaymentNotAllowed), errors.Is(err, services.ErrNotOrganizationMember):
+			return utils.HandleError(c, fiber.StatusForbidden, "Your organization role does not allow making repayments.", err)
+		case errors.Is(err, services.ErrInvoiceNotDisbursedForRepayment), errors.Is(err, models.ErrInvalidInvoiceTransition):
+			return utils.HandleError(c, fiber.StatusConflict, "This invoice is not awaiting repayment.", err)
+		case errors.Is(err, models.ErrRepaymentExceedsBalance), errors.Is(err, services.ErrRepaymentAmountTooSmall):
+			return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, services.ErrRepaymentDeclined):
+			return utils.HandleError(c, fiber.StatusPaymentRequired, err.Error(), err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to process repayment.", err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(repayment)
+}
+
+func handleQuoteError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrQuoteDueDatePassed), errors.Is(err, services.ErrPricingInputIncomplete):
+		return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, services.ErrNoPricingPlan), errors.Is(err, services.ErrPricingNoNetPayout):
+		return utils.HandleError(c, fiber.StatusUnprocessableEntity, "This invoice cannot be financed on our current terms.", err)
+	default:
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to prepare financing quote.", err)
+	}
+}
+
 func (h *InvoiceHandler) ViewReceipt(c *fiber.Ctx) error {
 	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
 	userIDStr := claims["user_id"].(string)
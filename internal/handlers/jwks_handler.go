@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"invoiceB2B/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JWKSHandler struct {
+	keyManager services.KeyManager // nil in legacy HS256 mode
+}
+
+func NewJWKSHandler(keyManager services.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keyManager: keyManager}
+}
+
+// GetJWKS publishes the public keys that verify our access and refresh tokens, so other
+// services (n8n, future microservices) can check tokens without holding a signing secret.
+func (h *JWKSHandler) GetJWKS(c *fiber.Ctx) error {
+	set := services.JWKSet{Keys: []services.JWK{}}
+	if h.keyManager != nil {
+		set = h.keyManager.JWKS()
+	}
+	// Short cache so verifiers pick up a rotated key quickly; they should also refetch on an unknown kid
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(set)
+}
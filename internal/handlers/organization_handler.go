@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/services"
+	"invoiceB2B/internal/utils"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type OrganizationHandler struct {
+	orgService services.OrganizationService
+	validate   *validator.Validate
+}
+
+func NewOrganizationHandler(orgService services.OrganizationService, validate *validator.Validate) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+		validate:   validate,
+	}
+}
+
+func (h *OrganizationHandler) GetOrganization(c *fiber.Ctx) error {
+	org, err := h.orgService.GetOrganization(c.Context(), organizationCaller(c))
+	if err != nil {
+		return handleOrganizationError(c, err, "Failed to retrieve organization.")
+	}
+	return c.Status(fiber.StatusOK).JSON(org)
+}
+
+func (h *OrganizationHandler) UpdateOrganization(c *fiber.Ctx) error {
+	var req dtos.UpdateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	org, err := h.orgService.UpdateOrganization(c.Context(), organizationCaller(c), req)
+	if err != nil {
+		return handleOrganizationError(c, err, "Failed to update organization.")
+	}
+	return c.Status(fiber.StatusOK).JSON(org)
+}
+
+func (h *OrganizationHandler) ListMembers(c *fiber.Ctx) error {
+	members, err := h.orgService.ListMembers(c.Context(), organizationCaller(c))
+	if err != nil {
+		return handleOrganizationError(c, err, "Failed to retrieve organization members.")
+	}
+	return c.Status(fiber.StatusOK).JSON(members)
+}
+
+func (h *OrganizationHandler) UpdateMemberRole(c *fiber.Ctx) error {
+	memberUserID, err := strconv.ParseUint(c.Params("userId"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format.", err)
+	}
+	var req dtos.UpdateMemberRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	member, err := h.orgService.UpdateMemberRole(c.Context(), organizationCaller(c), uint(memberUserID), req.Role)
+	if err != nil {
+		return handleOrganizationError(c, err, "Failed to update member role.")
+	}
+	return c.Status(fiber.StatusOK).JSON(member)
+}
+
+func (h *OrganizationHandler) RemoveMember(c *fiber.Ctx) error {
+	memberUserID, err := strconv.ParseUint(c.Params("userId"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format.", err)
+	}
+
+	if err := h.orgService.RemoveMember(c.Context(), organizationCaller(c), uint(memberUserID)); err != nil {
+		return handleOrganizationError(c, err, "Failed to remove member.")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Member removed from the organization."})
+}
+
+func (h *OrganizationHandler) InviteMember(c *fiber.Ctx) error {
+	var req dtos.InviteMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	invitation, err := h.orgService.InviteMember(c.Context(), organizationCaller(c), req)
+	if err != nil {
+		return handleOrganizationError(c, err, "Failed to invite member.")
+	}
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+func (h *OrganizationHandler) ListInvitations(c *fiber.Ctx) error {
+	invitations, err := h.orgService.ListInvitations(c.Context(), organizationCaller(c))
+	if err != nil {
+		return handleOrganizationError(c, err, "Failed to retrieve invitations.")
+	}
+	return c.Status(fiber.StatusOK).JSON(invitations)
+}
+
+func (h *OrganizationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	invitationID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid invitation ID format.", err)
+	}
+
+	if err := h.orgService.RevokeInvitation(c.Context(), organizationCaller(c), uint(invitationID)); err != nil {
+		return handleOrganizationError(c, err, "Failed to revoke invitation.")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Invitation revoked."})
+}
+
+// AcceptInvitation is public: the emailed token authorises creating the member's login.
+func (h *OrganizationHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var req dtos.AcceptInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	user, err := h.orgService.AcceptInvitation(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrWeakPassword) {
+			return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+		}
+		return handleOrganizationError(c, err, "Failed to accept invitation.")
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Invitation accepted. You can now log in.",
+		"email":   user.Email,
+	})
+}
+
+func organizationCaller(c *fiber.Ctx) uint {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userID, _ := strconv.ParseUint(claims["user_id"].(string), 10, 64)
+	return uint(userID)
+}
+
+func handleOrganizationError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrNotOrganizationMember):
+		return utils.HandleError(c, fiber.StatusForbidden, "Your account does not belong to an organization.", err)
+	case errors.Is(err, services.ErrOrganizationPermissionDenied):
+		return utils.HandleError(c, fiber.StatusForbidden, "Only organization owners can do this.", err)
+	case errors.Is(err, services.ErrMemberNotFound):
+		return utils.HandleError(c, fiber.StatusNotFound, "Member not found.", err)
+	case errors.Is(err, services.ErrInvitationNotFound):
+		return utils.HandleError(c, fiber.StatusNotFound, "Invitation not found.", err)
+	case errors.Is(err, services.ErrLastOwner):
+		return utils.HandleError(c, fiber.StatusConflict, "An organization must keep at least one owner.", err)
+	case errors.Is(err, services.ErrEmailExists):
+		return utils.HandleError(c, fiber.StatusConflict, "An account with this email already exists.", err)
+	case errors.Is(err, services.ErrInvitationAlreadyPending):
+		return utils.HandleError(c, fiber.StatusConflict, "An invitation is already pending for this email.", err)
+	case errors.Is(err, services.ErrInvitationInvalid):
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invitation is invalid or has expired.", err)
+	default:
+		return utils.HandleError(c, fiber.StatusInternalServerError, fallback, err)
+	}
+}
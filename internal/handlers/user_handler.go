@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"invoiceB2B/internal/config"
 	"invoiceB2B/internal/dtos"
 	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
@@ -12,14 +14,20 @@ import (
 )
 
 type UserHandler struct {
-	userService services.UserService
-	validate    *validator.Validate
+	userService   services.UserService
+	authService   services.AuthService
+	apiKeyService services.APIKeyService
+	validate      *validator.Validate
+	cfg           *config.Config
 }
 
-func NewUserHandler(userService services.UserService, validate *validator.Validate) *UserHandler {
+func NewUserHandler(userService services.UserService, authService services.AuthService, apiKeyService services.APIKeyService, validate *validator.Validate, cfg *config.Config) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		validate:    validate,
+		userService:   userService,
+		authService:   authService,
+		apiKeyService: apiKeyService,
+		validate:      validate,
+		cfg:           cfg,
 	}
 }
 
@@ -43,17 +51,26 @@ func (h *UserHandler) GetUserProfile(c *fiber.Ctx) error {
 		kycStatus = string(user.KYCDetail.Status)
 	}
 
+	var impersonatedBy *uint
+	if staffID, ok := services.ImpersonatorFromClaims(claims); ok {
+		impersonatedBy = &staffID
+	}
+
 	return c.Status(fiber.StatusOK).JSON(dtos.UserProfileResponse{
-		ID:           user.ID,
-		Email:        user.Email,
-		FirstName:    user.FirstName,
-		LastName:     user.LastName,
-		CompanyName:  user.CompanyName,
-		IsActive:     user.IsActive,
-		TwoFAEnabled: user.TwoFAEnabled,
-		KYCStatus:    kycStatus,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
+		ID:              user.ID,
+		Email:           user.Email,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		CompanyName:     user.CompanyName,
+		IsActive:        user.IsActive,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		TwoFAEnabled:    user.TwoFAEnabled,
+		TwoFAMethod:     user.TwoFAMethod,
+		KYCStatus:       kycStatus,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		Impersonated:    impersonatedBy != nil,
+		ImpersonatedBy:  impersonatedBy,
 	})
 }
 
@@ -85,16 +102,18 @@ func (h *UserHandler) UpdateUserProfile(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusOK).JSON(dtos.UserProfileResponse{
-		ID:           updatedUser.ID,
-		Email:        updatedUser.Email,
-		FirstName:    updatedUser.FirstName,
-		LastName:     updatedUser.LastName,
-		CompanyName:  updatedUser.CompanyName,
-		IsActive:     updatedUser.IsActive,
-		TwoFAEnabled: updatedUser.TwoFAEnabled,
-		KYCStatus:    kycStatus,
-		CreatedAt:    updatedUser.CreatedAt,
-		UpdatedAt:    updatedUser.UpdatedAt,
+		ID:              updatedUser.ID,
+		Email:           updatedUser.Email,
+		FirstName:       updatedUser.FirstName,
+		LastName:        updatedUser.LastName,
+		CompanyName:     updatedUser.CompanyName,
+		IsActive:        updatedUser.IsActive,
+		EmailVerifiedAt: updatedUser.EmailVerifiedAt,
+		TwoFAEnabled:    updatedUser.TwoFAEnabled,
+		TwoFAMethod:     updatedUser.TwoFAMethod,
+		KYCStatus:       kycStatus,
+		CreatedAt:       updatedUser.CreatedAt,
+		UpdatedAt:       updatedUser.UpdatedAt,
 	})
 }
 
@@ -117,6 +136,12 @@ func (h *UserHandler) SubmitKYC(c *fiber.Ctx) error {
 
 	kycDetail, err := h.userService.SubmitOrUpdateKYC(c.Context(), userID, req)
 	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Please verify your email address before submitting KYC.", err)
+		}
+		if errors.Is(err, services.ErrOrganizationPermissionDenied) || errors.Is(err, services.ErrNotOrganizationMember) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Only organization owners can submit KYC information.", err)
+		}
 		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to submit KYC information", err)
 	}
 
@@ -140,7 +165,10 @@ func (h *UserHandler) GetKYCStatus(c *fiber.Ctx) error {
 
 	kycDetail, err := h.userService.GetKYCStatus(c.Context(), userID)
 	if err != nil {
-		if err.Error() == "KYC record not found for this user" {
+		if errors.Is(err, services.ErrNotOrganizationMember) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Your account does not belong to an organization.", err)
+		}
+		if errors.Is(err, services.ErrKYCNotSubmitted) {
 			return c.Status(fiber.StatusOK).JSON(dtos.KYCStatusResponse{
 				UserID:  userID,
 				Status:  "Not Submitted",
@@ -159,3 +187,145 @@ func (h *UserHandler) GetKYCStatus(c *fiber.Ctx) error {
 		Message:         "KYC status retrieved successfully.",
 	})
 }
+
+func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+
+	var req dtos.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	resp, err := h.authService.ChangeUserPassword(c.Context(), userIDStr, req.CurrentPassword, req.NewPassword, clientInfo(c))
+	if err != nil {
+		return handleChangePasswordError(c, err)
+	}
+
+	return changePasswordResponse(c, h.cfg, resp)
+}
+
+// changePasswordResponse hands out the replacement token pair, as cookies in cookie mode.
+func changePasswordResponse(c *fiber.Ctx, cfg *config.Config, resp *dtos.ChangePasswordResponse) error {
+	if cfg.AuthCookieMode {
+		if err := utils.SetAuthCookies(c, cfg, resp.AccessToken, resp.AccessTokenExpiresAt, resp.RefreshToken); err != nil {
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to issue new session", err)
+		}
+		resp.AccessToken, resp.RefreshToken = "", ""
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// handleChangePasswordError maps password change failures for both the user and admin endpoints.
+func handleChangePasswordError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrCurrentPasswordWrong):
+		return utils.HandleError(c, fiber.StatusUnauthorized, "Current password is incorrect", err)
+	case errors.Is(err, services.ErrPasswordUnchanged), errors.Is(err, services.ErrWeakPassword):
+		return utils.HandleError(c, fiber.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, services.ErrUserNotFound):
+		return utils.HandleError(c, fiber.StatusNotFound, "Account not found", err)
+	}
+	return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to change password", err)
+}
+
+func (h *UserHandler) ListSessions(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format in token", err)
+	}
+	currentSessionID, _ := c.Locals("session_id").(string)
+
+	sessions, err := h.authService.ListSessions(c.Context(), services.PrincipalTypeUser, uint(parsedUserID), currentSessionID)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve sessions", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(sessions)
+}
+
+func (h *UserHandler) RevokeSession(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format in token", err)
+	}
+
+	if err := h.authService.RevokeSession(c.Context(), services.PrincipalTypeUser, uint(parsedUserID), c.Params("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "Session not found", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to revoke session", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session signed out successfully."})
+}
+
+func (h *UserHandler) ListAPIKeys(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format in token", err)
+	}
+
+	keys, err := h.apiKeyService.ListKeys(c.Context(), uint(parsedUserID))
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to retrieve API keys", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(keys)
+}
+
+// CreateAPIKey issues a personal API key. The full key is in this response only.
+func (h *UserHandler) CreateAPIKey(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format in token", err)
+	}
+
+	var req dtos.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid request body", err)
+	}
+	if errs := h.validate.Struct(req); errs != nil {
+		return utils.HandleValidationError(c, errs)
+	}
+
+	key, err := h.apiKeyService.CreateKey(c.Context(), uint(parsedUserID), req)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyLimitReached) {
+			return utils.HandleError(c, fiber.StatusConflict, "You have reached the maximum number of API keys. Revoke one first.", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to create API key", err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+func (h *UserHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid user ID format in token", err)
+	}
+	keyID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.HandleError(c, fiber.StatusBadRequest, "Invalid API key ID format", err)
+	}
+
+	if err := h.apiKeyService.RevokeKey(c.Context(), uint(parsedUserID), uint(keyID)); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			return utils.HandleError(c, fiber.StatusNotFound, "API key not found", err)
+		}
+		return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to revoke API key", err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "API key revoked."})
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"invoiceB2B/internal/config"
 	"invoiceB2B/internal/models"
 	"invoiceB2B/internal/repositories" // To fetch staff role
+	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
 	"strconv"
 
@@ -11,14 +13,17 @@ import (
 )
 
 type AdminMiddleware struct {
-	staffRepo repositories.StaffRepository
+	staffRepo         repositories.StaffRepository
+	permissionService services.PermissionService
+	cfg               *config.Config
 }
 
-func NewAdminMiddleware(staffRepo repositories.StaffRepository) *AdminMiddleware {
-	return &AdminMiddleware{staffRepo: staffRepo}
+func NewAdminMiddleware(staffRepo repositories.StaffRepository, permissionService services.PermissionService, cfg *config.Config) *AdminMiddleware {
+	return &AdminMiddleware{staffRepo: staffRepo, permissionService: permissionService, cfg: cfg}
 }
 
-// AdminRequired checks if the authenticated user is an admin.
+// AdminRequired checks if the authenticated user is an active staff member and loads the
+// permissions of their role for RequirePermission.
 // It assumes that the general AuthMiddleware.Protected() has already run
 // and validated the JWT, placing user claims in c.Locals("user").
 // It also assumes that admin users are stored in the 'staff' table.
@@ -39,6 +44,11 @@ func (am *AdminMiddleware) AdminRequired() fiber.Handler {
 		// For this example, we assume the user_id in the JWT for an admin corresponds to an ID in the 'staff' table.
 		// A more robust way is to have a 'role' claim in the JWT.
 
+		// Staff and user IDs come from different tables; only staff tokens may be looked up here.
+		if services.PrincipalTypeFromClaims(claims) != services.PrincipalTypeStaff {
+			return utils.HandleError(c, fiber.StatusForbidden, "Access denied. Admin privileges required.", nil)
+		}
+
 		userIDStr, ok := claims["user_id"].(string)
 		if !ok {
 			return utils.HandleError(c, fiber.StatusForbidden, "User ID not found in token claims.", nil)
@@ -56,29 +66,40 @@ func (am *AdminMiddleware) AdminRequired() fiber.Handler {
 			return utils.HandleError(c, fiber.StatusForbidden, "Access denied. Admin privileges required.", nil)
 		}
 
-		// Check the role (e.g., staff.Role == "admin")
-		// For simplicity, any entry in the staff table is considered having some level of admin access.
-		// Refine this with specific role checks (e.g., models.RoleAdmin)
-		if staff.Role != models.RoleAdmin && staff.Role != models.RoleFinanceManager && staff.Role != models.RoleKYCReviewer { // Example roles
-			// This check depends on how you define admin roles.
-			// If any staff is admin, this check might be simpler.
-			// If specific roles are needed for specific admin routes, this middleware might need to be more granular
-			// or you'd have multiple admin middlewares.
-			// For now, let's assume certain roles are "admin-level".
-			// A common approach is to have a general "admin" role.
-			// If the JWT itself contained a "role": "admin" claim, this DB lookup could be skipped.
-			// For now, we check if the staff member has a role that grants admin access.
-			// This is a placeholder for more specific role checking.
-			// For this example, we'll just check if the role is "admin".
-			if staff.Role != models.RoleAdmin {
-				return utils.HandleError(c, fiber.StatusForbidden, "Access denied. Insufficient admin privileges.", nil)
-			}
+		if !models.IsStaffRole(staff.Role) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Access denied. Insufficient admin privileges.", nil)
+		}
+
+		// Tokens from a login without a verified second factor are refused while the 2FA policy
+		// covers the role, including sessions that predate the policy
+		if services.Staff2FARequired(am.cfg, staff.Role) && !services.MFAVerifiedFromClaims(claims) {
+			return utils.HandleError(c, fiber.StatusForbidden, "Two-factor authentication is required for your role. Please log in again.", nil)
 		}
 
 		// Store staff details in context if needed by admin handlers
 		c.Locals("staff_id", staff.ID)
 		c.Locals("staff_role", staff.Role)
 
+		// Permissions are looked up on every request so that role edits apply immediately
+		permissions, err := am.permissionService.PermissionsForRole(c.Context(), staff.Role)
+		if err != nil {
+			return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to load staff permissions.", err)
+		}
+		c.Locals(utils.StaffPermissionsLocal, permissions)
+
+		return c.Next()
+	}
+}
+
+// RequirePermission allows the request only if the staff member's role holds every listed
+// permission. It must run after AdminRequired.
+func (am *AdminMiddleware) RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, permission := range permissions {
+			if !utils.HasStaffPermission(c, permission) {
+				return utils.HandleError(c, fiber.StatusForbidden, "Access denied. Missing permission: "+permission, nil)
+			}
+		}
 		return c.Next()
 	}
 }
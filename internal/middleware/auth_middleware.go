@@ -1,48 +1,72 @@
 package middleware
 
 import (
+	"errors"
+	"invoiceB2B/internal/models"
 	"invoiceB2B/internal/services"
 	"invoiceB2B/internal/utils"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// APIKeyHeader carries a personal API key on the routes that accept one.
+const APIKeyHeader = "X-API-Key"
+
 type AuthMiddleware struct {
-	jwtService services.JWTService
+	jwtService     services.JWTService
+	otpService     services.OTPService
+	sessionService services.SessionService
+	apiKeyService  services.APIKeyService
 }
 
-func NewAuthMiddleware(jwtService services.JWTService) *AuthMiddleware {
-	return &AuthMiddleware{jwtService: jwtService}
+func NewAuthMiddleware(jwtService services.JWTService, otpService services.OTPService, sessionService services.SessionService, apiKeyService services.APIKeyService) *AuthMiddleware {
+	return &AuthMiddleware{jwtService: jwtService, otpService: otpService, sessionService: sessionService, apiKeyService: apiKeyService}
 }
 
 func (am *AuthMiddleware) Protected() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
+		var tokenStr string
+		if authHeader := c.Get("Authorization"); authHeader != "" {
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				return utils.HandleError(c, fiber.StatusUnauthorized, "Invalid Authorization Header format", nil)
+			}
+			tokenStr = parts[1]
+		} else if cookieToken := c.Cookies(utils.AccessTokenCookie); cookieToken != "" {
+			// Browsers attach cookies automatically, so cookie-authenticated writes need the CSRF header
+			if !utils.ValidCSRFToken(c) {
+				return utils.HandleError(c, fiber.StatusForbidden, "Missing or invalid CSRF token", nil)
+			}
+			tokenStr = cookieToken
+		} else {
 			return utils.HandleError(c, fiber.StatusUnauthorized, "Missing Authorization Header", nil)
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			return utils.HandleError(c, fiber.StatusUnauthorized, "Invalid Authorization Header format", nil)
-		}
-
-		tokenStr := parts[1]
 		claims, err := am.jwtService.ValidateToken(tokenStr, false) // false for access token
 		if err != nil {
 			return utils.HandleError(c, fiber.StatusUnauthorized, "Invalid or expired token", err)
 		}
 
-		// Check if token is blacklisted (for logout)
-		// This requires otpService or a dedicated blacklist service.
-		// For simplicity, let's assume otpService has IsTokenBlacklisted.
-		// This dependency might need to be passed to AuthMiddleware or handled differently.
-		// isBlacklisted, _ := am.otpService.IsTokenBlacklisted(c.Context(), tokenStr)
-		// if isBlacklisted {
-		// 	return utils.HandleError(c, fiber.StatusUnauthorized, "Token has been invalidated", nil)
-		// }
+		// Reject tokens invalidated by logout, or issued before a password reset/change
+		isBlacklisted, _ := am.otpService.IsTokenBlacklisted(c.Context(), tokenStr)
+		if isBlacklisted {
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Token has been invalidated", nil)
+		}
+		if revoked, _ := am.otpService.IsTokenRevoked(c.Context(), claims); revoked {
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Session has been revoked. Please log in again.", nil)
+		}
+
+		// The session must still exist in the registry; signing a session out remotely ends it here
+		sessionID, _ := claims["sid"].(string)
+		if sessionID == "" {
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Token is not bound to a session. Please log in again.", nil)
+		}
+		if active, err := am.sessionService.IsActive(c.Context(), sessionID); err == nil && !active {
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Session has been revoked. Please log in again.", nil)
+		}
 
 		// Store claims in context for handlers to use
 		// Convert claims (jwt.MapClaims) to a more usable struct if needed, or pass as is.
@@ -60,9 +84,91 @@ func (am *AuthMiddleware) Protected() fiber.Handler {
 		tokenWithClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims) // Recreate a token object with validated claims
 		tokenWithClaims.Raw = tokenStr                                       // Store the raw token string as well if needed later (e.g. for blacklist)
 
+		principalType := services.PrincipalTypeFromClaims(claims)
+		if principalType == "" {
+			return utils.HandleError(c, fiber.StatusUnauthorized, "Token is missing a valid principal type. Please log in again.", nil)
+		}
+
+		// Impersonation tokens let staff look at a customer's account but never change it
+		if _, impersonated := services.ImpersonatorFromClaims(claims); impersonated && !isReadOnlyMethod(c.Method()) {
+			return utils.HandleError(c, fiber.StatusForbidden, "This is a read-only impersonation session.", nil)
+		}
+
 		c.Locals("user", tokenWithClaims) // Store the validated token object (which includes claims)
+		c.Locals("principal_type", principalType)
+		c.Locals("session_id", sessionID)
 		// c.Locals("user_id", claims["user_id"]) // Example of storing specific claim
 
 		return c.Next()
 	}
 }
+
+// ProtectedOrAPIKey accepts a personal API key in the X-API-Key header as an alternative to a
+// JWT. Key requests are authenticated as the key's owner with the same locals Protected()
+// sets, plus "api_key"; RequireAPIKeyScope then limits what they may do.
+func (am *AuthMiddleware) ProtectedOrAPIKey() fiber.Handler {
+	protected := am.Protected()
+	return func(c *fiber.Ctx) error {
+		rawKey := c.Get(APIKeyHeader)
+		if rawKey == "" {
+			return protected(c)
+		}
+
+		key, err := am.apiKeyService.Authenticate(c.Context(), rawKey, c.IP())
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrAPIKeyInvalid):
+				return utils.HandleError(c, fiber.StatusUnauthorized, "Invalid, expired or revoked API key", nil)
+			case errors.Is(err, services.ErrAccountNotActive):
+				return utils.HandleError(c, fiber.StatusForbidden, "Account is not active", nil)
+			default:
+				return utils.HandleError(c, fiber.StatusInternalServerError, "Failed to verify API key", err)
+			}
+		}
+
+		// Handlers read the caller from token claims, so present the key owner the same way
+		claims := jwt.MapClaims{
+			"user_id":        strconv.FormatUint(uint64(key.UserID), 10),
+			"principal_type": services.PrincipalTypeUser,
+			"email":          key.User.Email,
+			"role":           "user",
+			"api_key_id":     strconv.FormatUint(uint64(key.ID), 10),
+		}
+		c.Locals("user", jwt.NewWithClaims(jwt.SigningMethodHS256, claims))
+		c.Locals("principal_type", services.PrincipalTypeUser)
+		c.Locals("api_key", key)
+
+		return c.Next()
+	}
+}
+
+// RequireAPIKeyScope rejects API key requests whose key was not granted scope. Requests
+// authenticated with a JWT pass unchanged.
+func (am *AuthMiddleware) RequireAPIKeyScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if key, ok := c.Locals("api_key").(*models.APIKey); ok && !key.HasScope(scope) {
+			return utils.HandleError(c, fiber.StatusForbidden, "API key is missing the "+scope+" scope", nil)
+		}
+		return c.Next()
+	}
+}
+
+// RequirePrincipal rejects tokens issued to a different kind of account, so that a user
+// token can never be used on staff routes (or vice versa) just because the numeric IDs match.
+// It must run after Protected().
+func (am *AuthMiddleware) RequirePrincipal(principalType string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if actual, _ := c.Locals("principal_type").(string); actual != principalType {
+			return utils.HandleError(c, fiber.StatusForbidden, "This token cannot be used for this resource.", nil)
+		}
+		return c.Next()
+	}
+}
+
+func isReadOnlyMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	}
+	return false
+}
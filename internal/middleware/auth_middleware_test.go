@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/config"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/repositories"
+	"invoiceB2B/internal/services"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// collidingID is both a customer's user ID and a staff member's ID, which is the case
+// principal types exist for.
+const collidingID = 7
+
+const (
+	userToken  = "user-token"
+	staffToken = "staff-token"
+)
+
+// The stubs embed the service interfaces and implement only what the middleware calls.
+
+type stubJWTService struct{ services.JWTService }
+
+func (stubJWTService) ValidateToken(tokenString string, isRefreshToken bool) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{"user_id": "7", "sid": "session-" + tokenString, "mfa": true}
+	switch tokenString {
+	case userToken:
+		claims["principal_type"] = services.PrincipalTypeUser
+	case staffToken:
+		claims["principal_type"] = services.PrincipalTypeStaff
+	default:
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+type stubOTPService struct{ services.OTPService }
+
+func (stubOTPService) IsTokenBlacklisted(ctx context.Context, tokenStr string) (bool, error) {
+	return false, nil
+}
+
+func (stubOTPService) IsTokenRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	return false, nil
+}
+
+type stubSessionService struct{ services.SessionService }
+
+func (stubSessionService) IsActive(ctx context.Context, familyID string) (bool, error) {
+	return true, nil
+}
+
+type stubStaffRepository struct{ repositories.StaffRepository }
+
+func (stubStaffRepository) FindByID(ctx context.Context, id uint) (*models.Staff, error) {
+	if id != collidingID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	staff := &models.Staff{Role: models.RoleAdmin, IsActive: true}
+	staff.ID = id
+	return staff, nil
+}
+
+type stubPermissionService struct{ services.PermissionService }
+
+func (stubPermissionService) PermissionsForRole(ctx context.Context, role string) (map[string]bool, error) {
+	return map[string]bool{models.PermInvoiceRead: true}, nil
+}
+
+// newTestApp mounts the middleware chains of the admin, user and invoice route groups in
+// front of handlers that only report success.
+func newTestApp(withPrincipalCheck bool) *fiber.App {
+	authMw := NewAuthMiddleware(stubJWTService{}, stubOTPService{}, stubSessionService{}, nil)
+	adminMw := NewAdminMiddleware(stubStaffRepository{}, stubPermissionService{}, &config.Config{})
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	app := fiber.New()
+	adminGroup := app.Group("/admin", authMw.Protected())
+	if withPrincipalCheck {
+		adminGroup.Use(authMw.RequirePrincipal(services.PrincipalTypeStaff))
+	}
+	adminGroup.Use(adminMw.AdminRequired())
+	adminGroup.Get("/invoices", adminMw.RequirePermission(models.PermInvoiceRead), ok)
+
+	userGroup := app.Group("/user", authMw.Protected(), authMw.RequirePrincipal(services.PrincipalTypeUser))
+	userGroup.Get("/profile", ok)
+
+	invoiceGroup := app.Group("/invoices", authMw.ProtectedOrAPIKey(), authMw.RequirePrincipal(services.PrincipalTypeUser))
+	invoiceGroup.Get("", ok)
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App, path, token string) int {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", path, err)
+	}
+	return resp.StatusCode
+}
+
+func TestPrincipalTypesDoNotCrossRouteGroups(t *testing.T) {
+	app := newTestApp(true)
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"user token with a staff ID on admin routes", "/admin/invoices", userToken, fiber.StatusForbidden},
+		{"staff token on user routes", "/user/profile", staffToken, fiber.StatusForbidden},
+		{"staff token on invoice routes", "/invoices", staffToken, fiber.StatusForbidden},
+		{"staff token on admin routes", "/admin/invoices", staffToken, fiber.StatusOK},
+		{"user token on user routes", "/user/profile", userToken, fiber.StatusOK},
+		{"user token on invoice routes", "/invoices", userToken, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(t, app, tt.path, tt.token); got != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// AdminRequired must refuse a user token on its own, even where a route group forgets
+// RequirePrincipal.
+func TestAdminRequiredRejectsUserTokenWithStaffID(t *testing.T) {
+	app := newTestApp(false)
+	if got := doRequest(t, app, "/admin/invoices", userToken); got != fiber.StatusForbidden {
+		t.Errorf("GET /admin/invoices with a user token = %d, want %d", got, fiber.StatusForbidden)
+	}
+}
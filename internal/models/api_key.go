@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes that can be granted to an API key.
+const (
+	APIKeyScopeInvoicesRead  string = "invoices:read"
+	APIKeyScopeInvoicesWrite string = "invoices:write"
+	// Repayments charge the customer's payment method, so they are never implied by invoices:write
+	APIKeyScopeRepaymentsWrite string = "repayments:write"
+)
+
+// APIKey lets a user call the invoice API from their own systems, e.g. an ERP. Only the
+// SHA-256 hash of the key is stored; Prefix is kept in clear so that keys can be told apart.
+// Revoking a key soft-deletes it.
+type APIKey struct {
+	gorm.Model
+	UserID     uint       `gorm:"not null;index"`
+	User       User       `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Name       string     `gorm:"type:varchar(100);not null"`
+	Prefix     string     `gorm:"type:varchar(20);not null"`
+	KeyHash    string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	Scopes     string     `gorm:"type:varchar(255);not null"` // Comma-separated
+	ExpiresAt  *time.Time `gorm:"null"`
+	LastUsedAt *time.Time `gorm:"null"`
+	LastUsedIP string     `gorm:"type:varchar(45);null"`
+}
+
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.ScopeList() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
@@ -1,8 +1,13 @@
 package models
 
 import (
-	"gorm.io/gorm"
+	"errors"
+	"fmt"
+	"invoiceB2B/internal/money"
 	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type InvoiceStatus string
@@ -18,10 +23,75 @@ const (
 	InvoicePendingApproval  InvoiceStatus = "pending_admin_review"
 )
 
+var ErrInvalidInvoiceTransition = errors.New("invalid invoice status transition")
+
+// ErrRepaymentExceedsBalance is returned for a repayment larger than what is still owed.
+var ErrRepaymentExceedsBalance = errors.New("repayment exceeds the outstanding balance")
+
+// invoiceTransitions is the invoice state machine: the statuses each status may move to.
+// Rejected and repaid invoices are final. The invoice repository enforces this table on every
+// status change.
+var invoiceTransitions = map[InvoiceStatus][]InvoiceStatus{
+	InvoicePendingReview:    {InvoicePendingApproval, InvoiceProcessingFailed, InvoiceApproved, InvoiceRejected},
+	InvoiceProcessingFailed: {InvoicePendingReview, InvoicePendingApproval, InvoiceRejected},
+	InvoicePendingApproval:  {InvoiceApproved, InvoiceRejected, InvoiceProcessingFailed},
+	InvoiceApproved:         {InvoiceDisbursed, InvoiceRejected},
+	InvoiceDisbursed:        {InvoiceRepaymentPending, InvoiceRepaid},
+	InvoiceRepaymentPending: {InvoiceRepaid},
+}
+
+// CanTransitionTo reports whether an invoice in status s may move to next.
+func (s InvoiceStatus) CanTransitionTo(next InvoiceStatus) bool {
+	for _, allowed := range invoiceTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsProcessedData reports whether extracted invoice data (amount, currency, due date, ...)
+// may still be changed. Once an invoice has been approved, priced or rejected its terms are
+// fixed.
+func (s InvoiceStatus) AcceptsProcessedData() bool {
+	return s == InvoicePendingReview || s == InvoiceProcessingFailed || s == InvoicePendingApproval
+}
+
+// ValidateInvoiceTransition returns an error wrapping ErrInvalidInvoiceTransition if an invoice
+// may not move from one status to the other.
+func ValidateInvoiceTransition(from, to InvoiceStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidInvoiceTransition, from, to)
+	}
+	return nil
+}
+
+// Actors recorded against invoice status changes.
+const (
+	InvoiceActorUser   string = "user"
+	InvoiceActorStaff  string = "staff"
+	InvoiceActorSystem string = "system" // The n8n processing pipeline
+)
+
+// InvoiceStatusHistory records one status change of an invoice. The entry written on upload
+// has an empty FromStatus.
+type InvoiceStatusHistory struct {
+	gorm.Model
+	InvoiceID  uint          `gorm:"not null;index"`
+	Invoice    Invoice       `gorm:"foreignKey:InvoiceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FromStatus InvoiceStatus `gorm:"type:varchar(30);null"`
+	ToStatus   InvoiceStatus `gorm:"type:varchar(30);not null"`
+	ActorType  string        `gorm:"type:varchar(10);not null"`
+	ActorID    *uint         `gorm:"null"` // Nil for system changes
+	Reason     *string       `gorm:"type:text;null"`
+}
+
 type Invoice struct {
 	gorm.Model
-	UserID uint `gorm:"not null;index"`
-	User   User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID         uint          `gorm:"not null;index"` // Member who uploaded the invoice
+	User           User          `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	OrganizationID *uint         `gorm:"null;index"`
+	Organization   *Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 
 	InvoiceNumber     string `gorm:"type:varchar(100);null"`
 	IssuerName        string `gorm:"type:varchar(255);null"`
@@ -29,9 +99,9 @@ type Invoice struct {
 	IssuerBankName    string `gorm:"type:varchar(100);null"`
 	DebtorName        string `gorm:"type:varchar(255);null"`
 
-	Amount   float64    `gorm:"type:decimal(18,2);null"`
-	Currency string     `gorm:"type:varchar(3);null"`
-	DueDate  *time.Time `gorm:"null"`
+	Amount   money.Amount `gorm:"type:decimal(20,4);null"` // Rounded to the currency's minor unit
+	Currency string       `gorm:"type:varchar(3);null"`
+	DueDate  *time.Time   `gorm:"null"`
 
 	Status           InvoiceStatus `gorm:"type:varchar(30);default:'pending_review';not null"`
 	OriginalFilePath string        `gorm:"type:varchar(500);null"`
@@ -47,14 +117,23 @@ type Invoice struct {
 	DisbursedBy   *Staff `gorm:"foreignKey:DisbursedByID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	DisbursedAt   *time.Time
 
-	FinancingFeePercentage  *float64 `gorm:"type:decimal(5,2);null"`
-	FinancedAmount          *float64 `gorm:"type:decimal(18,2);null"`
-	DisbursementReceiptPath *string  `gorm:"type:varchar(500);null"`
-	ProcessingError         *string  `gorm:"type:text;null"`
+	FinancingFeePercentage  *decimal.Decimal `gorm:"type:decimal(5,2);null"`
+	FinancedAmount          *money.Amount    `gorm:"type:decimal(20,4);null"`
+	Pricing                 PricingBreakdown `gorm:"embedded;embeddedPrefix:pricing_"` // Terms applied at disbursement
+	DisbursementReceiptPath *string          `gorm:"type:varchar(500);null"`
+	ProcessingError         *string          `gorm:"type:text;null"`
 
 	Transactions []Transaction `gorm:"foreignKey:InvoiceID"`
 }
 
+// RepaymentDue is what the customer repays on a disbursed invoice: the amount advanced to them.
+func (i *Invoice) RepaymentDue() money.Amount {
+	if i.FinancedAmount == nil {
+		return money.Zero
+	}
+	return i.FinancedAmount.Round(i.Currency)
+}
+
 type TransactionType string
 
 const (
@@ -62,13 +141,83 @@ const (
 	TransactionRepayment    TransactionType = "repayment"
 )
 
+// TransactionStatus tracks a payment through the provider. A repayment is written as pending
+// before the customer is charged, so that it already counts against the outstanding balance,
+// and is then completed or voided depending on the outcome.
+type TransactionStatus string
+
+const (
+	TransactionPending   TransactionStatus = "pending"
+	TransactionCompleted TransactionStatus = "completed"
+	TransactionVoided    TransactionStatus = "voided"
+)
+
 type Transaction struct {
 	gorm.Model
 	InvoiceID uint    `gorm:"not null;index"`
 	Invoice   Invoice `gorm:"foreignKey:InvoiceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 
-	Type            TransactionType `gorm:"type:varchar(20);not null"`
-	Amount          float64         `gorm:"type:decimal(18,2);not null"`
-	TransactionDate time.Time       `gorm:"not null"`
-	ReferenceID     *string         `gorm:"type:varchar(100);null"`
+	Type            TransactionType   `gorm:"type:varchar(20);not null"`
+	Status          TransactionStatus `gorm:"type:varchar(20);not null;default:'completed'"`
+	Amount          money.Amount      `gorm:"type:decimal(20,4);not null"`
+	TransactionDate time.Time         `gorm:"not null"`
+	ReferenceID     *string           `gorm:"type:varchar(100);null"`
+}
+
+type DisbursementApprovalStatus string
+
+const (
+	DisbursementPending  DisbursementApprovalStatus = "pending"
+	DisbursementApproved DisbursementApprovalStatus = "approved"
+	DisbursementRejected DisbursementApprovalStatus = "rejected"
+)
+
+// DisbursementApproval is a disbursement proposed by one staff member (the maker). Nothing is
+// paid out until a different staff member (the checker) approves it; only then is the
+// disbursement Transaction written. An invoice can have at most one pending request.
+type DisbursementApproval struct {
+	gorm.Model
+	InvoiceID uint    `gorm:"not null;index;uniqueIndex:idx_invoice_pending_disbursement,where:status = 'pending'"`
+	Invoice   Invoice `gorm:"foreignKey:InvoiceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	FinancedAmount         money.Amount               `gorm:"type:decimal(20,4);not null"`
+	FinancingFeePercentage decimal.Decimal            `gorm:"type:decimal(5,2);not null"`
+	Pricing                PricingBreakdown           `gorm:"embedded;embeddedPrefix:pricing_"`
+	Status                 DisbursementApprovalStatus `gorm:"type:varchar(20);default:'pending';not null"`
+
+	RequestedByID   uint       `gorm:"not null"`
+	RequestedBy     Staff      `gorm:"foreignKey:RequestedByID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ReviewedByID    *uint      `gorm:"null"`
+	ReviewedBy      *Staff     `gorm:"foreignKey:ReviewedByID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ReviewedAt      *time.Time `gorm:"null"`
+	RejectionReason *string    `gorm:"type:text;null"`
+	TransactionID   *uint      `gorm:"null"`
+}
+
+type EscalationAction string
+
+const (
+	EscalationApprove  EscalationAction = "approve"
+	EscalationDisburse EscalationAction = "disburse"
+)
+
+// InvoiceEscalation queues an invoice for a staff member with a higher approval limit after
+// someone tried to approve or disburse an amount above their role's ApprovalLimit. It is
+// resolved once the action is approved or rejected by anyone.
+type InvoiceEscalation struct {
+	gorm.Model
+	InvoiceID uint    `gorm:"not null;index"`
+	Invoice   Invoice `gorm:"foreignKey:InvoiceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	Action      EscalationAction `gorm:"type:varchar(20);not null"`
+	Amount      money.Amount     `gorm:"type:decimal(20,4);not null"`
+	Currency    string           `gorm:"type:varchar(3);not null"`
+	Role        string           `gorm:"type:varchar(50);not null"` // Role whose limit was exceeded
+	LimitAmount money.Amount     `gorm:"type:decimal(20,4);not null"`
+
+	EscalatedByID          uint       `gorm:"not null"`
+	EscalatedBy            Staff      `gorm:"foreignKey:EscalatedByID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	DisbursementApprovalID *uint      `gorm:"null"`
+	ResolvedByID           *uint      `gorm:"null"`
+	ResolvedAt             *time.Time `gorm:"null"`
 }
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+var allInvoiceStatuses = []InvoiceStatus{
+	InvoicePendingReview,
+	InvoiceProcessingFailed,
+	InvoicePendingApproval,
+	InvoiceApproved,
+	InvoiceRejected,
+	InvoiceDisbursed,
+	InvoiceRepaymentPending,
+	InvoiceRepaid,
+}
+
+func TestValidateInvoiceTransition(t *testing.T) {
+	tests := []struct {
+		from, to InvoiceStatus
+		allowed  bool
+	}{
+		{InvoicePendingReview, InvoicePendingApproval, true},
+		{InvoicePendingReview, InvoiceProcessingFailed, true},
+		{InvoicePendingReview, InvoiceApproved, true},
+		{InvoicePendingReview, InvoiceRejected, true},
+		{InvoicePendingReview, InvoiceDisbursed, false},
+		{InvoicePendingReview, InvoiceRepaid, false},
+		{InvoiceProcessingFailed, InvoicePendingReview, true},
+		{InvoiceProcessingFailed, InvoiceApproved, false},
+		{InvoicePendingApproval, InvoiceApproved, true},
+		{InvoicePendingApproval, InvoiceDisbursed, false},
+		{InvoiceApproved, InvoiceDisbursed, true},
+		{InvoiceApproved, InvoiceRejected, true},
+		{InvoiceApproved, InvoiceRepaid, false},
+		{InvoiceApproved, InvoicePendingReview, false},
+		{InvoiceDisbursed, InvoiceRepaymentPending, true},
+		{InvoiceDisbursed, InvoiceRepaid, true},
+		{InvoiceDisbursed, InvoiceRejected, false},
+		{InvoiceRepaymentPending, InvoiceRepaid, true},
+		{InvoiceRepaymentPending, InvoiceDisbursed, false},
+		{InvoiceApproved, InvoiceApproved, false},
+		{InvoiceStatus("unknown"), InvoiceApproved, false},
+	}
+	for _, tt := range tests {
+		err := ValidateInvoiceTransition(tt.from, tt.to)
+		if tt.allowed && err != nil {
+			t.Errorf("%s -> %s refused: %v", tt.from, tt.to, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrInvalidInvoiceTransition) {
+			t.Errorf("%s -> %s = %v, want ErrInvalidInvoiceTransition", tt.from, tt.to, err)
+		}
+	}
+}
+
+func TestRejectedAndRepaidInvoicesAreTerminal(t *testing.T) {
+	for _, from := range []InvoiceStatus{InvoiceRejected, InvoiceRepaid} {
+		for _, to := range allInvoiceStatuses {
+			if err := ValidateInvoiceTransition(from, to); !errors.Is(err, ErrInvalidInvoiceTransition) {
+				t.Errorf("%s -> %s = %v, want ErrInvalidInvoiceTransition", from, to, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Organisation member roles. Owners manage the organisation, its members, KYC and bank
+// details; uploaders can submit invoices; viewers have read-only access.
+const (
+	OrgRoleOwner    string = "owner"
+	OrgRoleUploader string = "uploader"
+	OrgRoleViewer   string = "viewer"
+)
+
+// IsOrgRole reports whether role is one of the organisation roles above.
+func IsOrgRole(role string) bool {
+	switch role {
+	case OrgRoleOwner, OrgRoleUploader, OrgRoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanUploadInvoices reports whether members with the given role may submit invoices.
+func CanUploadInvoices(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleUploader
+}
+
+// Organization is the customer company. It owns KYC, invoices and the bank details that
+// financing is paid out to; individual logins (users) belong to it as members.
+type Organization struct {
+	gorm.Model
+	Name     string `gorm:"type:varchar(100);not null"`
+	RiskTier string `gorm:"type:varchar(10);default:'medium';not null"` // Set by staff; drives invoice pricing
+
+	BankAccountName   string `gorm:"type:varchar(255);null"`
+	BankAccountNumber string `gorm:"type:varchar(100);null"`
+	BankName          string `gorm:"type:varchar(100);null"`
+
+	Members []OrganizationMember `gorm:"foreignKey:OrganizationID"`
+}
+
+// OrganizationMember links a user to the organisation they act for. A user belongs to
+// exactly one organisation.
+type OrganizationMember struct {
+	gorm.Model
+	OrganizationID uint         `gorm:"not null;index"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	UserID         uint         `gorm:"not null;uniqueIndex"`
+	User           User         `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Role           string       `gorm:"type:varchar(20);not null"`
+}
+
+// OrganizationInvitation is a pending invite for a new member. Only the SHA-256 hash of the
+// emailed token is stored.
+type OrganizationInvitation struct {
+	gorm.Model
+	OrganizationID uint         `gorm:"not null;index"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Email          string       `gorm:"type:varchar(100);not null;index"`
+	Role           string       `gorm:"type:varchar(20);not null"`
+	TokenHash      string       `gorm:"type:varchar(64);not null;uniqueIndex"`
+	InvitedByID    uint         `gorm:"not null"`
+	ExpiresAt      time.Time    `gorm:"not null"`
+	AcceptedAt     *time.Time   `gorm:"null"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"invoiceB2B/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// Staff permissions. Each admin route requires one of these; which roles hold them is stored
+// in RolePermission and can be changed by admins at runtime.
+const (
+	PermUserRead        string = "user:read"
+	PermUserManage      string = "user:manage"
+	PermUserImpersonate string = "user:impersonate"
+	PermKYCReview       string = "kyc:review"
+	PermInvoiceRead     string = "invoice:read"
+	PermInvoiceApprove  string = "invoice:approve"
+	PermInvoiceDisburse string = "invoice:disburse"
+	PermStaffManage     string = "staff:manage"
+	PermRolesManage     string = "roles:manage"
+	PermLogsRead        string = "logs:read"
+	PermAnalyticsRead   string = "analytics:read"
+	PermPricingManage   string = "pricing:manage"
+)
+
+// Permission describes an entry of the permission catalogue.
+type Permission struct {
+	Key         string
+	Description string
+}
+
+// PermissionCatalogue lists every permission that can be granted to a staff role.
+var PermissionCatalogue = []Permission{
+	{PermUserRead, "View customers and their KYC details"},
+	{PermUserManage, "Sign customers out and lift login lockouts"},
+	{PermUserImpersonate, "View the dashboard as a customer in a read-only session"},
+	{PermKYCReview, "Approve or reject KYC submissions"},
+	{PermInvoiceRead, "View invoices and download invoice PDFs"},
+	{PermInvoiceApprove, "Approve or reject invoices"},
+	{PermInvoiceDisburse, "Disburse financing, upload receipts and confirm repayments"},
+	{PermStaffManage, "Invite, edit and remove staff members"},
+	{PermRolesManage, "Change the permissions and approval limits of staff roles"},
+	{PermLogsRead, "Read activity logs"},
+	{PermAnalyticsRead, "View dashboard analytics"},
+	{PermPricingManage, "Manage pricing plans and customer and debtor risk tiers"},
+}
+
+// IsPermission reports whether key is in the permission catalogue.
+func IsPermission(key string) bool {
+	for _, permission := range PermissionCatalogue {
+		if permission.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRolePermissions is seeded into an empty role_permissions table.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermUserRead, PermUserManage, PermUserImpersonate, PermKYCReview, PermInvoiceRead, PermInvoiceApprove,
+		PermInvoiceDisburse, PermStaffManage, PermRolesManage, PermLogsRead, PermAnalyticsRead, PermPricingManage,
+	},
+	RoleKYCReviewer:    {PermUserRead, PermKYCReview, PermInvoiceRead},
+	RoleFinanceManager: {PermUserRead, PermInvoiceRead, PermInvoiceApprove, PermInvoiceDisburse, PermAnalyticsRead, PermPricingManage},
+}
+
+// RolePermission grants a permission to every staff member with the role.
+type RolePermission struct {
+	gorm.Model
+	Role       string `gorm:"type:varchar(50);not null;uniqueIndex:idx_role_permission"`
+	Permission string `gorm:"type:varchar(50);not null;uniqueIndex:idx_role_permission"`
+}
+
+// ApprovalLimit caps the amount a staff role may sign off in one currency: the invoice amount
+// when approving an invoice and the financed amount when approving a disbursement. A role
+// without a limit for a currency is not capped.
+type ApprovalLimit struct {
+	gorm.Model
+	Role      string       `gorm:"type:varchar(50);not null;uniqueIndex:idx_role_currency_limit"`
+	Currency  string       `gorm:"type:varchar(3);not null;uniqueIndex:idx_role_currency_limit"`
+	MaxAmount money.Amount `gorm:"type:decimal(20,4);not null"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"invoiceB2B/internal/money"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Risk tiers of customers and debtors, from least to most risky. An invoice is priced at the
+// riskier of its customer's tier and its debtor's tier.
+const (
+	RiskTierLow    string = "low"
+	RiskTierMedium string = "medium"
+	RiskTierHigh   string = "high"
+)
+
+var riskTierRank = map[string]int{RiskTierLow: 1, RiskTierMedium: 2, RiskTierHigh: 3}
+
+func IsRiskTier(tier string) bool {
+	_, ok := riskTierRank[tier]
+	return ok
+}
+
+// HigherRiskTier returns the riskier of two tiers. An empty or unknown tier is ignored.
+func HigherRiskTier(a, b string) string {
+	if riskTierRank[b] > riskTierRank[a] {
+		return b
+	}
+	return a
+}
+
+// NormalizeDebtorName is the form of a debtor name used to look up its risk rating.
+func NormalizeDebtorName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// PricingPlan sets the financing terms for invoices of one risk tier in one currency.
+type PricingPlan struct {
+	gorm.Model
+	Name     string `gorm:"type:varchar(100);not null"`
+	RiskTier string `gorm:"type:varchar(10);not null;uniqueIndex:idx_pricing_plan_tier_currency"`
+	Currency string `gorm:"type:varchar(3);not null;uniqueIndex:idx_pricing_plan_tier_currency"`
+
+	AdvanceRatePercentage  decimal.Decimal `gorm:"type:decimal(5,2);not null"` // Share of the invoice amount advanced
+	DiscountRatePercentage decimal.Decimal `gorm:"type:decimal(7,4);not null"` // Annual rate charged on the advance until maturity
+	MinimumDays            int             `gorm:"not null;default:0"`         // Fewer days to maturity are charged as this many
+	FlatFee                money.Amount    `gorm:"type:decimal(20,4);not null;default:0"`
+}
+
+// DebtorRiskRating assigns a risk tier to a debtor, matched on the normalised debtor name.
+type DebtorRiskRating struct {
+	gorm.Model
+	DebtorName string `gorm:"type:varchar(255);not null;uniqueIndex"`
+	RiskTier   string `gorm:"type:varchar(10);not null"`
+}
+
+// PricingBreakdown is the result of pricing an invoice. A copy is embedded in disbursement
+// requests and, once disbursed, in the invoice, so the terms that were applied survive later
+// plan changes. PricedAt is nil until the invoice has been priced.
+type PricingBreakdown struct {
+	PlanID                 *uint           `gorm:"null"`
+	RiskTier               string          `gorm:"type:varchar(10);not null;default:''"`
+	AdvanceRatePercentage  decimal.Decimal `gorm:"type:decimal(5,2);not null;default:0"`
+	DiscountRatePercentage decimal.Decimal `gorm:"type:decimal(7,4);not null;default:0"`
+	DiscountDays           int             `gorm:"not null;default:0"`
+	AdvanceAmount          money.Amount    `gorm:"type:decimal(20,4);not null;default:0"`
+	DiscountFee            money.Amount    `gorm:"type:decimal(20,4);not null;default:0"`
+	FlatFee                money.Amount    `gorm:"type:decimal(20,4);not null;default:0"`
+	NetDisbursement        money.Amount    `gorm:"type:decimal(20,4);not null;default:0"`
+	Overridden             bool            `gorm:"not null;default:false"` // Staff replaced some of the plan's terms
+	OverrideReason         *string         `gorm:"type:text;null"`
+	PricedAt               *time.Time      `gorm:"null"`
+}
+
+func (p PricingBreakdown) TotalFees() money.Amount {
+	return p.DiscountFee.Add(p.FlatFee)
+}
+
+// FinancingQuote is an estimate of what a customer would receive for an invoice, priced on
+// the plans in force when it was given. Quotes are kept for the record and are honoured as an
+// estimate only until ExpiresAt; disbursement always prices the invoice afresh.
+type FinancingQuote struct {
+	gorm.Model
+	UserID         uint  `gorm:"not null;index"`
+	OrganizationID *uint `gorm:"index;null"`
+	InvoiceID      *uint `gorm:"index;null"` // Set for quotes on an uploaded invoice
+
+	DebtorName string       `gorm:"type:varchar(255);not null"`
+	Amount     money.Amount `gorm:"type:decimal(20,4);not null"`
+	Currency   string       `gorm:"type:varchar(3);not null"`
+	DueDate    time.Time    `gorm:"not null"` // Repayment is due on the invoice due date
+
+	Pricing   PricingBreakdown `gorm:"embedded;embeddedPrefix:pricing_"`
+	ExpiresAt time.Time        `gorm:"not null;index"`
+}
+
+// Matches reports whether the quote was given for these invoice terms.
+func (q *FinancingQuote) Matches(debtorName string, amount money.Amount, currency string, dueDate time.Time) bool {
+	return NormalizeDebtorName(q.DebtorName) == NormalizeDebtorName(debtorName) &&
+		q.Amount.Equal(amount) &&
+		strings.EqualFold(q.Currency, currency) &&
+		q.DueDate.Equal(dueDate)
+}
@@ -12,6 +12,20 @@ const (
 	RoleFinanceManager string = "finance_manager"
 )
 
+// IsStaffRole reports whether role is one of the staff roles above.
+func IsStaffRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleKYCReviewer, RoleFinanceManager:
+		return true
+	}
+	return false
+}
+
+const (
+	TwoFAMethodEmail string = "email"
+	TwoFAMethodTOTP  string = "totp"
+)
+
 type User struct {
 	gorm.Model
 	Email        string `gorm:"type:varchar(100);uniqueIndex;not null"`
@@ -23,15 +37,27 @@ type User struct {
 	KYCID     *uint      `gorm:"null"`
 	KYCDetail *KYCDetail `gorm:"foreignKey:KYCID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
-	IsActive     bool       `gorm:"default:true"`
-	TwoFASecret  *string    `gorm:"type:varchar(255);null"`
-	TwoFAEnabled bool       `gorm:"default:false"`
-	EmailOTP     *string    `gorm:"type:varchar(10);null"`
-	EmailOTPExp  *time.Time `gorm:"null"`
+	IsActive        bool       `gorm:"default:true"`
+	EmailVerifiedAt *time.Time `gorm:"null"`
+	TwoFASecret     *string    `gorm:"type:varchar(255);null"`
+	TwoFAEnabled    bool       `gorm:"default:false"`
+	TwoFAMethod     string     `gorm:"type:varchar(10);default:'email';not null"`
+	EmailOTP        *string    `gorm:"type:varchar(10);null"`
+	EmailOTPExp     *time.Time `gorm:"null"`
 
 	Invoices []Invoice `gorm:"foreignKey:UserID"`
 }
 
+// TwoFARecoveryCode is a single-use fallback code for users who lose access to their second factor.
+// Only the SHA-256 hash of the code is stored.
+type TwoFARecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"not null;index"`
+	User     User       `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CodeHash string     `gorm:"type:varchar(64);not null;index"`
+	UsedAt   *time.Time `gorm:"null"`
+}
+
 type KYCStatus string
 
 const (
@@ -43,7 +69,8 @@ const (
 
 type KYCDetail struct {
 	gorm.Model
-	UserID          uint      `gorm:"uniqueIndex;not null"`
+	UserID          uint      `gorm:"uniqueIndex;not null"` // Member who submitted the KYC
+	OrganizationID  *uint     `gorm:"uniqueIndex;null"`
 	Status          KYCStatus `gorm:"type:varchar(20);default:'pending';not null"`
 	SubmittedAt     *time.Time
 	ReviewedByID    *uint  `gorm:"null"`
@@ -62,6 +89,23 @@ type Staff struct {
 	Role         string `gorm:"type:varchar(50);not null"`
 	IsActive     bool   `gorm:"default:true"`
 	LastLoginAt  *time.Time
+
+	TwoFAEnabled bool    `gorm:"default:false"`
+	TwoFAMethod  string  `gorm:"type:varchar(10);default:'email';not null"`
+	TwoFASecret  *string `gorm:"type:varchar(255);null"`
+}
+
+// StaffInvitation is a pending invitation to join the back office. The invitee chooses their
+// own password when accepting; only the SHA-256 hash of the emailed token is stored.
+type StaffInvitation struct {
+	gorm.Model
+	Email       string     `gorm:"type:varchar(100);not null;index"`
+	Role        string     `gorm:"type:varchar(50);not null"`
+	TokenHash   string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	InvitedByID uint       `gorm:"not null"`
+	InvitedBy   Staff      `gorm:"foreignKey:InvitedByID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ExpiresAt   time.Time  `gorm:"not null"`
+	AcceptedAt  *time.Time `gorm:"null"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -86,6 +130,10 @@ func (s *Staff) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	return string(bytes), err
@@ -0,0 +1,125 @@
+// Package money provides the exact decimal Amount type used for every monetary value, and
+// the ISO 4217 minor units that amounts are rounded to.
+package money
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// minorUnits lists the ISO 4217 currencies whose minor unit is not the usual two digits.
+var minorUnits = map[string]int32{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits returns the number of decimal places used by currency, defaulting to two for
+// currencies that are unknown or not yet set.
+func MinorUnits(currency string) int32 {
+	if places, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return places
+	}
+	return 2
+}
+
+// Amount is an exact decimal amount of money. It does not carry its currency; pass the
+// currency stored alongside it to Round and Format. Amounts are encoded in JSON as strings,
+// and decoding accepts both strings and plain numbers.
+type Amount struct {
+	value decimal.Decimal
+}
+
+// Zero is the zero amount, the same as Amount{}.
+var Zero = Amount{}
+
+func New(value decimal.Decimal) Amount {
+	return Amount{value: value}
+}
+
+func FromInt(value int64) Amount {
+	return Amount{value: decimal.NewFromInt(value)}
+}
+
+// Parse reads a decimal string such as "1250.50".
+func Parse(s string) (Amount, error) {
+	value, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil {
+		return Zero, err
+	}
+	return Amount{value: value}, nil
+}
+
+// MustParse is Parse for constants; it panics on malformed input.
+func MustParse(s string) Amount {
+	return Amount{value: decimal.RequireFromString(s)}
+}
+
+func (a Amount) Decimal() decimal.Decimal { return a.value }
+
+func (a Amount) Add(b Amount) Amount { return Amount{value: a.value.Add(b.value)} }
+func (a Amount) Sub(b Amount) Amount { return Amount{value: a.value.Sub(b.value)} }
+func (a Amount) Neg() Amount         { return Amount{value: a.value.Neg()} }
+
+// Mul multiplies the amount by factor without rounding.
+func (a Amount) Mul(factor decimal.Decimal) Amount { return Amount{value: a.value.Mul(factor)} }
+
+// Percent returns percent per cent of the amount without rounding.
+func (a Amount) Percent(percent decimal.Decimal) Amount {
+	return Amount{value: a.value.Mul(percent).Div(hundred)}
+}
+
+// Round rounds the amount to the minor unit of currency, with halves rounded away from zero.
+func (a Amount) Round(currency string) Amount {
+	return Amount{value: a.value.Round(MinorUnits(currency))}
+}
+
+// Format renders the amount rounded to the minor unit of currency, e.g. "1250.50".
+func (a Amount) Format(currency string) string {
+	return a.value.StringFixed(MinorUnits(currency))
+}
+
+func (a Amount) Cmp(b Amount) int          { return a.value.Cmp(b.value) }
+func (a Amount) Equal(b Amount) bool       { return a.value.Equal(b.value) }
+func (a Amount) GreaterThan(b Amount) bool { return a.value.GreaterThan(b.value) }
+func (a Amount) LessThan(b Amount) bool    { return a.value.LessThan(b.value) }
+func (a Amount) IsZero() bool              { return a.value.IsZero() }
+func (a Amount) IsPositive() bool          { return a.value.IsPositive() }
+func (a Amount) IsNegative() bool          { return a.value.IsNegative() }
+func (a Amount) InexactFloat64() float64   { return a.value.InexactFloat64() }
+
+// String keeps the trailing zeros of the amount's scale, so a rounded amount of 1250.5 USD
+// prints as "1250.50".
+func (a Amount) String() string {
+	if exp := a.value.Exponent(); exp < 0 {
+		return a.value.StringFixed(-exp)
+	}
+	return a.value.String()
+}
+
+// Value stores the amount in a numeric column.
+func (a Amount) Value() (driver.Value, error) {
+	return a.value.String(), nil
+}
+
+// Scan reads a numeric column. NULL reads as zero; use *Amount for columns where NULL matters.
+func (a *Amount) Scan(src interface{}) error {
+	if src == nil {
+		a.value = decimal.Decimal{}
+		return nil
+	}
+	return a.value.Scan(src)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + a.String() + `"`), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	return a.value.UnmarshalJSON(data)
+}
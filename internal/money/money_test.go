@@ -0,0 +1,134 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     int32
+	}{
+		{"JPY", 0},
+		{"jpy", 0},
+		{"KWD", 3},
+		{"CLF", 4},
+		{"USD", 2},
+		{"XYZ", 2},
+		{"", 2},
+	}
+	for _, tt := range tests {
+		if got := MinorUnits(tt.currency); got != tt.want {
+			t.Errorf("MinorUnits(%q) = %d, want %d", tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     string
+	}{
+		{"10.005", "USD", "10.01"},
+		{"10.004", "USD", "10"},
+		{"-10.005", "USD", "-10.01"},
+		{"-10.004", "USD", "-10"},
+		{"1234.5", "JPY", "1235"},
+		{"-1234.5", "JPY", "-1235"},
+		{"1.0005", "KWD", "1.001"},
+		{"1.00005", "CLF", "1.0001"},
+		{"2.345", "", "2.35"},
+	}
+	for _, tt := range tests {
+		got := MustParse(tt.amount).Round(tt.currency)
+		if !got.Equal(MustParse(tt.want)) {
+			t.Errorf("%s.Round(%q) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestStringAndFormatKeepTrailingZeros(t *testing.T) {
+	tests := []struct {
+		amount     Amount
+		currency   string
+		wantString string
+		wantFormat string
+	}{
+		{MustParse("1250.50"), "USD", "1250.50", "1250.50"},
+		{MustParse("1250.5").Round("USD"), "USD", "1250.50", "1250.50"},
+		{MustParse("1250.00"), "USD", "1250.00", "1250.00"},
+		{FromInt(1250), "USD", "1250", "1250.00"},
+		{FromInt(1250), "JPY", "1250", "1250"},
+		{MustParse("1.5"), "KWD", "1.5", "1.500"},
+		{Zero, "USD", "0", "0.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.wantString {
+			t.Errorf("String() = %q, want %q", got, tt.wantString)
+		}
+		if got := tt.amount.Format(tt.currency); got != tt.wantFormat {
+			t.Errorf("Format(%q) = %q, want %q", tt.currency, got, tt.wantFormat)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		want     string
+		wantJSON string
+	}{
+		{`"1250.50"`, "1250.50", `"1250.50"`},
+		{`1250.5`, "1250.5", `"1250.5"`},
+		{`100`, "100", `"100"`},
+		{`"-0.01"`, "-0.01", `"-0.01"`},
+	}
+	for _, tt := range tests {
+		var amount Amount
+		if err := json.Unmarshal([]byte(tt.input), &amount); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", tt.input, err)
+		}
+		if !amount.Equal(MustParse(tt.want)) {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.input, amount, tt.want)
+		}
+		encoded, err := json.Marshal(amount)
+		if err != nil {
+			t.Fatalf("Marshal(%s) failed: %v", amount, err)
+		}
+		if string(encoded) != tt.wantJSON {
+			t.Errorf("Marshal(%s) = %s, want %s", tt.input, encoded, tt.wantJSON)
+		}
+	}
+
+	var amount Amount
+	if err := json.Unmarshal([]byte(`"abc"`), &amount); err == nil {
+		t.Error("Unmarshal of a non-numeric string succeeded, want an error")
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		want string
+	}{
+		{"NULL", nil, "0"},
+		{"numeric as bytes", []byte("1250.50"), "1250.50"},
+		{"numeric as string", "99.999", "99.999"},
+		{"integer", int64(42), "42"},
+		{"float", 12.5, "12.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount := MustParse("7") // Scan must overwrite any previous value
+			if err := amount.Scan(tt.src); err != nil {
+				t.Fatalf("Scan(%v) failed: %v", tt.src, err)
+			}
+			if !amount.Equal(MustParse(tt.want)) {
+				t.Errorf("Scan(%v) = %s, want %s", tt.src, amount, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository stores users' personal API keys.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	ListByUserID(ctx context.Context, userID uint) ([]models.APIKey, error)
+	CountByUserID(ctx context.Context, userID uint) (int64, error)
+	FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	Delete(ctx context.Context, userID, keyID uint) (bool, error)
+	TouchLastUsed(ctx context.Context, keyID uint, usedAt time.Time, ipAddress string) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		log.Printf("Error creating API key for user %d: %v", key.UserID, err)
+		return err
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) ListByUserID(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		log.Printf("Error listing API keys of user %d: %v", userID, err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) CountByUserID(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.APIKey{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Printf("Error counting API keys of user %d: %v", userID, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindByHash returns the unrevoked key with its owner preloaded. Expiry is left to the caller.
+func (r *apiKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).Preload("User").Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding API key by hash: %v", err)
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Delete revokes one of the user's keys. It returns false if none matched.
+func (r *apiKeyRepository) Delete(ctx context.Context, userID, keyID uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", keyID, userID).Delete(&models.APIKey{})
+	if result.Error != nil {
+		log.Printf("Error revoking API key %d of user %d: %v", keyID, userID, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, keyID uint, usedAt time.Time, ipAddress string) error {
+	err := r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", keyID).
+		UpdateColumns(map[string]interface{}{"last_used_at": usedAt, "last_used_ip": ipAddress}).Error
+	if err != nil {
+		log.Printf("Error recording use of API key %d: %v", keyID, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// ApprovalLimitRepository stores the monetary approval limits of staff roles.
+type ApprovalLimitRepository interface {
+	ListAll(ctx context.Context) ([]models.ApprovalLimit, error)
+	FindByRoleAndCurrency(ctx context.Context, role, currency string) (*models.ApprovalLimit, error)
+	RoleHasLimits(ctx context.Context, role string) (bool, error)
+	Upsert(ctx context.Context, limit *models.ApprovalLimit) error
+	Delete(ctx context.Context, id uint) (bool, error)
+}
+
+type approvalLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewApprovalLimitRepository(db *gorm.DB) ApprovalLimitRepository {
+	return &approvalLimitRepository{db: db}
+}
+
+func (r *approvalLimitRepository) ListAll(ctx context.Context) ([]models.ApprovalLimit, error) {
+	var limits []models.ApprovalLimit
+	if err := r.db.WithContext(ctx).Order("role ASC, currency ASC").Find(&limits).Error; err != nil {
+		log.Printf("Error listing approval limits: %v", err)
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (r *approvalLimitRepository) FindByRoleAndCurrency(ctx context.Context, role, currency string) (*models.ApprovalLimit, error) {
+	var limit models.ApprovalLimit
+	if err := r.db.WithContext(ctx).Where("role = ? AND currency = ?", role, currency).First(&limit).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding %s approval limit of role %s: %v", currency, role, err)
+		}
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// RoleHasLimits reports whether the role has a limit in any currency.
+func (r *approvalLimitRepository) RoleHasLimits(ctx context.Context, role string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.ApprovalLimit{}).Where("role = ?", role).Count(&count).Error; err != nil {
+		log.Printf("Error counting approval limits of role %s: %v", role, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Upsert creates the limit or replaces the amount of the existing one for the same role and
+// currency.
+func (r *approvalLimitRepository) Upsert(ctx context.Context, limit *models.ApprovalLimit) error {
+	existing, err := r.FindByRoleAndCurrency(ctx, limit.Role, limit.Currency)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil {
+		limit.Model = existing.Model
+	}
+	if err := r.db.WithContext(ctx).Save(limit).Error; err != nil {
+		log.Printf("Error saving %s approval limit of role %s: %v", limit.Currency, limit.Role, err)
+		return err
+	}
+	return nil
+}
+
+// Delete removes a limit permanently so it can be recreated without tripping the unique
+// index. It returns false if none matched.
+func (r *approvalLimitRepository) Delete(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&models.ApprovalLimit{}, id)
+	if result.Error != nil {
+		log.Printf("Error deleting approval limit %d: %v", id, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
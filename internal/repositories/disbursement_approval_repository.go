@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DisbursementApprovalRepository manages maker-checker disbursement requests.
+type DisbursementApprovalRepository interface {
+	Create(ctx context.Context, approval *models.DisbursementApproval) error
+	FindPendingByID(ctx context.Context, id uint) (*models.DisbursementApproval, error)
+	FindPendingByInvoiceID(ctx context.Context, invoiceID uint) (*models.DisbursementApproval, error)
+	ListPending(ctx context.Context) ([]models.DisbursementApproval, error)
+	Approve(ctx context.Context, approval *models.DisbursementApproval, reviewerID uint) error
+	Reject(ctx context.Context, approval *models.DisbursementApproval, reviewerID uint, reason string) error
+}
+
+type disbursementApprovalRepository struct {
+	db *gorm.DB
+}
+
+func NewDisbursementApprovalRepository(db *gorm.DB) DisbursementApprovalRepository {
+	return &disbursementApprovalRepository{db: db}
+}
+
+func (r *disbursementApprovalRepository) Create(ctx context.Context, approval *models.DisbursementApproval) error {
+	if err := r.db.WithContext(ctx).Create(approval).Error; err != nil {
+		log.Printf("Error creating disbursement request for invoice %d: %v", approval.InvoiceID, err)
+		return err
+	}
+	return nil
+}
+
+func (r *disbursementApprovalRepository) pending(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("Invoice").Preload("RequestedBy").
+		Where("status = ?", models.DisbursementPending)
+}
+
+func (r *disbursementApprovalRepository) FindPendingByID(ctx context.Context, id uint) (*models.DisbursementApproval, error) {
+	var approval models.DisbursementApproval
+	if err := r.pending(ctx).First(&approval, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding disbursement request %d: %v", id, err)
+		}
+		return nil, err
+	}
+	return &approval, nil
+}
+
+func (r *disbursementApprovalRepository) FindPendingByInvoiceID(ctx context.Context, invoiceID uint) (*models.DisbursementApproval, error) {
+	var approval models.DisbursementApproval
+	if err := r.pending(ctx).Where("invoice_id = ?", invoiceID).First(&approval).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding disbursement request for invoice %d: %v", invoiceID, err)
+		}
+		return nil, err
+	}
+	return &approval, nil
+}
+
+func (r *disbursementApprovalRepository) ListPending(ctx context.Context) ([]models.DisbursementApproval, error) {
+	var approvals []models.DisbursementApproval
+	if err := r.pending(ctx).Order("created_at ASC").Find(&approvals).Error; err != nil {
+		log.Printf("Error listing pending disbursement requests: %v", err)
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// Approve marks the request as approved, moves the invoice from approved to disbursed with
+// the requested amounts, records the status change and writes the disbursement transaction,
+// all in one transaction.
+// The conditional updates make approval single-use and fail with gorm.ErrRecordNotFound if
+// the request or the invoice changed since they were read.
+func (r *disbursementApprovalRepository) Approve(ctx context.Context, approval *models.DisbursementApproval, reviewerID uint) error {
+	if err := models.ValidateInvoiceTransition(models.InvoiceApproved, models.InvoiceDisbursed); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Invoice{}).
+			Where("id = ? AND status = ?", approval.InvoiceID, models.InvoiceApproved).
+			Updates(disbursedInvoiceColumns(approval, reviewerID, now))
+		if result.Error != nil {
+			log.Printf("Error disbursing invoice %d: %v", approval.InvoiceID, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		entry := models.InvoiceStatusHistory{ActorType: models.InvoiceActorStaff, ActorID: &reviewerID}
+		if err := recordInvoiceTransition(tx, approval.InvoiceID, models.InvoiceApproved, models.InvoiceDisbursed, entry); err != nil {
+			return err
+		}
+
+		// Requests priced before the pricing engine carry no breakdown; they pay out in full
+		paidOut := approval.FinancedAmount
+		if approval.Pricing.PricedAt != nil {
+			paidOut = approval.Pricing.NetDisbursement
+		}
+		disbursementTx := &models.Transaction{
+			InvoiceID:       approval.InvoiceID,
+			Type:            models.TransactionDisbursement,
+			Status:          models.TransactionCompleted,
+			Amount:          paidOut,
+			TransactionDate: now,
+		}
+		if err := tx.Create(disbursementTx).Error; err != nil {
+			log.Printf("Error creating disbursement transaction for invoice %d: %v", approval.InvoiceID, err)
+			return err
+		}
+
+		result = tx.Model(&models.DisbursementApproval{}).
+			Where("id = ? AND status = ?", approval.ID, models.DisbursementPending).
+			Updates(map[string]interface{}{
+				"status":         models.DisbursementApproved,
+				"reviewed_by_id": reviewerID,
+				"reviewed_at":    now,
+				"transaction_id": disbursementTx.ID,
+			})
+		if result.Error != nil {
+			log.Printf("Error approving disbursement request %d: %v", approval.ID, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		approval.Status = models.DisbursementApproved
+		approval.ReviewedByID = &reviewerID
+		approval.ReviewedAt = &now
+		approval.TransactionID = &disbursementTx.ID
+		return nil
+	})
+}
+
+// disbursedInvoiceColumns are the invoice columns set on disbursement, including the pricing
+// breakdown the request was approved with.
+func disbursedInvoiceColumns(approval *models.DisbursementApproval, reviewerID uint, now time.Time) map[string]interface{} {
+	pricing := approval.Pricing
+	return map[string]interface{}{
+		"status":                           models.InvoiceDisbursed,
+		"disbursed_by_id":                  reviewerID,
+		"disbursed_at":                     now,
+		"financed_amount":                  approval.FinancedAmount,
+		"financing_fee_percentage":         approval.FinancingFeePercentage,
+		"pricing_plan_id":                  pricing.PlanID,
+		"pricing_risk_tier":                pricing.RiskTier,
+		"pricing_advance_rate_percentage":  pricing.AdvanceRatePercentage,
+		"pricing_discount_rate_percentage": pricing.DiscountRatePercentage,
+		"pricing_discount_days":            pricing.DiscountDays,
+		"pricing_advance_amount":           pricing.AdvanceAmount,
+		"pricing_discount_fee":             pricing.DiscountFee,
+		"pricing_flat_fee":                 pricing.FlatFee,
+		"pricing_net_disbursement":         pricing.NetDisbursement,
+		"pricing_overridden":               pricing.Overridden,
+		"pricing_override_reason":          pricing.OverrideReason,
+		"pricing_priced_at":                pricing.PricedAt,
+	}
+}
+
+// Reject closes a pending request without disbursing. It fails with gorm.ErrRecordNotFound
+// if the request is no longer pending.
+func (r *disbursementApprovalRepository) Reject(ctx context.Context, approval *models.DisbursementApproval, reviewerID uint, reason string) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.DisbursementApproval{}).
+		Where("id = ? AND status = ?", approval.ID, models.DisbursementPending).
+		Updates(map[string]interface{}{
+			"status":           models.DisbursementRejected,
+			"reviewed_by_id":   reviewerID,
+			"reviewed_at":      now,
+			"rejection_reason": reason,
+		})
+	if result.Error != nil {
+		log.Printf("Error rejecting disbursement request %d: %v", approval.ID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	approval.Status = models.DisbursementRejected
+	approval.ReviewedByID = &reviewerID
+	approval.ReviewedAt = &now
+	approval.RejectionReason = &reason
+	return nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InvoiceEscalationRepository manages the queue of invoices escalated past a role's approval limit.
+type InvoiceEscalationRepository interface {
+	CreateIfNotOpen(ctx context.Context, escalation *models.InvoiceEscalation) error
+	ListOpen(ctx context.Context) ([]models.InvoiceEscalation, error)
+	ResolveForInvoice(ctx context.Context, invoiceID uint, action models.EscalationAction, staffID uint) error
+}
+
+type invoiceEscalationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceEscalationRepository(db *gorm.DB) InvoiceEscalationRepository {
+	return &invoiceEscalationRepository{db: db}
+}
+
+// CreateIfNotOpen queues the escalation unless the invoice is already queued for the same
+// action, in which case escalation is filled with the open entry.
+func (r *invoiceEscalationRepository) CreateIfNotOpen(ctx context.Context, escalation *models.InvoiceEscalation) error {
+	err := r.db.WithContext(ctx).
+		Where("invoice_id = ? AND action = ? AND resolved_at IS NULL", escalation.InvoiceID, escalation.Action).
+		FirstOrCreate(escalation).Error
+	if err != nil {
+		log.Printf("Error escalating invoice %d for %s: %v", escalation.InvoiceID, escalation.Action, err)
+		return err
+	}
+	return nil
+}
+
+func (r *invoiceEscalationRepository) ListOpen(ctx context.Context) ([]models.InvoiceEscalation, error) {
+	var escalations []models.InvoiceEscalation
+	err := r.db.WithContext(ctx).Preload("Invoice").Preload("EscalatedBy").
+		Where("resolved_at IS NULL").Order("created_at ASC").Find(&escalations).Error
+	if err != nil {
+		log.Printf("Error listing invoice escalations: %v", err)
+		return nil, err
+	}
+	return escalations, nil
+}
+
+// ResolveForInvoice closes the open escalations of the invoice for action.
+func (r *invoiceEscalationRepository) ResolveForInvoice(ctx context.Context, invoiceID uint, action models.EscalationAction, staffID uint) error {
+	err := r.db.WithContext(ctx).Model(&models.InvoiceEscalation{}).
+		Where("invoice_id = ? AND action = ? AND resolved_at IS NULL", invoiceID, action).
+		Updates(map[string]interface{}{"resolved_by_id": staffID, "resolved_at": time.Now()}).Error
+	if err != nil {
+		log.Printf("Error resolving %s escalations of invoice %d: %v", action, invoiceID, err)
+		return err
+	}
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
 	"log"
 	"time" // Added for CountOverdue
 )
@@ -14,15 +16,19 @@ import (
 type InvoiceRepository interface {
 	Create(ctx context.Context, invoice *models.Invoice) error
 	Update(ctx context.Context, invoice *models.Invoice) error
+	Transition(ctx context.Context, invoice *models.Invoice, to models.InvoiceStatus, entry models.InvoiceStatusHistory) error
+	ListStatusHistory(ctx context.Context, invoiceID uint) ([]models.InvoiceStatusHistory, error)
 	FindByID(ctx context.Context, id uint) (*models.Invoice, error)
 	FindByIDWithRelations(ctx context.Context, id uint) (*models.Invoice, error)
 	FindByUserID(ctx context.Context, userID uint, page, pageSize int) ([]models.Invoice, int64, error)
+	FindByOrganizationID(ctx context.Context, organizationID uint, page, pageSize int) ([]models.Invoice, int64, error)
 	// Renamed from FindAll to FindAllWithRelations to match service layer usage and make preloading explicit.
 	FindAll(ctx context.Context, page, pageSize int, filters map[string]string) ([]models.Invoice, int64, error)
 	// Added methods for analytics
 	CountByStatus(ctx context.Context, status models.InvoiceStatus, filters map[string]interface{}) (int64, error)
 	CountOverdue(ctx context.Context) (int64, error)
-	SumAmountByStatus(ctx context.Context, statuses []models.InvoiceStatus, amountField string) (float64, error)
+	FindOutstanding(ctx context.Context, page, pageSize int) ([]models.Invoice, int64, error)
+	SumAmountByStatus(ctx context.Context, statuses []models.InvoiceStatus, amountField string) (map[string]money.Amount, error)
 }
 
 type invoiceRepository struct {
@@ -34,24 +40,81 @@ func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
 	return &invoiceRepository{db: db}
 }
 
-// Create persists a new invoice record to the database.
+// Create persists a new invoice record to the database, recording its initial status as
+// set by the uploading user.
 func (r *invoiceRepository) Create(ctx context.Context, invoice *models.Invoice) error {
-	if err := r.db.WithContext(ctx).Create(invoice).Error; err != nil {
-		log.Printf("Error creating invoice in DB: %v", err)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(invoice).Error; err != nil {
+			log.Printf("Error creating invoice in DB: %v", err)
+			return err
+		}
+		entry := models.InvoiceStatusHistory{ActorType: models.InvoiceActorUser, ActorID: &invoice.UserID}
+		return recordInvoiceTransition(tx, invoice.ID, "", invoice.Status, entry)
+	})
+}
+
+// Update saves changes to an existing invoice record in the database. The status column is
+// never written here; status changes go through Transition.
+func (r *invoiceRepository) Update(ctx context.Context, invoice *models.Invoice) error {
+	if err := r.db.WithContext(ctx).Omit("status").Save(invoice).Error; err != nil {
+		log.Printf("Error updating invoice %d in DB: %v", invoice.ID, err)
 		return err
 	}
 	return nil
 }
 
-// Update saves changes to an existing invoice record in the database.
-func (r *invoiceRepository) Update(ctx context.Context, invoice *models.Invoice) error {
-	if err := r.db.WithContext(ctx).Save(invoice).Error; err != nil {
-		log.Printf("Error updating invoice %d in DB: %v", invoice.ID, err)
+// Transition moves the invoice from the status it was loaded with to status to, saving its
+// other changes along with it, and records the change. entry supplies the actor and reason.
+// The update is conditional on the loaded status, so if another request moved the invoice
+// first this fails with ErrInvalidInvoiceTransition instead of overwriting it.
+func (r *invoiceRepository) Transition(ctx context.Context, invoice *models.Invoice, to models.InvoiceStatus, entry models.InvoiceStatusHistory) error {
+	from := invoice.Status
+	if err := models.ValidateInvoiceTransition(from, to); err != nil {
+		return err
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoice.Status = to
+		result := tx.Model(invoice).Where("status = ?", from).Select("*").Omit(clause.Associations).Updates(invoice)
+		if result.Error != nil {
+			log.Printf("Error moving invoice %d from %s to %s: %v", invoice.ID, from, to, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: invoice %d is no longer %s", models.ErrInvalidInvoiceTransition, invoice.ID, from)
+		}
+		return recordInvoiceTransition(tx, invoice.ID, from, to, entry)
+	})
+	if err != nil {
+		invoice.Status = from
 		return err
 	}
 	return nil
 }
 
+// recordInvoiceTransition writes a status history entry inside tx. The caller must already
+// have changed the invoice's status in the same transaction.
+func recordInvoiceTransition(tx *gorm.DB, invoiceID uint, from, to models.InvoiceStatus, entry models.InvoiceStatusHistory) error {
+	entry.InvoiceID = invoiceID
+	entry.FromStatus = from
+	entry.ToStatus = to
+	if err := tx.Create(&entry).Error; err != nil {
+		log.Printf("Error recording status change of invoice %d to %s: %v", invoiceID, to, err)
+		return err
+	}
+	return nil
+}
+
+// ListStatusHistory returns an invoice's status changes, oldest first.
+func (r *invoiceRepository) ListStatusHistory(ctx context.Context, invoiceID uint) ([]models.InvoiceStatusHistory, error) {
+	var history []models.InvoiceStatusHistory
+	if err := r.db.WithContext(ctx).Where("invoice_id = ?", invoiceID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		log.Printf("Error listing status history of invoice %d: %v", invoiceID, err)
+		return nil, err
+	}
+	return history, nil
+}
+
 // FindByID retrieves a single invoice by its ID.
 func (r *invoiceRepository) FindByID(ctx context.Context, id uint) (*models.Invoice, error) {
 	var invoice models.Invoice
@@ -103,6 +166,26 @@ func (r *invoiceRepository) FindByUserID(ctx context.Context, userID uint, page,
 	return invoices, total, nil
 }
 
+// FindByOrganizationID retrieves a paginated list of invoices belonging to an organisation.
+func (r *invoiceRepository) FindByOrganizationID(ctx context.Context, organizationID uint, page, pageSize int) ([]models.Invoice, int64, error) {
+	var invoices []models.Invoice
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Invoice{}).Where("organization_id = ?", organizationID)
+
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("Error counting invoices for organization %d: %v", organizationID, err)
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&invoices).Error; err != nil {
+		log.Printf("Error fetching invoices for organization %d: %v", organizationID, err)
+		return nil, 0, err
+	}
+	return invoices, total, nil
+}
+
 // FindAllWithRelations retrieves a paginated list of all invoices, applying filters and preloading relations.
 // Renamed from FindAll.
 func (r *invoiceRepository) FindAll(ctx context.Context, page, pageSize int, filters map[string]string) ([]models.Invoice, int64, error) {
@@ -168,7 +251,7 @@ func (r *invoiceRepository) CountOverdue(ctx context.Context) (int64, error) {
 	var count int64
 	now := time.Now()
 	err := r.db.WithContext(ctx).Model(&models.Invoice{}).
-		Where("status = ?", models.InvoiceDisbursed). // Assuming models.InvoiceDisbursed is defined
+		Where("status IN ?", []models.InvoiceStatus{models.InvoiceDisbursed, models.InvoiceRepaymentPending}).
 		Where("due_date < ?", now).
 		Count(&count).Error
 	if err != nil {
@@ -178,29 +261,59 @@ func (r *invoiceRepository) CountOverdue(ctx context.Context) (int64, error) {
 	return count, nil
 }
 
-// SumAmountByStatus calculates the sum of a specified amount field for invoices matching given statuses.
+// SumAmountByStatus calculates the sum of a specified amount field for invoices matching given
+// statuses, per currency. Amounts in different currencies are never added together.
 // statuses: A slice of InvoiceStatus to filter by. If empty, no status filter is applied.
 // amountField: The database column name of the amount to sum (e.g., "amount", "financed_amount").
-func (r *invoiceRepository) SumAmountByStatus(ctx context.Context, statuses []models.InvoiceStatus, amountField string) (float64, error) {
-	var totalAmount struct {
-		Total float64
+func (r *invoiceRepository) SumAmountByStatus(ctx context.Context, statuses []models.InvoiceStatus, amountField string) (map[string]money.Amount, error) {
+	var rows []struct {
+		Currency string
+		Total    money.Amount
 	}
 
 	if amountField != "amount" && amountField != "financed_amount" {
 		log.Printf("Error: Invalid amount field '%s' for summation.", amountField)
-		return 0, errors.New("invalid amount field for summation")
+		return nil, errors.New("invalid amount field for summation")
 	}
 
-	query := r.db.WithContext(ctx).Model(&models.Invoice{})
+	query := r.db.WithContext(ctx).Model(&models.Invoice{}).Where(fmt.Sprintf("%s IS NOT NULL", amountField))
 
 	if len(statuses) > 0 {
 		query = query.Where("status IN (?)", statuses)
 	}
 
-	selectQuery := fmt.Sprintf("COALESCE(SUM(%s), 0) as total", amountField)
-	if err := query.Select(selectQuery).Scan(&totalAmount).Error; err != nil {
+	selectQuery := fmt.Sprintf("UPPER(COALESCE(currency, '')) AS currency, SUM(%s) AS total", amountField)
+	if err := query.Select(selectQuery).Group("UPPER(COALESCE(currency, ''))").Scan(&rows).Error; err != nil {
 		log.Printf("Error summing '%s' for statuses %v: %v", amountField, statuses, err)
-		return 0, err
+		return nil, err
 	}
-	return totalAmount.Total, nil
+
+	totals := make(map[string]money.Amount, len(rows))
+	for _, row := range rows {
+		totals[row.Currency] = row.Total.Round(row.Currency) // Sets the display scale; stored amounts are already rounded
+	}
+	return totals, nil
+}
+
+// FindOutstanding lists disbursed invoices that have not been fully repaid, soonest due first.
+func (r *invoiceRepository) FindOutstanding(ctx context.Context, page, pageSize int) ([]models.Invoice, int64, error) {
+	var invoices []models.Invoice
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Invoice{}).
+		Where("status IN ?", []models.InvoiceStatus{models.InvoiceDisbursed, models.InvoiceRepaymentPending})
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("Error counting outstanding invoices: %v", err)
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("due_date ASC NULLS LAST, id ASC").
+		Preload("User").
+		Offset(offset).Limit(pageSize).Find(&invoices).Error
+	if err != nil {
+		log.Printf("Error fetching outstanding invoices: %v", err)
+		return nil, 0, err
+	}
+	return invoices, total, nil
 }
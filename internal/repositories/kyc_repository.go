@@ -14,6 +14,7 @@ import (
 type KYCRepository interface {
 	CreateOrUpdate(ctx context.Context, kycDetail *models.KYCDetail) (*models.KYCDetail, error)
 	FindByUserID(ctx context.Context, userID uint) (*models.KYCDetail, error)
+	FindByOrganizationID(ctx context.Context, organizationID uint) (*models.KYCDetail, error)
 	FindByID(ctx context.Context, kycID uint) (*models.KYCDetail, error)
 	CountByStatus(ctx context.Context, status models.KYCStatus) (int64, error) // Added for analytics
 }
@@ -27,12 +28,20 @@ func NewKYCRepository(db *gorm.DB) KYCRepository {
 	return &kycRepository{db: db}
 }
 
-// CreateOrUpdate creates a new KYC record or updates an existing one based on UserID.
+// CreateOrUpdate creates a new KYC record or updates an existing one based on OrganizationID
+// (or UserID for records that do not belong to an organisation).
 func (r *kycRepository) CreateOrUpdate(ctx context.Context, kycDetail *models.KYCDetail) (*models.KYCDetail, error) {
+	conflictColumn := "user_id"
+	updateColumns := []string{"status", "submitted_at", "reviewed_by_id", "reviewed_at", "rejection_reason", "documents_info", "updated_at"}
+	if kycDetail.OrganizationID != nil {
+		conflictColumn = "organization_id"
+		updateColumns = append(updateColumns, "user_id") // Record the member who last submitted
+	}
+
 	// Ensure all relevant fields are included in DoUpdates for a proper update on conflict.
 	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "user_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"status", "submitted_at", "reviewed_by_id", "reviewed_at", "rejection_reason", "documents_info", "updated_at"}),
+		Columns:   []clause.Column{{Name: conflictColumn}},
+		DoUpdates: clause.AssignmentColumns(updateColumns),
 	}).Create(kycDetail).Error
 
 	if err != nil {
@@ -64,6 +73,19 @@ func (r *kycRepository) FindByUserID(ctx context.Context, userID uint) (*models.
 	return &kycDetail, nil
 }
 
+// FindByOrganizationID retrieves the KYC record owned by an organisation.
+func (r *kycRepository) FindByOrganizationID(ctx context.Context, organizationID uint) (*models.KYCDetail, error) {
+	var kycDetail models.KYCDetail
+	if err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).First(&kycDetail).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound // Propagate gorm.ErrRecordNotFound
+		}
+		log.Printf("Error finding KYC detail by organization ID %d in DB: %v", organizationID, err)
+		return nil, err
+	}
+	return &kycDetail, nil
+}
+
 // FindByID retrieves a KYC record by its primary ID.
 func (r *kycRepository) FindByID(ctx context.Context, kycID uint) (*models.KYCDetail, error) {
 	var kycDetail models.KYCDetail
@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrganizationRepository manages organisations, their members and pending invitations.
+type OrganizationRepository interface {
+	CreateWithOwner(ctx context.Context, org *models.Organization, owner *models.User) error
+	Update(ctx context.Context, org *models.Organization) error
+	FindByID(ctx context.Context, id uint) (*models.Organization, error)
+	UpdateRiskTier(ctx context.Context, id uint, riskTier string) (bool, error)
+	FindMembershipByUserID(ctx context.Context, userID uint) (*models.OrganizationMember, error)
+	FindMember(ctx context.Context, organizationID, userID uint) (*models.OrganizationMember, error)
+	ListMembers(ctx context.Context, organizationID uint) ([]models.OrganizationMember, error)
+	UpdateMember(ctx context.Context, member *models.OrganizationMember) error
+	DeleteMember(ctx context.Context, member *models.OrganizationMember) error
+	CountMembersByRole(ctx context.Context, organizationID uint, role string) (int64, error)
+
+	CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error
+	FindPendingInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.OrganizationInvitation, error)
+	FindPendingInvitationByEmail(ctx context.Context, organizationID uint, email string) (*models.OrganizationInvitation, error)
+	ListPendingInvitations(ctx context.Context, organizationID uint) ([]models.OrganizationInvitation, error)
+	DeleteInvitation(ctx context.Context, organizationID, invitationID uint) (bool, error)
+	AcceptInvitation(ctx context.Context, invitation *models.OrganizationInvitation, user *models.User) error
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+// CreateWithOwner creates a self-registered user's login, their organisation, the owner
+// membership and the organisation's pending KYC record atomically, so a failure leaves no
+// login without an organisation behind.
+func (r *organizationRepository) CreateWithOwner(ctx context.Context, org *models.Organization, owner *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(owner).Error; err != nil {
+			log.Printf("Error creating user %s: %v", owner.Email, err)
+			return err
+		}
+		if err := tx.Create(org).Error; err != nil {
+			log.Printf("Error creating organization for user %d: %v", owner.ID, err)
+			return err
+		}
+		member := &models.OrganizationMember{OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner}
+		if err := tx.Create(member).Error; err != nil {
+			log.Printf("Error adding owner %d to organization %d: %v", owner.ID, org.ID, err)
+			return err
+		}
+		kycDetail := &models.KYCDetail{UserID: owner.ID, OrganizationID: &org.ID, Status: models.KYCPending}
+		if err := tx.Create(kycDetail).Error; err != nil {
+			log.Printf("Error creating initial KYC record for organization %d: %v", org.ID, err)
+			return err
+		}
+		owner.KYCID = &kycDetail.ID
+		if err := tx.Model(owner).Update("kyc_id", kycDetail.ID).Error; err != nil {
+			log.Printf("Error linking user %d to KYC record %d: %v", owner.ID, kycDetail.ID, err)
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *organizationRepository) Update(ctx context.Context, org *models.Organization) error {
+	if err := r.db.WithContext(ctx).Save(org).Error; err != nil {
+		log.Printf("Error updating organization %d: %v", org.ID, err)
+		return err
+	}
+	return nil
+}
+
+func (r *organizationRepository) FindByID(ctx context.Context, id uint) (*models.Organization, error) {
+	var org models.Organization
+	if err := r.db.WithContext(ctx).First(&org, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding organization %d: %v", id, err)
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// UpdateRiskTier sets the organisation's risk tier. It returns false if no organisation matched.
+func (r *organizationRepository) UpdateRiskTier(ctx context.Context, id uint, riskTier string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Organization{}).Where("id = ?", id).Update("risk_tier", riskTier)
+	if result.Error != nil {
+		log.Printf("Error updating risk tier of organization %d: %v", id, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindMembershipByUserID returns the user's membership with its organisation preloaded.
+func (r *organizationRepository) FindMembershipByUserID(ctx context.Context, userID uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	if err := r.db.WithContext(ctx).Preload("Organization").Where("user_id = ?", userID).First(&member).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding organization membership for user %d: %v", userID, err)
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *organizationRepository) FindMember(ctx context.Context, organizationID, userID uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	if err := r.db.WithContext(ctx).Preload("User").Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding member %d of organization %d: %v", userID, organizationID, err)
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *organizationRepository) ListMembers(ctx context.Context, organizationID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	if err := r.db.WithContext(ctx).Preload("User").Where("organization_id = ?", organizationID).Order("created_at ASC").Find(&members).Error; err != nil {
+		log.Printf("Error listing members of organization %d: %v", organizationID, err)
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *organizationRepository) UpdateMember(ctx context.Context, member *models.OrganizationMember) error {
+	if err := r.db.WithContext(ctx).Model(member).Update("role", member.Role).Error; err != nil {
+		log.Printf("Error updating member %d of organization %d: %v", member.UserID, member.OrganizationID, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteMember removes the membership permanently so the user ID could be re-added later
+// without tripping the unique index.
+func (r *organizationRepository) DeleteMember(ctx context.Context, member *models.OrganizationMember) error {
+	if err := r.db.WithContext(ctx).Unscoped().Delete(member).Error; err != nil {
+		log.Printf("Error removing member %d from organization %d: %v", member.UserID, member.OrganizationID, err)
+		return err
+	}
+	return nil
+}
+
+func (r *organizationRepository) CountMembersByRole(ctx context.Context, organizationID uint, role string) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.OrganizationMember{}).Where("organization_id = ? AND role = ?", organizationID, role).Count(&count).Error; err != nil {
+		log.Printf("Error counting %s members of organization %d: %v", role, organizationID, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) error {
+	if err := r.db.WithContext(ctx).Create(invitation).Error; err != nil {
+		log.Printf("Error creating invitation for %s to organization %d: %v", invitation.Email, invitation.OrganizationID, err)
+		return err
+	}
+	return nil
+}
+
+// FindPendingInvitationByTokenHash returns an unexpired, unaccepted invitation with its organisation.
+func (r *organizationRepository) FindPendingInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.WithContext(ctx).Preload("Organization").
+		Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&invitation).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding invitation by token: %v", err)
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *organizationRepository) FindPendingInvitationByEmail(ctx context.Context, organizationID uint, email string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND LOWER(email) = LOWER(?) AND accepted_at IS NULL AND expires_at > ?", organizationID, email, time.Now()).
+		First(&invitation).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding invitation for %s to organization %d: %v", email, organizationID, err)
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *organizationRepository) ListPendingInvitations(ctx context.Context, organizationID uint) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", organizationID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error
+	if err != nil {
+		log.Printf("Error listing invitations of organization %d: %v", organizationID, err)
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// DeleteInvitation revokes a pending invitation. It returns false if none matched.
+func (r *organizationRepository) DeleteInvitation(ctx context.Context, organizationID, invitationID uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND organization_id = ? AND accepted_at IS NULL", invitationID, organizationID).
+		Delete(&models.OrganizationInvitation{})
+	if result.Error != nil {
+		log.Printf("Error revoking invitation %d of organization %d: %v", invitationID, organizationID, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// AcceptInvitation creates the invitee's account and membership and marks the invitation as
+// used, all in one transaction. The conditional update makes the token single-use.
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, invitation *models.OrganizationInvitation, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.OrganizationInvitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			log.Printf("Error marking invitation %d as accepted: %v", invitation.ID, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			log.Printf("Error creating user for invitation %d: %v", invitation.ID, err)
+			return err
+		}
+		member := &models.OrganizationMember{OrganizationID: invitation.OrganizationID, UserID: user.ID, Role: invitation.Role}
+		if err := tx.Create(member).Error; err != nil {
+			log.Printf("Error adding user %d to organization %d: %v", user.ID, invitation.OrganizationID, err)
+			return err
+		}
+		invitation.AcceptedAt = &now
+		return nil
+	})
+}
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PricingRepository stores pricing plans and the risk ratings of debtors.
+type PricingRepository interface {
+	ListPlans(ctx context.Context) ([]models.PricingPlan, error)
+	FindPlan(ctx context.Context, riskTier, currency string) (*models.PricingPlan, error)
+	UpsertPlan(ctx context.Context, plan *models.PricingPlan) error
+	DeletePlan(ctx context.Context, id uint) (bool, error)
+
+	ListDebtorRatings(ctx context.Context) ([]models.DebtorRiskRating, error)
+	FindDebtorRating(ctx context.Context, debtorName string) (*models.DebtorRiskRating, error)
+	UpsertDebtorRating(ctx context.Context, rating *models.DebtorRiskRating) error
+	DeleteDebtorRating(ctx context.Context, id uint) (bool, error)
+
+	CreateQuote(ctx context.Context, quote *models.FinancingQuote) error
+	FindLatestInvoiceQuote(ctx context.Context, invoiceID uint, validAt time.Time) (*models.FinancingQuote, error)
+}
+
+type pricingRepository struct {
+	db *gorm.DB
+}
+
+func NewPricingRepository(db *gorm.DB) PricingRepository {
+	return &pricingRepository{db: db}
+}
+
+func (r *pricingRepository) ListPlans(ctx context.Context) ([]models.PricingPlan, error) {
+	var plans []models.PricingPlan
+	if err := r.db.WithContext(ctx).Order("currency ASC, risk_tier ASC").Find(&plans).Error; err != nil {
+		log.Printf("Error listing pricing plans: %v", err)
+		return nil, err
+	}
+	return plans, nil
+}
+
+func (r *pricingRepository) FindPlan(ctx context.Context, riskTier, currency string) (*models.PricingPlan, error) {
+	var plan models.PricingPlan
+	if err := r.db.WithContext(ctx).Where("risk_tier = ? AND currency = ?", riskTier, currency).First(&plan).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding %s pricing plan for risk tier %s: %v", currency, riskTier, err)
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// UpsertPlan creates the plan or replaces the terms of the existing one for the same risk
+// tier and currency.
+func (r *pricingRepository) UpsertPlan(ctx context.Context, plan *models.PricingPlan) error {
+	existing, err := r.FindPlan(ctx, plan.RiskTier, plan.Currency)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil {
+		plan.Model = existing.Model
+	}
+	if err := r.db.WithContext(ctx).Save(plan).Error; err != nil {
+		log.Printf("Error saving %s pricing plan for risk tier %s: %v", plan.Currency, plan.RiskTier, err)
+		return err
+	}
+	return nil
+}
+
+// DeletePlan removes a plan permanently so it can be recreated without tripping the unique
+// index. It returns false if none matched.
+func (r *pricingRepository) DeletePlan(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&models.PricingPlan{}, id)
+	if result.Error != nil {
+		log.Printf("Error deleting pricing plan %d: %v", id, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *pricingRepository) ListDebtorRatings(ctx context.Context) ([]models.DebtorRiskRating, error) {
+	var ratings []models.DebtorRiskRating
+	if err := r.db.WithContext(ctx).Order("debtor_name ASC").Find(&ratings).Error; err != nil {
+		log.Printf("Error listing debtor risk ratings: %v", err)
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// FindDebtorRating looks a debtor up by its normalised name.
+func (r *pricingRepository) FindDebtorRating(ctx context.Context, debtorName string) (*models.DebtorRiskRating, error) {
+	var rating models.DebtorRiskRating
+	if err := r.db.WithContext(ctx).Where("debtor_name = ?", models.NormalizeDebtorName(debtorName)).First(&rating).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding risk rating of debtor %s: %v", debtorName, err)
+		}
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// UpsertDebtorRating creates the rating or replaces the tier of the existing one for the same
+// debtor. The debtor name is stored normalised.
+func (r *pricingRepository) UpsertDebtorRating(ctx context.Context, rating *models.DebtorRiskRating) error {
+	rating.DebtorName = models.NormalizeDebtorName(rating.DebtorName)
+	existing, err := r.FindDebtorRating(ctx, rating.DebtorName)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil {
+		rating.Model = existing.Model
+	}
+	if err := r.db.WithContext(ctx).Save(rating).Error; err != nil {
+		log.Printf("Error saving risk rating of debtor %s: %v", rating.DebtorName, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteDebtorRating removes a rating permanently. It returns false if none matched.
+func (r *pricingRepository) DeleteDebtorRating(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&models.DebtorRiskRating{}, id)
+	if result.Error != nil {
+		log.Printf("Error deleting debtor risk rating %d: %v", id, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *pricingRepository) CreateQuote(ctx context.Context, quote *models.FinancingQuote) error {
+	if err := r.db.WithContext(ctx).Create(quote).Error; err != nil {
+		log.Printf("Error creating financing quote for user %d: %v", quote.UserID, err)
+		return err
+	}
+	return nil
+}
+
+// FindLatestInvoiceQuote returns the newest quote on an invoice that is still valid at
+// validAt, or gorm.ErrRecordNotFound.
+func (r *pricingRepository) FindLatestInvoiceQuote(ctx context.Context, invoiceID uint, validAt time.Time) (*models.FinancingQuote, error) {
+	var quote models.FinancingQuote
+	err := r.db.WithContext(ctx).
+		Where("invoice_id = ? AND expires_at > ?", invoiceID, validAt).
+		Order("created_at DESC").
+		First(&quote).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding financing quote for invoice %d: %v", invoiceID, err)
+		}
+		return nil, err
+	}
+	return &quote, nil
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error
+	Consume(ctx context.Context, userID uint, codeHash string) (bool, error)
+	CountUnused(ctx context.Context, userID uint) (int64, error)
+	DeleteForUser(ctx context.Context, userID uint) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceForUser removes every existing recovery code for the user and stores the new set atomically.
+func (r *recoveryCodeRepository) ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TwoFARecoveryCode{}).Error; err != nil {
+			log.Printf("Error deleting old recovery codes for user %d: %v", userID, err)
+			return err
+		}
+		codes := make([]models.TwoFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.TwoFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if err := tx.Create(&codes).Error; err != nil {
+			log.Printf("Error creating recovery codes for user %d: %v", userID, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// Consume marks a matching unused code as used. The conditional update guarantees a code
+// can only be redeemed once even under concurrent requests.
+func (r *recoveryCodeRepository) Consume(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.TwoFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Printf("Error consuming recovery code for user %d: %v", userID, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.TwoFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error; err != nil {
+		log.Printf("Error counting recovery codes for user %d: %v", userID, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *recoveryCodeRepository) DeleteForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.TwoFARecoveryCode{}).Error
+}
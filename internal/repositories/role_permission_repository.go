@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"invoiceB2B/internal/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// RolePermissionRepository stores which permissions each staff role holds.
+type RolePermissionRepository interface {
+	ListAll(ctx context.Context) ([]models.RolePermission, error)
+	ListByRole(ctx context.Context, role string) ([]string, error)
+	ReplaceForRole(ctx context.Context, role string, permissions []string) error
+	SeedIfEmpty(ctx context.Context, defaults map[string][]string) (bool, error)
+}
+
+type rolePermissionRepository struct {
+	db *gorm.DB
+}
+
+func NewRolePermissionRepository(db *gorm.DB) RolePermissionRepository {
+	return &rolePermissionRepository{db: db}
+}
+
+func (r *rolePermissionRepository) ListAll(ctx context.Context) ([]models.RolePermission, error) {
+	var grants []models.RolePermission
+	if err := r.db.WithContext(ctx).Order("role ASC, permission ASC").Find(&grants).Error; err != nil {
+		log.Printf("Error listing role permissions: %v", err)
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *rolePermissionRepository) ListByRole(ctx context.Context, role string) ([]string, error) {
+	var permissions []string
+	err := r.db.WithContext(ctx).Model(&models.RolePermission{}).
+		Where("role = ?", role).Order("permission ASC").Pluck("permission", &permissions).Error
+	if err != nil {
+		log.Printf("Error listing permissions of role %s: %v", role, err)
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// ReplaceForRole swaps the role's permissions for the given set atomically.
+func (r *rolePermissionRepository) ReplaceForRole(ctx context.Context, role string, permissions []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("role = ?", role).Delete(&models.RolePermission{}).Error; err != nil {
+			log.Printf("Error clearing permissions of role %s: %v", role, err)
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		grants := make([]models.RolePermission, 0, len(permissions))
+		for _, permission := range permissions {
+			grants = append(grants, models.RolePermission{Role: role, Permission: permission})
+		}
+		if err := tx.Create(&grants).Error; err != nil {
+			log.Printf("Error storing permissions of role %s: %v", role, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// SeedIfEmpty stores the default grants when no role has any permission yet. It reports
+// whether anything was seeded; once admins have edited the mapping it is left alone.
+func (r *rolePermissionRepository) SeedIfEmpty(ctx context.Context, defaults map[string][]string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.RolePermission{}).Count(&count).Error; err != nil {
+		log.Printf("Error counting role permissions: %v", err)
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	var grants []models.RolePermission
+	for role, permissions := range defaults {
+		for _, permission := range permissions {
+			grants = append(grants, models.RolePermission{Role: role, Permission: permission})
+		}
+	}
+	if err := r.db.WithContext(ctx).Create(&grants).Error; err != nil {
+		log.Printf("Error seeding default role permissions: %v", err)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StaffInvitationRepository manages pending invitations to create staff accounts.
+type StaffInvitationRepository interface {
+	Create(ctx context.Context, invitation *models.StaffInvitation) error
+	FindPendingByID(ctx context.Context, id uint) (*models.StaffInvitation, error)
+	FindPendingByTokenHash(ctx context.Context, tokenHash string) (*models.StaffInvitation, error)
+	FindPendingByEmail(ctx context.Context, email string) (*models.StaffInvitation, error)
+	ListPending(ctx context.Context) ([]models.StaffInvitation, error)
+	UpdateToken(ctx context.Context, invitation *models.StaffInvitation) error
+	Delete(ctx context.Context, id uint) (bool, error)
+	Accept(ctx context.Context, invitation *models.StaffInvitation, staff *models.Staff) error
+}
+
+type staffInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewStaffInvitationRepository(db *gorm.DB) StaffInvitationRepository {
+	return &staffInvitationRepository{db: db}
+}
+
+func (r *staffInvitationRepository) Create(ctx context.Context, invitation *models.StaffInvitation) error {
+	if err := r.db.WithContext(ctx).Create(invitation).Error; err != nil {
+		log.Printf("Error creating staff invitation for %s: %v", invitation.Email, err)
+		return err
+	}
+	return nil
+}
+
+// pending restricts a query to invitations that have been neither accepted nor revoked.
+// Expired invitations are included so that they can still be listed and resent.
+func (r *staffInvitationRepository) pending(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("InvitedBy").Where("accepted_at IS NULL")
+}
+
+func (r *staffInvitationRepository) FindPendingByID(ctx context.Context, id uint) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	if err := r.pending(ctx).First(&invitation, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding staff invitation %d: %v", id, err)
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindPendingByTokenHash only matches invitations that have not expired yet.
+func (r *staffInvitationRepository) FindPendingByTokenHash(ctx context.Context, tokenHash string) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	err := r.pending(ctx).Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).First(&invitation).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding staff invitation by token: %v", err)
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *staffInvitationRepository) FindPendingByEmail(ctx context.Context, email string) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	if err := r.pending(ctx).Where("LOWER(email) = LOWER(?)", email).First(&invitation).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Error finding staff invitation for %s: %v", email, err)
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *staffInvitationRepository) ListPending(ctx context.Context) ([]models.StaffInvitation, error) {
+	var invitations []models.StaffInvitation
+	if err := r.pending(ctx).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		log.Printf("Error listing staff invitations: %v", err)
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// UpdateToken stores a newly issued token and expiry, invalidating the previously emailed link.
+func (r *staffInvitationRepository) UpdateToken(ctx context.Context, invitation *models.StaffInvitation) error {
+	err := r.db.WithContext(ctx).Model(invitation).Updates(map[string]interface{}{
+		"token_hash": invitation.TokenHash,
+		"expires_at": invitation.ExpiresAt,
+	}).Error
+	if err != nil {
+		log.Printf("Error reissuing staff invitation %d: %v", invitation.ID, err)
+		return err
+	}
+	return nil
+}
+
+// Delete revokes a pending invitation. It returns false if none matched.
+func (r *staffInvitationRepository) Delete(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ? AND accepted_at IS NULL", id).Delete(&models.StaffInvitation{})
+	if result.Error != nil {
+		log.Printf("Error revoking staff invitation %d: %v", id, result.Error)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Accept creates the staff account and marks the invitation as used in one transaction. The
+// conditional update makes the token single-use.
+func (r *staffInvitationRepository) Accept(ctx context.Context, invitation *models.StaffInvitation, staff *models.Staff) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.StaffInvitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			log.Printf("Error marking staff invitation %d as accepted: %v", invitation.ID, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Create(staff).Error; err != nil {
+			log.Printf("Error creating staff for invitation %d: %v", invitation.ID, err)
+			return err
+		}
+		invitation.AcceptedAt = &now
+		return nil
+	})
+}
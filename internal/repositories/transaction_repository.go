@@ -2,15 +2,22 @@ package repositories
 
 import (
 	"context"
+	"fmt"
 	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
 	"log"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepository interface {
 	Create(ctx context.Context, transaction *models.Transaction) error
 	FindByInvoiceID(ctx context.Context, invoiceID uint) ([]models.Transaction, error)
+	SumRepayments(ctx context.Context, invoiceIDs []uint) (map[uint]money.Amount, error)
+	CreatePendingRepayment(ctx context.Context, repayment *models.Transaction) error
+	CompleteRepayment(ctx context.Context, repayment *models.Transaction, referenceID string, entry models.InvoiceStatusHistory) (models.InvoiceStatus, money.Amount, error)
+	VoidRepayment(ctx context.Context, repayment *models.Transaction) error
 	// Add other methods as needed, e.g., FindByID
 }
 
@@ -38,3 +45,150 @@ func (r *transactionRepository) FindByInvoiceID(ctx context.Context, invoiceID u
 	}
 	return transactions, nil
 }
+
+// SumRepayments totals the completed repayments on each of the invoices. Invoices without
+// any are left out of the map.
+func (r *transactionRepository) SumRepayments(ctx context.Context, invoiceIDs []uint) (map[uint]money.Amount, error) {
+	sums := make(map[uint]money.Amount, len(invoiceIDs))
+	if len(invoiceIDs) == 0 {
+		return sums, nil
+	}
+	rows, err := r.db.WithContext(ctx).Model(&models.Transaction{}).
+		Select("invoice_id, SUM(amount)").
+		Where("invoice_id IN ? AND type = ? AND status = ?", invoiceIDs, models.TransactionRepayment, models.TransactionCompleted).
+		Group("invoice_id").
+		Rows()
+	if err != nil {
+		log.Printf("Error summing repayments of invoices %v: %v", invoiceIDs, err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var invoiceID uint
+		var sum money.Amount
+		if err := rows.Scan(&invoiceID, &sum); err != nil {
+			log.Printf("Error reading repayment sums: %v", err)
+			return nil, err
+		}
+		sums[invoiceID] = sum
+	}
+	return sums, rows.Err()
+}
+
+// CreatePendingRepayment reserves a repayment on a disbursed invoice before the customer is
+// charged. The invoice row is locked while pending and completed repayments are checked
+// against what is owed, so concurrent repayments cannot together exceed the balance; an
+// overpayment fails with models.ErrRepaymentExceedsBalance.
+func (r *transactionRepository) CreatePendingRepayment(ctx context.Context, repayment *models.Transaction) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoice, err := lockInvoice(tx, repayment.InvoiceID)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != models.InvoiceDisbursed && invoice.Status != models.InvoiceRepaymentPending {
+			return fmt.Errorf("%w: invoice %d is %s", models.ErrInvalidInvoiceTransition, invoice.ID, invoice.Status)
+		}
+		reserved, err := sumInvoiceRepayments(tx, invoice.ID, models.TransactionPending, models.TransactionCompleted)
+		if err != nil {
+			return err
+		}
+		if repayment.Amount.GreaterThan(invoice.RepaymentDue().Sub(reserved)) {
+			return models.ErrRepaymentExceedsBalance
+		}
+
+		repayment.Type = models.TransactionRepayment
+		repayment.Status = models.TransactionPending
+		if err := tx.Create(repayment).Error; err != nil {
+			log.Printf("Error creating pending repayment for invoice %d: %v", invoice.ID, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// CompleteRepayment confirms a pending repayment once the provider has taken the payment and
+// moves the invoice to repayment_pending, or to repaid once nothing is outstanding. It returns
+// the invoice's new status and the balance still outstanding.
+func (r *transactionRepository) CompleteRepayment(ctx context.Context, repayment *models.Transaction, referenceID string, entry models.InvoiceStatusHistory) (models.InvoiceStatus, money.Amount, error) {
+	var status models.InvoiceStatus
+	var outstanding money.Amount
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoice, err := lockInvoice(tx, repayment.InvoiceID)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(repayment).Where("status = ?", models.TransactionPending).
+			Updates(map[string]interface{}{"status": models.TransactionCompleted, "reference_id": referenceID})
+		if result.Error != nil {
+			log.Printf("Error completing repayment %d: %v", repayment.ID, result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		repaid, err := sumInvoiceRepayments(tx, invoice.ID, models.TransactionCompleted)
+		if err != nil {
+			return err
+		}
+		outstanding = invoice.RepaymentDue().Sub(repaid)
+		// The customer has paid, so the repayment stands even if staff settled the invoice meanwhile
+		status = invoice.Status
+		if status != models.InvoiceDisbursed && status != models.InvoiceRepaymentPending {
+			return nil
+		}
+		status = models.InvoiceRepaymentPending
+		if !outstanding.IsPositive() {
+			status = models.InvoiceRepaid
+		}
+		if status == invoice.Status {
+			return nil
+		}
+		if err := models.ValidateInvoiceTransition(invoice.Status, status); err != nil {
+			return err
+		}
+		if err := tx.Model(invoice).Update("status", status).Error; err != nil {
+			log.Printf("Error moving invoice %d to %s: %v", invoice.ID, status, err)
+			return err
+		}
+		return recordInvoiceTransition(tx, invoice.ID, invoice.Status, status, entry)
+	})
+	if err != nil {
+		return "", money.Zero, err
+	}
+	return status, outstanding, nil
+}
+
+// VoidRepayment releases a pending repayment whose payment failed.
+func (r *transactionRepository) VoidRepayment(ctx context.Context, repayment *models.Transaction) error {
+	err := r.db.WithContext(ctx).Model(repayment).Where("status = ?", models.TransactionPending).
+		Update("status", models.TransactionVoided).Error
+	if err != nil {
+		log.Printf("Error voiding repayment %d: %v", repayment.ID, err)
+		return err
+	}
+	return nil
+}
+
+// lockInvoice loads an invoice with SELECT ... FOR UPDATE, holding the row for the rest of tx.
+func lockInvoice(tx *gorm.DB, invoiceID uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, invoiceID).Error; err != nil {
+		log.Printf("Error locking invoice %d for repayment: %v", invoiceID, err)
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func sumInvoiceRepayments(tx *gorm.DB, invoiceID uint, statuses ...models.TransactionStatus) (money.Amount, error) {
+	var sum money.Amount
+	err := tx.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("invoice_id = ? AND type = ? AND status IN ?", invoiceID, models.TransactionRepayment, statuses).
+		Row().Scan(&sum)
+	if err != nil {
+		log.Printf("Error summing repayments of invoice %d: %v", invoiceID, err)
+		return money.Zero, err
+	}
+	return sum, nil
+}
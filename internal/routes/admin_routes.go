@@ -4,6 +4,8 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"invoiceB2B/internal/handlers"
 	"invoiceB2B/internal/middleware"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/services"
 )
 
 // SetupAdminRoutes configures the routes for admin-specific operations.
@@ -14,37 +16,96 @@ func SetupAdminRoutes(
 	adminMw *middleware.AdminMiddleware,
 ) {
 	adminGroup := router.Group("/admin")
+
+	// Public: the emailed invitation token is the credential. Registered before the
+	// authentication middleware below so that it is matched first.
+	adminGroup.Post("/staff/invitations/accept/2fa", adminHandler.BeginStaffInvitation2FA)
+	adminGroup.Post("/staff/invitations/accept", adminHandler.AcceptStaffInvitation)
+
 	adminGroup.Use(authMw.Protected())
+	adminGroup.Use(authMw.RequirePrincipal(services.PrincipalTypeStaff))
 	adminGroup.Use(adminMw.AdminRequired())
 
 	// --- Admin Profile ---
 	adminGroup.Get("/profile/me", adminHandler.GetAdminProfile)
+	adminGroup.Put("/profile/password", adminHandler.ChangePassword)
+	adminGroup.Get("/profile/sessions", adminHandler.ListOwnSessions)
+	adminGroup.Delete("/profile/sessions/:id", adminHandler.RevokeOwnSession)
 
 	// --- Admin User & KYC Management ---
 	adminUsersGroup := adminGroup.Group("/users")
-	adminUsersGroup.Get("", adminHandler.GetAllUsers)
-	adminUsersGroup.Get("/:id", adminHandler.GetUserByID)
-	adminUsersGroup.Get("/:id/kyc", adminHandler.GetUserKYCDetail)
-	adminUsersGroup.Put("/:id/kyc/review", adminHandler.ReviewKYC)
-	adminUsersGroup.Get("/:id/activity-logs", adminHandler.GetUserActivityLogs)
+	adminUsersGroup.Get("", adminMw.RequirePermission(models.PermUserRead), adminHandler.GetAllUsers)
+	adminUsersGroup.Get("/:id", adminMw.RequirePermission(models.PermUserRead), adminHandler.GetUserByID)
+	adminUsersGroup.Get("/:id/kyc", adminMw.RequirePermission(models.PermUserRead), adminHandler.GetUserKYCDetail)
+	adminUsersGroup.Put("/:id/kyc/review", adminMw.RequirePermission(models.PermKYCReview), adminHandler.ReviewKYC)
+	adminUsersGroup.Get("/:id/activity-logs", adminMw.RequirePermission(models.PermLogsRead), adminHandler.GetUserActivityLogs)
+	adminUsersGroup.Delete("/:id/sessions", adminMw.RequirePermission(models.PermUserManage), adminHandler.RevokeUserSessions)
+	adminUsersGroup.Post("/:id/impersonate", adminMw.RequirePermission(models.PermUserImpersonate), adminHandler.ImpersonateUser)
+	adminUsersGroup.Delete("/:id/impersonate/:sessionId", adminMw.RequirePermission(models.PermUserImpersonate), adminHandler.StopImpersonation)
+	adminGroup.Get("/organizations/:id/kyc", adminMw.RequirePermission(models.PermUserRead), adminHandler.GetOrganizationKYCDetail)
+	adminGroup.Put("/organizations/:id/kyc/review", adminMw.RequirePermission(models.PermKYCReview), adminHandler.ReviewOrganizationKYC)
 
 	// --- Admin Invoice Management ---
 	adminInvoicesGroup := adminGroup.Group("/invoices")
-	adminInvoicesGroup.Get("", adminHandler.GetAllInvoices)
-	adminInvoicesGroup.Get("/:id", adminHandler.GetInvoiceDetail)
-	adminInvoicesGroup.Put("/:id/status", adminHandler.UpdateInvoiceStatus)
-	adminInvoicesGroup.Post("/:id/receipt", adminHandler.UploadDisbursementReceipt)
-	adminInvoicesGroup.Get("/:id/download-pdf", adminHandler.DownloadInvoicePDF)
+	adminInvoicesGroup.Get("", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.GetAllInvoices)
+	adminInvoicesGroup.Get("/:id", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.GetInvoiceDetail)
+	adminInvoicesGroup.Get("/:id/history", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.GetInvoiceHistory)
+	adminInvoicesGroup.Get("/:id/pricing", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.GetInvoicePricing)
+	// Approve/reject vs. disburse/repay is checked in the handler against the requested status
+	adminInvoicesGroup.Put("/:id/status", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.UpdateInvoiceStatus)
+	adminInvoicesGroup.Post("/:id/receipt", adminMw.RequirePermission(models.PermInvoiceDisburse), adminHandler.UploadDisbursementReceipt)
+	adminInvoicesGroup.Get("/:id/download-pdf", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.DownloadInvoicePDF)
+	adminInvoicesGroup.Post("/:id/disbursements", adminMw.RequirePermission(models.PermInvoiceDisburse), adminHandler.RequestDisbursement)
+
+	adminGroup.Get("/repayments/outstanding", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.GetOutstandingBalances)
+
+	// --- Admin Disbursement Approvals (maker-checker) ---
+	adminDisbursementsGroup := adminGroup.Group("/disbursements", adminMw.RequirePermission(models.PermInvoiceDisburse))
+	adminDisbursementsGroup.Get("/pending", adminHandler.GetPendingDisbursements)
+	adminDisbursementsGroup.Post("/:id/approve", adminHandler.ApproveDisbursement)
+	adminDisbursementsGroup.Post("/:id/reject", adminHandler.RejectDisbursement)
+
+	// --- Admin Approval Limits & Escalations ---
+	adminGroup.Get("/escalations", adminMw.RequirePermission(models.PermInvoiceRead), adminHandler.GetEscalations)
+	adminLimitsGroup := adminGroup.Group("/approval-limits", adminMw.RequirePermission(models.PermRolesManage))
+	adminLimitsGroup.Get("", adminHandler.GetApprovalLimits)
+	adminLimitsGroup.Put("", adminHandler.SetApprovalLimit)
+	adminLimitsGroup.Delete("/:id", adminHandler.DeleteApprovalLimit)
+
+	// --- Admin Pricing & Risk Tiers ---
+	adminPricingPlansGroup := adminGroup.Group("/pricing-plans", adminMw.RequirePermission(models.PermPricingManage))
+	adminPricingPlansGroup.Get("", adminHandler.GetPricingPlans)
+	adminPricingPlansGroup.Put("", adminHandler.SetPricingPlan)
+	adminPricingPlansGroup.Delete("/:id", adminHandler.DeletePricingPlan)
+	adminDebtorRatingsGroup := adminGroup.Group("/debtor-ratings", adminMw.RequirePermission(models.PermPricingManage))
+	adminDebtorRatingsGroup.Get("", adminHandler.GetDebtorRatings)
+	adminDebtorRatingsGroup.Put("", adminHandler.SetDebtorRating)
+	adminDebtorRatingsGroup.Delete("/:id", adminHandler.DeleteDebtorRating)
+	adminGroup.Put("/organizations/:id/risk-tier", adminMw.RequirePermission(models.PermPricingManage), adminHandler.SetOrganizationRiskTier)
 
 	// --- Admin Staff Management ---
-	adminStaffGroup := adminGroup.Group("/staff")
-	adminStaffGroup.Post("", adminHandler.CreateStaff)
+	adminStaffGroup := adminGroup.Group("/staff", adminMw.RequirePermission(models.PermStaffManage))
 	adminStaffGroup.Get("", adminHandler.GetAllStaff)
+	adminStaffGroup.Get("/invitations", adminHandler.GetStaffInvitations)
+	adminStaffGroup.Post("/invitations", adminHandler.InviteStaff)
+	adminStaffGroup.Post("/invitations/:id/resend", adminHandler.ResendStaffInvitation)
+	adminStaffGroup.Delete("/invitations/:id", adminHandler.RevokeStaffInvitation)
 	adminStaffGroup.Put("/:id", adminHandler.UpdateStaff)
 	adminStaffGroup.Delete("/:id", adminHandler.DeleteStaff)
+	adminStaffGroup.Delete("/:id/2fa", adminHandler.ResetStaff2FA)
+
+	// --- Admin Login Lockouts ---
+	adminSecurityGroup := adminGroup.Group("/security", adminMw.RequirePermission(models.PermUserManage))
+	adminSecurityGroup.Get("/locked-accounts", adminHandler.GetLockedAccounts)
+	adminSecurityGroup.Post("/locked-accounts/unlock", adminHandler.UnlockAccount)
 
 	// --- Admin Activity Logs & Analytics ---
-	adminGroup.Get("/activity-logs", adminHandler.GetActivityLogs)
+	adminGroup.Get("/activity-logs", adminMw.RequirePermission(models.PermLogsRead), adminHandler.GetActivityLogs)
 	// Dashboard analytics
-	adminGroup.Get("/dashboard/analytics", adminHandler.GetAdminDashboardAnalytics)
+	adminGroup.Get("/dashboard/analytics", adminMw.RequirePermission(models.PermAnalyticsRead), adminHandler.GetAdminDashboardAnalytics)
+
+	// --- Admin Role Permissions ---
+	adminGroup.Get("/permissions", adminMw.RequirePermission(models.PermRolesManage), adminHandler.GetPermissionCatalogue)
+	adminGroup.Get("/roles", adminMw.RequirePermission(models.PermRolesManage), adminHandler.GetRolePermissions)
+	adminGroup.Put("/roles/:role/permissions", adminMw.RequirePermission(models.PermRolesManage), adminHandler.UpdateRolePermissions)
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"invoiceB2B/internal/handlers"
 	"invoiceB2B/internal/middleware"
+	"invoiceB2B/internal/services"
 )
 
 func SetupAuthRoutes(router fiber.Router, authHandler *handlers.AuthHandler, authMw *middleware.AuthMiddleware) {
@@ -13,12 +14,29 @@ func SetupAuthRoutes(router fiber.Router, authHandler *handlers.AuthHandler, aut
 	authGroup.Post("/login", authHandler.Login)
 	authGroup.Post("/login/2fa/verify", authHandler.Verify2FA) // Verify OTP after login attempt
 
+	// Forced 2FA enrollment for staff, authorised by the enrollment token returned from login
+	authGroup.Post("/staff/2fa/enroll", authHandler.BeginStaff2FAEnrollment)
+	authGroup.Post("/staff/2fa/enroll/confirm", authHandler.ConfirmStaff2FAEnrollment)
+
 	authGroup.Post("/refresh-token", authHandler.RefreshToken)
 
-	// Routes requiring authentication
+	authGroup.Post("/password/forgot", authHandler.ForgotPassword)
+	authGroup.Post("/password/reset", authHandler.ResetPassword)
+	authGroup.Post("/verify-email", authHandler.VerifyEmail)
+	authGroup.Post("/unlock", authHandler.UnlockAccount) // Redeem the link emailed on lockout
+
+	// Routes requiring authentication (any principal)
 	authRequired := authGroup.Group("")
 	authRequired.Use(authMw.Protected()) // Apply JWT auth middleware
 
 	authRequired.Post("/logout", authHandler.Logout)
-	authRequired.Post("/2fa/toggle", authHandler.Enable2FA) // Enable/disable 2FA for logged-in user
+
+	// Routes for end users only
+	userOnly := authRequired.Group("", authMw.RequirePrincipal(services.PrincipalTypeUser))
+	userOnly.Post("/verify-email/resend", authHandler.ResendVerificationEmail)
+	userOnly.Post("/2fa/toggle", authHandler.Enable2FA) // Enable/disable 2FA for logged-in user
+	userOnly.Post("/2fa/totp/setup", authHandler.SetupTOTP)
+	userOnly.Post("/2fa/totp/confirm", authHandler.ConfirmTOTP)
+	userOnly.Put("/2fa/method", authHandler.Update2FAMethod) // Choose between email OTP and authenticator app
+	userOnly.Post("/2fa/recovery-codes/regenerate", authHandler.RegenerateRecoveryCodes)
 }
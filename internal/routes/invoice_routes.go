@@ -4,15 +4,26 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"invoiceB2B/internal/handlers"
 	"invoiceB2B/internal/middleware"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/services"
 )
 
 func SetupInvoiceRoutes(router fiber.Router, invoiceHandler *handlers.InvoiceHandler, authMw *middleware.AuthMiddleware, adminMw *middleware.AdminMiddleware) {
 	userInvoiceGroup := router.Group("/invoices")
-	userInvoiceGroup.Use(authMw.Protected())
+	userInvoiceGroup.Use(authMw.ProtectedOrAPIKey()) // Personal API keys are accepted here for ERP integrations
+	userInvoiceGroup.Use(authMw.RequirePrincipal(services.PrincipalTypeUser))
 
-	userInvoiceGroup.Post("", invoiceHandler.UploadInvoice)
-	userInvoiceGroup.Get("", invoiceHandler.GetUserInvoices)
-	userInvoiceGroup.Get("/:id", invoiceHandler.GetInvoiceByID)
-	userInvoiceGroup.Get("/:id/viewreceipt", invoiceHandler.ViewReceipt)
-	userInvoiceGroup.Get("/:id/receipt", invoiceHandler.DownloadReceipt)
+	read := authMw.RequireAPIKeyScope(models.APIKeyScopeInvoicesRead)
+	write := authMw.RequireAPIKeyScope(models.APIKeyScopeInvoicesWrite)
+	repay := authMw.RequireAPIKeyScope(models.APIKeyScopeRepaymentsWrite)
+
+	userInvoiceGroup.Post("", write, invoiceHandler.UploadInvoice)
+	userInvoiceGroup.Post("/quote", write, invoiceHandler.QuoteFinancing)
+	userInvoiceGroup.Get("", read, invoiceHandler.GetUserInvoices)
+	userInvoiceGroup.Get("/:id", read, invoiceHandler.GetInvoiceByID)
+	userInvoiceGroup.Get("/:id/history", read, invoiceHandler.GetInvoiceHistory)
+	userInvoiceGroup.Get("/:id/quote", read, invoiceHandler.GetInvoiceQuote)
+	userInvoiceGroup.Post("/:id/repayments", repay, invoiceHandler.RepayInvoice)
+	userInvoiceGroup.Get("/:id/viewreceipt", read, invoiceHandler.ViewReceipt)
+	userInvoiceGroup.Get("/:id/receipt", read, invoiceHandler.DownloadReceipt)
 }
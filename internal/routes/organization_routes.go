@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"invoiceB2B/internal/handlers"
+	"invoiceB2B/internal/middleware"
+	"invoiceB2B/internal/services"
+)
+
+func SetupOrganizationRoutes(router fiber.Router, orgHandler *handlers.OrganizationHandler, authMw *middleware.AuthMiddleware) {
+	orgGroup := router.Group("/organization")
+
+	orgGroup.Post("/invitations/accept", orgHandler.AcceptInvitation) // Public; authorised by the emailed token
+
+	// Routes for members of the caller's organisation
+	memberGroup := orgGroup.Group("", authMw.Protected(), authMw.RequirePrincipal(services.PrincipalTypeUser))
+	memberGroup.Get("", orgHandler.GetOrganization)
+	memberGroup.Put("", orgHandler.UpdateOrganization)
+	memberGroup.Get("/members", orgHandler.ListMembers)
+	memberGroup.Put("/members/:userId", orgHandler.UpdateMemberRole)
+	memberGroup.Delete("/members/:userId", orgHandler.RemoveMember)
+	memberGroup.Get("/invitations", orgHandler.ListInvitations)
+	memberGroup.Post("/invitations", orgHandler.InviteMember)
+	memberGroup.Delete("/invitations/:id", orgHandler.RevokeInvitation)
+}
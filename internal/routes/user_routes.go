@@ -4,14 +4,23 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"invoiceB2B/internal/handlers"
 	"invoiceB2B/internal/middleware"
+	"invoiceB2B/internal/services"
 )
 
 func SetupUserRoutes(router fiber.Router, userHandler *handlers.UserHandler, authMw *middleware.AuthMiddleware) {
 	userGroup := router.Group("/user")
 	userGroup.Use(authMw.Protected()) // All user routes require authentication
+	userGroup.Use(authMw.RequirePrincipal(services.PrincipalTypeUser))
 
 	userGroup.Get("/profile", userHandler.GetUserProfile)
 	userGroup.Put("/profile", userHandler.UpdateUserProfile)
+	userGroup.Put("/password", userHandler.ChangePassword)
+	userGroup.Get("/sessions", userHandler.ListSessions)
+	userGroup.Delete("/sessions/:id", userHandler.RevokeSession)
+
+	userGroup.Get("/api-keys", userHandler.ListAPIKeys)
+	userGroup.Post("/api-keys", userHandler.CreateAPIKey)
+	userGroup.Delete("/api-keys/:id", userHandler.RevokeAPIKey)
 
 	kycGroup := userGroup.Group("/kyc")
 	kycGroup.Post("", userHandler.SubmitKYC)
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"invoiceB2B/internal/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupWellKnownRoutes(router fiber.Router, jwksHandler *handlers.JWKSHandler) {
+	wellKnownGroup := router.Group("/.well-known")
+
+	wellKnownGroup.Get("/jwks.json", jwksHandler.GetJWKS)
+}
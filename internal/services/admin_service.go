@@ -2,15 +2,19 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"invoiceB2B/internal/config"
 	"invoiceB2B/internal/dtos"
 	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
 	"invoiceB2B/internal/repositories"
 	"log"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -19,20 +23,46 @@ import (
 // --- Error Definitions (assuming these are or will be defined centrally) ---
 var (
 	ErrStaffNotFound       = errors.New("staff not found")
+	ErrStaffExists         = errors.New("staff with this email already exists")
 	ErrKYCNotFound         = errors.New("kyc record not found for user")
 	ErrPDFGenerationFailed = errors.New("failed to generate PDF")
 	ErrServiceNotAvailable = errors.New("a required service is not available")
+
+	ErrDisbursementNotFound         = errors.New("pending disbursement request not found")
+	ErrDisbursementAlreadyPending   = errors.New("a disbursement request is already pending for this invoice")
+	ErrDisbursementSelfApproval     = errors.New("a disbursement must be approved by a different staff member than the one who requested it")
+	ErrFinancedAmountExceedsInvoice = errors.New("financed amount cannot exceed the invoice amount")
+	ErrApprovalLimitNotFound        = errors.New("approval limit not found")
 )
 
+// ApprovalLimitExceededError is returned when a staff member tries to approve or disburse more
+// than their role's ApprovalLimit allows. By the time it is returned the invoice has been
+// queued for escalation.
+type ApprovalLimitExceededError struct {
+	Role         string
+	Currency     string
+	Limit        money.Amount
+	Amount       money.Amount
+	EscalationID uint
+	NoLimit      bool // The role has limits, but none in this currency
+}
+
+func (e *ApprovalLimitExceededError) Error() string {
+	if e.NoLimit {
+		return fmt.Sprintf("role %s has no approval limit in %s for amount %s %s", e.Role, e.Currency, e.Amount.Format(e.Currency), e.Currency)
+	}
+	return fmt.Sprintf("amount %s %s exceeds the %s %s approval limit of role %s", e.Amount.Format(e.Currency), e.Currency, e.Limit.Format(e.Currency), e.Currency, e.Role)
+}
+
 // --- DTO Definitions (conceptual, should be in dtos package) ---
 
 // dtos.AdminDashboardAnalytics
 type AdminDashboardAnalytics struct {
-	TotalUsers           int64            `json:"totalUsers"`
-	KYCStats             KYCAnalytics     `json:"kycStats"`
-	InvoiceStats         InvoiceAnalytics `json:"invoiceStats"`
-	TotalFinancedAmount  float64          `json:"totalFinancedAmount"`
-	TotalDisbursedAmount float64          `json:"totalDisbursedAmount"`
+	TotalUsers           int64                   `json:"totalUsers"`
+	KYCStats             KYCAnalytics            `json:"kycStats"`
+	InvoiceStats         InvoiceAnalytics        `json:"invoiceStats"`
+	TotalFinancedAmount  map[string]money.Amount `json:"totalFinancedAmount"`  // By currency
+	TotalDisbursedAmount map[string]money.Amount `json:"totalDisbursedAmount"` // By currency
 	// TotalRepaidAmount could be sum of transactions of type REPAYMENT
 }
 
@@ -51,7 +81,7 @@ type InvoiceAnalytics struct {
 	Disbursed        int64 `json:"disbursed"`        // Status = DISBURSED
 	RepaymentPending int64 `json:"repaymentPending"` // Status = REPAYMENT_PENDING
 	Repaid           int64 `json:"repaid"`           // Status = REPAID
-	Overdue          int64 `json:"overdue"`          // Status = DISBURSED and DueDate < Now
+	Overdue          int64 `json:"overdue"`          // Status = DISBURSED or REPAYMENT_PENDING and DueDate < Now
 }
 
 // dtos.InvoicePDFResponse
@@ -75,21 +105,48 @@ type AdminService interface {
 	GetUserByID(ctx context.Context, userID uint) (*dtos.UserResponse, error)
 	GetUserKYCDetail(ctx context.Context, userID uint) (*dtos.AdminKYCDetailResponse, error)
 	ReviewKYC(ctx context.Context, userID, reviewerStaffID uint, req dtos.AdminKYCReviewRequest) (*dtos.AdminKYCDetailResponse, error)
+	GetOrganizationKYCDetail(ctx context.Context, organizationID uint) (*dtos.AdminKYCDetailResponse, error)
+	ReviewOrganizationKYC(ctx context.Context, organizationID, reviewerStaffID uint, req dtos.AdminKYCReviewRequest) (*dtos.AdminKYCDetailResponse, error)
 
 	// Invoice Management
 	GetAllInvoices(ctx context.Context, page, pageSize int, statusFilter string) ([]dtos.InvoiceResponse, int64, error)
 	GetInvoiceDetail(ctx context.Context, invoiceID uint) (*dtos.InvoiceResponse, error)
+	GetInvoiceHistory(ctx context.Context, invoiceID uint) ([]dtos.InvoiceStatusHistoryResponse, error)
+	GetInvoicePricing(ctx context.Context, invoiceID uint) (*dtos.PricingBreakdownResponse, error)
+	GetOutstandingBalances(ctx context.Context, page, pageSize int) ([]dtos.OutstandingBalanceResponse, int64, error)
 	UpdateInvoiceStatus(ctx context.Context, invoiceID, adminStaffID uint, req dtos.AdminInvoiceUpdateRequest) (*dtos.InvoiceResponse, error)
 	UploadDisbursementReceipt(ctx context.Context, invoiceID, adminStaffID uint, req dtos.AdminUploadReceiptRequest) (*dtos.InvoiceResponse, error)
 	DownloadInvoicePDF(ctx context.Context, invoiceID, adminStaffID uint) (*InvoicePDFResponse, error)
 
+	// Disbursement Approvals
+	RequestDisbursement(ctx context.Context, invoiceID, makerStaffID uint, req dtos.RequestDisbursementRequest) (*dtos.DisbursementApprovalResponse, error)
+	GetPendingDisbursements(ctx context.Context) ([]dtos.DisbursementApprovalResponse, error)
+	ApproveDisbursement(ctx context.Context, approvalID, checkerStaffID uint) (*dtos.DisbursementApprovalResponse, error)
+	RejectDisbursement(ctx context.Context, approvalID, checkerStaffID uint, req dtos.RejectDisbursementRequest) (*dtos.DisbursementApprovalResponse, error)
+
+	// Approval Limits & Escalations
+	GetApprovalLimits(ctx context.Context) ([]dtos.ApprovalLimitResponse, error)
+	SetApprovalLimit(ctx context.Context, adminStaffID uint, req dtos.SetApprovalLimitRequest) (*dtos.ApprovalLimitResponse, error)
+	DeleteApprovalLimit(ctx context.Context, adminStaffID, limitID uint) error
+	GetOpenEscalations(ctx context.Context) ([]dtos.InvoiceEscalationResponse, error)
+
 	// Staff Management
 	CreateStaff(ctx context.Context, req dtos.CreateStaffRequest) (*dtos.StaffResponse, error)
+	InviteStaff(ctx context.Context, adminStaffID uint, req dtos.InviteStaffRequest) (*dtos.StaffInvitationResponse, error)
+	GetPendingStaffInvitations(ctx context.Context) ([]dtos.StaffInvitationResponse, error)
+	ResendStaffInvitation(ctx context.Context, adminStaffID, invitationID uint) (*dtos.StaffInvitationResponse, error)
+	RevokeStaffInvitation(ctx context.Context, adminStaffID, invitationID uint) error
+	BeginStaffInvitation2FA(ctx context.Context, token string) (*dtos.StaffTwoFAEnrollmentResponse, error)
+	AcceptStaffInvitation(ctx context.Context, req dtos.AcceptStaffInvitationRequest) (*dtos.StaffResponse, error)
 	GetStaffByID(ctx context.Context, staffID uint) (*dtos.StaffResponse, error) // New method for GetAdminProfile
 	GetAllStaff(ctx context.Context, page, pageSize int) ([]dtos.StaffResponse, int64, error)
 	UpdateStaff(ctx context.Context, staffID uint, req dtos.UpdateStaffRequest) (*dtos.StaffResponse, error)
 	DeleteStaff(ctx context.Context, staffID uint) error
 
+	// Login Lockouts
+	GetLockedAccounts(ctx context.Context) ([]dtos.LockedAccountResponse, error)
+	UnlockAccount(ctx context.Context, adminStaffID uint, email string) error
+
 	// Activity Logs & Analytics
 	GetActivityLogs(ctx context.Context, page, pageSize int, filters map[string]string) ([]dtos.ActivityLogResponse, int64, error)
 	GetUserActivityLogs(ctx context.Context, userID uint, page, pageSize int, filters map[string]string) ([]dtos.ActivityLogResponse, int64, error)
@@ -99,41 +156,68 @@ type AdminService interface {
 type adminService struct {
 	userRepo        repositories.UserRepository
 	kycRepo         repositories.KYCRepository
+	orgRepo         repositories.OrganizationRepository
 	staffRepo       repositories.StaffRepository
+	staffInviteRepo repositories.StaffInvitationRepository
 	invoiceRepo     repositories.InvoiceRepository
 	transactionRepo repositories.TransactionRepository
+	approvalRepo    repositories.DisbursementApprovalRepository
+	limitRepo       repositories.ApprovalLimitRepository
+	escalationRepo  repositories.InvoiceEscalationRepository
 	activityLogSvc  ActivityLogService
 	emailService    EmailService
 	notificationSvc NotificationService
 	fileService     FileService
 	pdfService      PDFService
+	loginGuard      LoginGuardService
+	pricingSvc      PricingService
+	otpService      OTPService
+	totpService     TOTPService
 	cfg             *config.Config
 }
 
 func NewAdminService(
 	userRepo repositories.UserRepository,
 	kycRepo repositories.KYCRepository,
+	orgRepo repositories.OrganizationRepository,
 	staffRepo repositories.StaffRepository,
+	staffInviteRepo repositories.StaffInvitationRepository,
 	invoiceRepo repositories.InvoiceRepository,
 	transactionRepo repositories.TransactionRepository,
+	approvalRepo repositories.DisbursementApprovalRepository,
+	limitRepo repositories.ApprovalLimitRepository,
+	escalationRepo repositories.InvoiceEscalationRepository,
 	activityLogSvc ActivityLogService,
 	emailService EmailService,
 	notificationSvc NotificationService,
 	fileService FileService,
 	pdfService PDFService,
+	loginGuard LoginGuardService,
+	pricingSvc PricingService,
+	otpService OTPService,
+	totpService TOTPService,
 	cfg *config.Config,
 ) AdminService {
 	return &adminService{
 		userRepo:        userRepo,
 		kycRepo:         kycRepo,
+		orgRepo:         orgRepo,
 		staffRepo:       staffRepo,
+		staffInviteRepo: staffInviteRepo,
 		invoiceRepo:     invoiceRepo,
 		transactionRepo: transactionRepo,
+		approvalRepo:    approvalRepo,
+		limitRepo:       limitRepo,
+		escalationRepo:  escalationRepo,
 		activityLogSvc:  activityLogSvc,
 		emailService:    emailService,
 		notificationSvc: notificationSvc,
 		fileService:     fileService,
 		pdfService:      pdfService,
+		loginGuard:      loginGuard,
+		pricingSvc:      pricingSvc,
+		otpService:      otpService,
+		totpService:     totpService,
 		cfg:             cfg,
 	}
 }
@@ -141,13 +225,15 @@ func NewAdminService(
 // --- Helper Functions (existing ones renamed with 'local' prefix) ---
 func localMapModelUserToUserResponse(user *models.User) dtos.UserResponse {
 	return dtos.UserResponse{
-		ID:           user.ID,
-		Email:        user.Email,
-		FirstName:    user.FirstName,
-		LastName:     user.LastName,
-		CompanyName:  user.CompanyName,
-		IsActive:     user.IsActive,
-		TwoFAEnabled: user.TwoFAEnabled,
+		ID:            user.ID,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		CompanyName:   user.CompanyName,
+		IsActive:      user.IsActive,
+		EmailVerified: user.IsEmailVerified(),
+		TwoFAEnabled:  user.TwoFAEnabled,
+		TwoFAMethod:   user.TwoFAMethod,
 	}
 }
 
@@ -209,7 +295,7 @@ func localMapInvoiceToResponse(invoice *models.Invoice) dtos.InvoiceResponse {
 		IssuerBankAccount:       invoice.IssuerBankAccount,
 		IssuerBankName:          invoice.IssuerBankName,
 		DebtorName:              invoice.DebtorName,
-		Amount:                  invoice.Amount,
+		Amount:                  invoice.Amount.Round(invoice.Currency),
 		Currency:                invoice.Currency,
 		DueDate:                 invoice.DueDate,
 		Status:                  invoice.Status,
@@ -219,7 +305,8 @@ func localMapInvoiceToResponse(invoice *models.Invoice) dtos.InvoiceResponse {
 		ApprovedAt:              invoice.ApprovedAt,
 		DisbursedAt:             invoice.DisbursedAt,
 		FinancingFeePercentage:  invoice.FinancingFeePercentage,
-		FinancedAmount:          invoice.FinancedAmount,
+		FinancedAmount:          roundedAmount(invoice.FinancedAmount, invoice.Currency),
+		Pricing:                 mapPricingBreakdown(invoice.Pricing, invoice.Currency),
 		DisbursementReceiptPath: receiptPath,
 		CreatedAt:               invoice.CreatedAt,
 		UpdatedAt:               invoice.UpdatedAt,
@@ -251,20 +338,24 @@ func (s *adminService) GetUserByID(ctx context.Context, userID uint) (*dtos.User
 	return &resp, nil
 }
 
+// GetUserKYCDetail returns the KYC record of the organisation the user belongs to.
 func (s *adminService) GetUserKYCDetail(ctx context.Context, userID uint) (*dtos.AdminKYCDetailResponse, error) {
-	user, err := s.userRepo.FindByID(ctx, userID)
+	organizationID, err := s.organizationIDForUser(ctx, userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrUserNotFound
-		}
-		return nil, fmt.Errorf("failed to find user for KYC detail: %w", err)
+		return nil, err
 	}
-	kyc, err := s.kycRepo.FindByUserID(ctx, userID)
+	return s.GetOrganizationKYCDetail(ctx, organizationID)
+}
+
+// GetOrganizationKYCDetail returns an organisation's KYC record with the member who submitted it.
+func (s *adminService) GetOrganizationKYCDetail(ctx context.Context, organizationID uint) (*dtos.AdminKYCDetailResponse, error) {
+	kyc, err := s.findOrganizationKYC(ctx, organizationID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrKYCNotFound
-		}
-		return nil, fmt.Errorf("failed to find KYC by user ID %d: %w", userID, err)
+		return nil, err
+	}
+	submitter, err := s.userRepo.FindByID(ctx, kyc.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find submitter of KYC %d: %w", kyc.ID, err)
 	}
 
 	var reviewerEmail *string
@@ -277,24 +368,29 @@ func (s *adminService) GetUserKYCDetail(ctx context.Context, userID uint) (*dtos
 		}
 	}
 
-	resp := localMapModelKYCToAdminResponse(kyc, user.Email, reviewerEmail)
+	resp := localMapModelKYCToAdminResponse(kyc, submitter.Email, reviewerEmail)
 	return &resp, nil
 }
 
+// ReviewKYC reviews the KYC record of the organisation the user belongs to.
 func (s *adminService) ReviewKYC(ctx context.Context, userID, reviewerStaffID uint, req dtos.AdminKYCReviewRequest) (*dtos.AdminKYCDetailResponse, error) {
-	user, err := s.userRepo.FindByID(ctx, userID)
+	organizationID, err := s.organizationIDForUser(ctx, userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrUserNotFound
-		}
-		return nil, fmt.Errorf("failed to find user for KYC review: %w", err)
+		return nil, err
 	}
-	kyc, err := s.kycRepo.FindByUserID(ctx, userID)
+	return s.ReviewOrganizationKYC(ctx, organizationID, reviewerStaffID, req)
+}
+
+// ReviewOrganizationKYC approves or rejects an organisation's KYC and notifies the member who
+// submitted it.
+func (s *adminService) ReviewOrganizationKYC(ctx context.Context, organizationID, reviewerStaffID uint, req dtos.AdminKYCReviewRequest) (*dtos.AdminKYCDetailResponse, error) {
+	kyc, err := s.findOrganizationKYC(ctx, organizationID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrKYCNotFound
-		}
-		return nil, fmt.Errorf("failed to find KYC for review by user ID %d: %w", userID, err)
+		return nil, err
+	}
+	user, err := s.userRepo.FindByID(ctx, kyc.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find submitter of KYC %d: %w", kyc.ID, err)
 	}
 	reviewer, err := s.staffRepo.FindByID(ctx, reviewerStaffID)
 	if err != nil {
@@ -323,8 +419,8 @@ func (s *adminService) ReviewKYC(ctx context.Context, userID, reviewerStaffID ui
 		return nil, fmt.Errorf("failed to update KYC record: %w", err)
 	}
 
-	_ = s.activityLogSvc.LogActivity(ctx, &reviewerStaffID, &userID, "ADMIN_KYC_REVIEWED",
-		map[string]interface{}{"kyc_id": kyc.ID, "old_status": oldStatus, "new_status": kyc.Status, "reason": kyc.RejectionReason}, "")
+	_ = s.activityLogSvc.LogActivity(ctx, &reviewerStaffID, &user.ID, "ADMIN_KYC_REVIEWED",
+		map[string]interface{}{"kyc_id": kyc.ID, "organization_id": organizationID, "old_status": oldStatus, "new_status": kyc.Status, "reason": kyc.RejectionReason}, "")
 
 	go func() {
 		subject := fmt.Sprintf("Your KYC Application Status: %s", kyc.Status)
@@ -340,13 +436,42 @@ func (s *adminService) ReviewKYC(ctx context.Context, userID, reviewerStaffID ui
 
 	if s.notificationSvc != nil && s.cfg != nil {
 		_ = s.notificationSvc.PublishEvent(s.cfg.RabbitMQEventExchangeName, s.cfg.RabbitMQKYCStatusUpdatedRoutingKey,
-			map[string]interface{}{"user_id": userID, "kyc_id": kyc.ID, "status": kyc.Status, "rejection_reason": kyc.RejectionReason})
+			map[string]interface{}{"user_id": user.ID, "organization_id": organizationID, "kyc_id": kyc.ID, "status": kyc.Status, "rejection_reason": kyc.RejectionReason})
 	}
 
 	resp := localMapModelKYCToAdminResponse(updatedKYC, user.Email, &reviewer.Email)
 	return &resp, nil
 }
 
+// organizationIDForUser resolves the organisation whose KYC a customer acts under.
+func (s *adminService) organizationIDForUser(ctx context.Context, userID uint) (uint, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("failed to find user %d: %w", userID, err)
+	}
+	member, err := s.orgRepo.FindMembershipByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrKYCNotFound
+		}
+		return 0, fmt.Errorf("failed to find organization of user %d: %w", userID, err)
+	}
+	return member.OrganizationID, nil
+}
+
+func (s *adminService) findOrganizationKYC(ctx context.Context, organizationID uint) (*models.KYCDetail, error) {
+	kyc, err := s.kycRepo.FindByOrganizationID(ctx, organizationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKYCNotFound
+		}
+		return nil, fmt.Errorf("failed to find KYC of organization %d: %w", organizationID, err)
+	}
+	return kyc, nil
+}
+
 // --- Invoice Management (with DownloadInvoicePDF) ---
 func (s *adminService) GetAllInvoices(ctx context.Context, page, pageSize int, statusFilter string) ([]dtos.InvoiceResponse, int64, error) {
 	filters := make(map[string]string)
@@ -377,6 +502,73 @@ func (s *adminService) GetInvoiceDetail(ctx context.Context, invoiceID uint) (*d
 	return &resp, nil
 }
 
+// GetInvoicePricing previews what the invoice would be disbursed on under the current plans.
+func (s *adminService) GetInvoicePricing(ctx context.Context, invoiceID uint) (*dtos.PricingBreakdownResponse, error) {
+	invoice, err := s.invoiceRepo.FindByID(ctx, invoiceID)
+	if err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	pricing, err := s.pricingSvc.PriceInvoice(ctx, invoice, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mapPricingBreakdown(*pricing, invoice.Currency), nil
+}
+
+// GetOutstandingBalances lists disbursed invoices with what has been repaid on each and what
+// is still owed, soonest due first.
+func (s *adminService) GetOutstandingBalances(ctx context.Context, page, pageSize int) ([]dtos.OutstandingBalanceResponse, int64, error) {
+	invoices, total, err := s.invoiceRepo.FindOutstanding(ctx, page, pageSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get outstanding invoices: %w", err)
+	}
+	invoiceIDs := make([]uint, 0, len(invoices))
+	for _, invoice := range invoices {
+		invoiceIDs = append(invoiceIDs, invoice.ID)
+	}
+	repaid, err := s.transactionRepo.SumRepayments(ctx, invoiceIDs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get repayments: %w", err)
+	}
+
+	now := time.Now()
+	balances := make([]dtos.OutstandingBalanceResponse, 0, len(invoices))
+	for i := range invoices {
+		invoice := &invoices[i]
+		due := invoice.RepaymentDue()
+		balance := dtos.OutstandingBalanceResponse{
+			InvoiceID:         invoice.ID,
+			InvoiceNumber:     invoice.InvoiceNumber,
+			OrganizationID:    invoice.OrganizationID,
+			DebtorName:        invoice.DebtorName,
+			Currency:          invoice.Currency,
+			Status:            invoice.Status,
+			DisbursedAt:       invoice.DisbursedAt,
+			DueDate:           invoice.DueDate,
+			Overdue:           invoice.DueDate != nil && invoice.DueDate.Before(now),
+			FinancedAmount:    due,
+			RepaidAmount:      repaid[invoice.ID].Round(invoice.Currency),
+			OutstandingAmount: due.Sub(repaid[invoice.ID]).Round(invoice.Currency),
+		}
+		if invoice.User.ID != 0 {
+			balance.UserEmail = invoice.User.Email
+		}
+		balances = append(balances, balance)
+	}
+	return balances, total, nil
+}
+
+func (s *adminService) GetInvoiceHistory(ctx context.Context, invoiceID uint) ([]dtos.InvoiceStatusHistoryResponse, error) {
+	if _, err := s.invoiceRepo.FindByID(ctx, invoiceID); err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	history, err := s.invoiceRepo.ListStatusHistory(ctx, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of invoice %d: %w", invoiceID, err)
+	}
+	return mapInvoiceStatusHistory(history), nil
+}
+
 func (s *adminService) UpdateInvoiceStatus(ctx context.Context, invoiceID, adminStaffID uint, req dtos.AdminInvoiceUpdateRequest) (*dtos.InvoiceResponse, error) {
 	invoice, err := s.invoiceRepo.FindByID(ctx, invoiceID)
 	if err != nil {
@@ -391,18 +583,24 @@ func (s *adminService) UpdateInvoiceStatus(ctx context.Context, invoiceID, admin
 	}
 
 	oldStatus := invoice.Status
-	invoice.Status = req.Status
+	if err := models.ValidateInvoiceTransition(oldStatus, req.Status); err != nil {
+		return nil, err
+	}
 	now := time.Now()
+	entry := models.InvoiceStatusHistory{ActorType: models.InvoiceActorStaff, ActorID: &adminStaffID}
 
 	logDetails := map[string]interface{}{
 		"invoice_id":     invoice.ID,
 		"old_status":     oldStatus,
-		"new_status":     invoice.Status,
+		"new_status":     req.Status,
 		"admin_staff_id": adminStaffID,
 	}
 
 	switch req.Status {
 	case models.InvoiceApproved:
+		if err := s.enforceApprovalLimit(ctx, invoice, models.EscalationApprove, invoice.Amount, adminStaffID, nil); err != nil {
+			return nil, err
+		}
 		invoice.ApprovedByID = &adminStaffID
 		invoice.ApprovedAt = &now
 		logDetails["approved_by_staff_id"] = adminStaffID
@@ -413,61 +611,46 @@ func (s *adminService) UpdateInvoiceStatus(ctx context.Context, invoiceID, admin
 			return nil, errors.New("rejection reason is required for rejected invoice")
 		}
 		invoice.ProcessingError = req.RejectionReason
+		entry.Reason = req.RejectionReason
 		logDetails["processing_error"] = *req.RejectionReason
-	case models.InvoiceDisbursed:
-		if oldStatus != models.InvoiceApproved {
-			return nil, ErrInvoiceNotApprovedForDisbursement
-		}
-		if req.FinancedAmount == nil || *req.FinancedAmount <= 0 {
-			return nil, errors.New("valid financed amount is required for disbursement")
-		}
-		if req.FinancingFeePercentage == nil || *req.FinancingFeePercentage < 0 {
-			return nil, errors.New("valid financing fee percentage is required for disbursement")
-		}
-		invoice.DisbursedByID = &adminStaffID
-		invoice.DisbursedAt = &now
-		invoice.FinancingFeePercentage = req.FinancingFeePercentage
-		invoice.FinancedAmount = req.FinancedAmount
-		logDetails["financed_amount"] = *req.FinancedAmount
-		logDetails["financing_fee_percentage"] = *req.FinancingFeePercentage
-
-		disbursementTx := &models.Transaction{
-			InvoiceID:       invoiceID,
-			Type:            models.TransactionDisbursement,
-			Amount:          *req.FinancedAmount,
-			TransactionDate: now,
-		}
-		if err := s.transactionRepo.Create(ctx, disbursementTx); err != nil {
-			log.Printf("Error creating disbursement transaction for invoice %d: %v", invoiceID, err)
-			return nil, fmt.Errorf("failed to record disbursement transaction: %w", err)
-		}
-		logDetails["disbursement_transaction_id"] = disbursementTx.ID
 	case models.InvoiceRepaid:
-		if oldStatus != models.InvoiceDisbursed && oldStatus != models.InvoiceRepaymentPending {
-			return nil, ErrInvalidInvoiceStatusForOperation
-		}
 		logDetails["repayment_confirmed_by_staff_id"] = adminStaffID
 	default:
 		return nil, fmt.Errorf("invalid status '%s' for admin update", req.Status)
 	}
 
-	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
+	if err := s.invoiceRepo.Transition(ctx, invoice, req.Status, entry); err != nil {
+		if errors.Is(err, models.ErrInvalidInvoiceTransition) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to update invoice: %w", err)
 	}
 
 	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, &invoice.UserID, "ADMIN_INVOICE_STATUS_UPDATE", logDetails, "")
+	if invoice.Status == models.InvoiceApproved || invoice.Status == models.InvoiceRejected {
+		_ = s.escalationRepo.ResolveForInvoice(ctx, invoice.ID, models.EscalationApprove, adminStaffID)
+	}
+
+	s.notifyInvoiceStatusChange(invoice, user, req.RejectionReason)
 
+	updatedInvoice, _ := s.invoiceRepo.FindByIDWithRelations(ctx, invoiceID)
+	resp := localMapInvoiceToResponse(updatedInvoice)
+	return &resp, nil
+}
+
+// notifyInvoiceStatusChange emails the invoice owner and publishes the status change event.
+func (s *adminService) notifyInvoiceStatusChange(invoice *models.Invoice, user *models.User, rejectionReason *string) {
 	if user != nil {
 		go func() {
 			subject := fmt.Sprintf("Invoice #%s Status Update: %s", invoice.InvoiceNumber, invoice.Status)
-			body := fmt.Sprintf("Dear %s,\n\nYour invoice #%s (Amount: %.2f %s) has been updated to: %s.",
-				user.FirstName, invoice.InvoiceNumber, invoice.Amount, invoice.Currency, invoice.Status)
+			body := fmt.Sprintf("Dear %s,\n\nYour invoice #%s (Amount: %s %s) has been updated to: %s.",
+				user.FirstName, invoice.InvoiceNumber, invoice.Amount.Format(invoice.Currency), invoice.Currency, invoice.Status)
 
-			if invoice.Status == models.InvoiceRejected && req.RejectionReason != nil {
-				body += fmt.Sprintf("\nReason: %s", *req.RejectionReason)
+			if invoice.Status == models.InvoiceRejected && rejectionReason != nil {
+				body += fmt.Sprintf("\nReason: %s", *rejectionReason)
 			}
 			if invoice.Status == models.InvoiceDisbursed {
-				body += fmt.Sprintf("\nFinanced Amount: %.2f %s.", *invoice.FinancedAmount, invoice.Currency)
+				body += fmt.Sprintf("\nFinanced Amount: %s %s.", invoice.FinancedAmount.Format(invoice.Currency), invoice.Currency)
 				if invoice.DisbursementReceiptPath != nil && *invoice.DisbursementReceiptPath != "" {
 					body += "\nA disbursement receipt is available for viewing/download from your dashboard."
 				}
@@ -485,15 +668,11 @@ func (s *adminService) UpdateInvoiceStatus(ctx context.Context, invoiceID, admin
 			"invoice_id": invoice.ID,
 			"status":     invoice.Status,
 		}
-		if req.Status == models.InvoiceRejected && req.RejectionReason != nil {
-			eventPayload["rejection_reason"] = *req.RejectionReason
+		if invoice.Status == models.InvoiceRejected && rejectionReason != nil {
+			eventPayload["rejection_reason"] = *rejectionReason
 		}
 		_ = s.notificationSvc.PublishEvent(s.cfg.RabbitMQEventExchangeName, s.cfg.RabbitMQInvoiceStatusUpdatedRoutingKey, eventPayload)
 	}
-
-	updatedInvoice, _ := s.invoiceRepo.FindByIDWithRelations(ctx, invoiceID)
-	resp := localMapInvoiceToResponse(updatedInvoice)
-	return &resp, nil
 }
 
 func (s *adminService) UploadDisbursementReceipt(ctx context.Context, invoiceID, adminStaffID uint, req dtos.AdminUploadReceiptRequest) (*dtos.InvoiceResponse, error) {
@@ -527,7 +706,7 @@ func (s *adminService) UploadDisbursementReceipt(ctx context.Context, invoiceID,
 	if userErr == nil && user != nil {
 		go func() {
 			subject := fmt.Sprintf("Disbursement Receipt Uploaded for Invoice #%s", invoice.InvoiceNumber)
-			body := fmt.Sprintf("Dear %s,\n\nA disbursement receipt has been uploaded for your invoice #%s (Amount: %.2f %s). You can view or download it from your dashboard.\n\nRegards,\nThe Admin Team", user.FirstName, invoice.InvoiceNumber, invoice.Amount, invoice.Currency)
+			body := fmt.Sprintf("Dear %s,\n\nA disbursement receipt has been uploaded for your invoice #%s (Amount: %s %s). You can view or download it from your dashboard.\n\nRegards,\nThe Admin Team", user.FirstName, invoice.InvoiceNumber, invoice.Amount.Format(invoice.Currency), invoice.Currency)
 
 			absAttachmentPath, pathErr := s.fileService.GetAbsPath(relativePath)
 			if pathErr != nil {
@@ -572,12 +751,22 @@ func (s *adminService) DownloadInvoicePDF(ctx context.Context, invoiceID, adminS
 		log.Printf("Warning: User %d for invoice %d not found during PDF generation: %v", invoice.UserID, invoiceID, err)
 	}
 
-	kycDetails, err := s.kycRepo.FindByUserID(ctx, invoice.UserID)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		log.Printf("Warning: KYC details for user %d not found during PDF generation for invoice %d: %v", invoice.UserID, invoiceID, err)
+	// The KYC belongs to the invoice's organisation, whichever member uploaded it
+	var kycDetails *models.KYCDetail
+	organizationID := invoice.OrganizationID
+	if organizationID == nil {
+		if member, memberErr := s.orgRepo.FindMembershipByUserID(ctx, invoice.UserID); memberErr == nil {
+			organizationID = &member.OrganizationID
+		}
 	}
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		kycDetails = nil
+	if organizationID != nil {
+		kycDetails, err = s.kycRepo.FindByOrganizationID(ctx, *organizationID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("Warning: KYC details for organization %d not found during PDF generation for invoice %d: %v", *organizationID, invoiceID, err)
+			}
+			kycDetails = nil
+		}
 	}
 
 	companyLogoPath := "" // e.g., s.cfg.CompanyLogoPathForPDF
@@ -602,11 +791,316 @@ func (s *adminService) DownloadInvoicePDF(ctx context.Context, invoiceID, adminS
 	return pdfResponse, nil
 }
 
+// --- Disbursement Approvals ---
+// Disbursements follow a maker-checker workflow: one staff member requests the disbursement
+// and a different one approves it before any money moves.
+
+func localMapDisbursementApprovalToResponse(approval *models.DisbursementApproval) dtos.DisbursementApprovalResponse {
+	return dtos.DisbursementApprovalResponse{
+		ID:                     approval.ID,
+		InvoiceID:              approval.InvoiceID,
+		InvoiceNumber:          approval.Invoice.InvoiceNumber,
+		InvoiceAmount:          approval.Invoice.Amount.Round(approval.Invoice.Currency),
+		Currency:               approval.Invoice.Currency,
+		FinancedAmount:         approval.FinancedAmount.Round(approval.Invoice.Currency),
+		FinancingFeePercentage: approval.FinancingFeePercentage,
+		Pricing:                mapPricingBreakdown(approval.Pricing, approval.Invoice.Currency),
+		Status:                 approval.Status,
+		RequestedByID:          approval.RequestedByID,
+		RequestedByEmail:       approval.RequestedBy.Email,
+		ReviewedByID:           approval.ReviewedByID,
+		ReviewedAt:             approval.ReviewedAt,
+		RejectionReason:        approval.RejectionReason,
+		TransactionID:          approval.TransactionID,
+		CreatedAt:              approval.CreatedAt,
+	}
+}
+
+func (s *adminService) RequestDisbursement(ctx context.Context, invoiceID, makerStaffID uint, req dtos.RequestDisbursementRequest) (*dtos.DisbursementApprovalResponse, error) {
+	invoice, err := s.invoiceRepo.FindByID(ctx, invoiceID)
+	if err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	if invoice.Status != models.InvoiceApproved {
+		return nil, ErrInvoiceNotApprovedForDisbursement
+	}
+	pricing, err := s.pricingSvc.PriceInvoice(ctx, invoice, req.Override)
+	if err != nil {
+		return nil, err
+	}
+	if pricing.AdvanceAmount.GreaterThan(invoice.Amount) {
+		return nil, ErrFinancedAmountExceedsInvoice
+	}
+	if _, err := s.approvalRepo.FindPendingByInvoiceID(ctx, invoiceID); err == nil {
+		return nil, ErrDisbursementAlreadyPending
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to check pending disbursements: %w", err)
+	}
+
+	approval := &models.DisbursementApproval{
+		InvoiceID:              invoiceID,
+		FinancedAmount:         pricing.AdvanceAmount,
+		FinancingFeePercentage: effectiveFeePercentage(pricing),
+		Pricing:                *pricing,
+		Status:                 models.DisbursementPending,
+		RequestedByID:          makerStaffID,
+	}
+	if err := s.approvalRepo.Create(ctx, approval); err != nil {
+		// The partial unique index catches a concurrent request for the same invoice
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, ErrDisbursementAlreadyPending
+		}
+		return nil, fmt.Errorf("failed to create disbursement request: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &makerStaffID, &invoice.UserID, "ADMIN_DISBURSEMENT_REQUESTED", map[string]interface{}{
+		"invoice_id":               invoiceID,
+		"disbursement_request_id":  approval.ID,
+		"financed_amount":          approval.FinancedAmount,
+		"financing_fee_percentage": approval.FinancingFeePercentage,
+		"net_disbursement":         pricing.NetDisbursement,
+		"pricing_overridden":       pricing.Overridden,
+	}, "")
+
+	created, err := s.approvalRepo.FindPendingByID(ctx, approval.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load disbursement request: %w", err)
+	}
+	resp := localMapDisbursementApprovalToResponse(created)
+	return &resp, nil
+}
+
+func (s *adminService) GetPendingDisbursements(ctx context.Context) ([]dtos.DisbursementApprovalResponse, error) {
+	approvals, err := s.approvalRepo.ListPending(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending disbursements: %w", err)
+	}
+	responses := make([]dtos.DisbursementApprovalResponse, 0, len(approvals))
+	for i := range approvals {
+		responses = append(responses, localMapDisbursementApprovalToResponse(&approvals[i]))
+	}
+	return responses, nil
+}
+
+// ApproveDisbursement is the checker step: it disburses the invoice on the terms priced in the
+// request, stores that pricing breakdown on the invoice and records the disbursement
+// transaction for the net amount paid out.
+func (s *adminService) ApproveDisbursement(ctx context.Context, approvalID, checkerStaffID uint) (*dtos.DisbursementApprovalResponse, error) {
+	approval, err := s.approvalRepo.FindPendingByID(ctx, approvalID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDisbursementNotFound
+		}
+		return nil, fmt.Errorf("failed to find disbursement request: %w", err)
+	}
+	if approval.RequestedByID == checkerStaffID {
+		return nil, ErrDisbursementSelfApproval
+	}
+	if approval.Invoice.Status != models.InvoiceApproved {
+		return nil, ErrInvoiceNotApprovedForDisbursement
+	}
+	if err := s.enforceApprovalLimit(ctx, &approval.Invoice, models.EscalationDisburse, approval.FinancedAmount, checkerStaffID, &approval.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.approvalRepo.Approve(ctx, approval, checkerStaffID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Another checker acted first or the invoice changed status in the meantime
+			return nil, ErrDisbursementNotFound
+		}
+		return nil, fmt.Errorf("failed to approve disbursement: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &checkerStaffID, &approval.Invoice.UserID, "ADMIN_DISBURSEMENT_APPROVED", map[string]interface{}{
+		"invoice_id":                  approval.InvoiceID,
+		"disbursement_request_id":     approval.ID,
+		"requested_by_staff_id":       approval.RequestedByID,
+		"financed_amount":             approval.FinancedAmount,
+		"financing_fee_percentage":    approval.FinancingFeePercentage,
+		"disbursement_transaction_id": *approval.TransactionID,
+	}, "")
+	_ = s.escalationRepo.ResolveForInvoice(ctx, approval.InvoiceID, models.EscalationDisburse, checkerStaffID)
+
+	if invoice, err := s.invoiceRepo.FindByID(ctx, approval.InvoiceID); err == nil {
+		user, userErr := s.userRepo.FindByID(ctx, invoice.UserID)
+		if userErr != nil {
+			log.Printf("Warning: User for invoice %d not found after disbursement: %v", invoice.ID, userErr)
+		}
+		s.notifyInvoiceStatusChange(invoice, user, nil)
+		approval.Invoice = *invoice
+	}
+
+	resp := localMapDisbursementApprovalToResponse(approval)
+	return &resp, nil
+}
+
+// RejectDisbursement closes a pending request without paying out. The requester may use it to
+// withdraw their own request.
+func (s *adminService) RejectDisbursement(ctx context.Context, approvalID, checkerStaffID uint, req dtos.RejectDisbursementRequest) (*dtos.DisbursementApprovalResponse, error) {
+	approval, err := s.approvalRepo.FindPendingByID(ctx, approvalID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDisbursementNotFound
+		}
+		return nil, fmt.Errorf("failed to find disbursement request: %w", err)
+	}
+	if err := s.approvalRepo.Reject(ctx, approval, checkerStaffID, req.Reason); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDisbursementNotFound
+		}
+		return nil, fmt.Errorf("failed to reject disbursement: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &checkerStaffID, &approval.Invoice.UserID, "ADMIN_DISBURSEMENT_REJECTED", map[string]interface{}{
+		"invoice_id":              approval.InvoiceID,
+		"disbursement_request_id": approval.ID,
+		"requested_by_staff_id":   approval.RequestedByID,
+		"reason":                  req.Reason,
+	}, "")
+	_ = s.escalationRepo.ResolveForInvoice(ctx, approval.InvoiceID, models.EscalationDisburse, checkerStaffID)
+
+	resp := localMapDisbursementApprovalToResponse(approval)
+	return &resp, nil
+}
+
+// --- Approval Limits & Escalations ---
+
+// enforceApprovalLimit checks amount against the approval limit of the staff member's role
+// for the invoice's currency. When the limit is exceeded the invoice is queued for escalation
+// and an *ApprovalLimitExceededError is returned. A role without any limits is unlimited, but
+// a role with limits in other currencies may not approve anything in a currency it has no
+// limit for; such invoices are escalated as well.
+func (s *adminService) enforceApprovalLimit(ctx context.Context, invoice *models.Invoice, action models.EscalationAction, amount money.Amount, staffID uint, approvalID *uint) error {
+	staff, err := s.staffRepo.FindByID(ctx, staffID)
+	if err != nil {
+		return fmt.Errorf("failed to load staff member %d for approval limit check: %w", staffID, err)
+	}
+	currency := strings.ToUpper(invoice.Currency)
+	limit, err := s.limitRepo.FindByRoleAndCurrency(ctx, staff.Role, currency)
+	noLimit := false
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load approval limit: %w", err)
+		}
+		hasLimits, err := s.limitRepo.RoleHasLimits(ctx, staff.Role)
+		if err != nil {
+			return fmt.Errorf("failed to load approval limits: %w", err)
+		}
+		if !hasLimits {
+			return nil
+		}
+		limit = &models.ApprovalLimit{Role: staff.Role, Currency: currency, MaxAmount: money.Zero}
+		noLimit = true
+	} else if !amount.GreaterThan(limit.MaxAmount) {
+		return nil
+	}
+
+	escalation := &models.InvoiceEscalation{
+		InvoiceID:              invoice.ID,
+		Action:                 action,
+		Amount:                 amount,
+		Currency:               currency,
+		Role:                   staff.Role,
+		LimitAmount:            limit.MaxAmount,
+		EscalatedByID:          staffID,
+		DisbursementApprovalID: approvalID,
+	}
+	if err := s.escalationRepo.CreateIfNotOpen(ctx, escalation); err != nil {
+		return fmt.Errorf("failed to escalate invoice: %w", err)
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &staffID, &invoice.UserID, "ADMIN_INVOICE_ESCALATED", map[string]interface{}{
+		"invoice_id":    invoice.ID,
+		"escalation_id": escalation.ID,
+		"action":        action,
+		"amount":        amount,
+		"currency":      currency,
+		"role":          staff.Role,
+		"limit":         limit.MaxAmount,
+		"no_limit":      noLimit,
+	}, "")
+
+	return &ApprovalLimitExceededError{
+		Role:         staff.Role,
+		Currency:     currency,
+		Limit:        limit.MaxAmount,
+		Amount:       amount,
+		EscalationID: escalation.ID,
+		NoLimit:      noLimit,
+	}
+}
+
+func (s *adminService) GetApprovalLimits(ctx context.Context) ([]dtos.ApprovalLimitResponse, error) {
+	limits, err := s.limitRepo.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approval limits: %w", err)
+	}
+	responses := make([]dtos.ApprovalLimitResponse, 0, len(limits))
+	for _, limit := range limits {
+		responses = append(responses, dtos.ApprovalLimitResponse{ID: limit.ID, Role: limit.Role, Currency: limit.Currency, MaxAmount: limit.MaxAmount.Round(limit.Currency)})
+	}
+	return responses, nil
+}
+
+// SetApprovalLimit creates or replaces the limit of a role for a currency. Once a role has a
+// limit in any currency, it can no longer approve invoices in currencies without one; those
+// are escalated.
+func (s *adminService) SetApprovalLimit(ctx context.Context, adminStaffID uint, req dtos.SetApprovalLimitRequest) (*dtos.ApprovalLimitResponse, error) {
+	currency := strings.ToUpper(req.Currency)
+	limit := &models.ApprovalLimit{Role: req.Role, Currency: currency, MaxAmount: req.MaxAmount.Round(currency)}
+	if err := s.limitRepo.Upsert(ctx, limit); err != nil {
+		return nil, fmt.Errorf("failed to save approval limit: %w", err)
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_APPROVAL_LIMIT_SET", map[string]interface{}{
+		"role": limit.Role, "currency": limit.Currency, "max_amount": limit.MaxAmount,
+	}, "")
+	return &dtos.ApprovalLimitResponse{ID: limit.ID, Role: limit.Role, Currency: limit.Currency, MaxAmount: limit.MaxAmount}, nil
+}
+
+// DeleteApprovalLimit removes a limit. While the role keeps limits in other currencies, its
+// approvals in this currency are escalated; deleting its last limit makes it unlimited.
+func (s *adminService) DeleteApprovalLimit(ctx context.Context, adminStaffID, limitID uint) error {
+	deleted, err := s.limitRepo.Delete(ctx, limitID)
+	if err != nil {
+		return fmt.Errorf("failed to delete approval limit: %w", err)
+	}
+	if !deleted {
+		return ErrApprovalLimitNotFound
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_APPROVAL_LIMIT_DELETED", map[string]interface{}{"approval_limit_id": limitID}, "")
+	return nil
+}
+
+func (s *adminService) GetOpenEscalations(ctx context.Context) ([]dtos.InvoiceEscalationResponse, error) {
+	escalations, err := s.escalationRepo.ListOpen(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get escalated invoices: %w", err)
+	}
+	responses := make([]dtos.InvoiceEscalationResponse, 0, len(escalations))
+	for _, escalation := range escalations {
+		responses = append(responses, dtos.InvoiceEscalationResponse{
+			ID:                     escalation.ID,
+			InvoiceID:              escalation.InvoiceID,
+			InvoiceNumber:          escalation.Invoice.InvoiceNumber,
+			InvoiceStatus:          string(escalation.Invoice.Status),
+			Action:                 string(escalation.Action),
+			Amount:                 escalation.Amount.Round(escalation.Currency),
+			Currency:               escalation.Currency,
+			Role:                   escalation.Role,
+			LimitAmount:            escalation.LimitAmount.Round(escalation.Currency),
+			EscalatedByID:          escalation.EscalatedByID,
+			EscalatedByEmail:       escalation.EscalatedBy.Email,
+			DisbursementApprovalID: escalation.DisbursementApprovalID,
+			CreatedAt:              escalation.CreatedAt,
+		})
+	}
+	return responses, nil
+}
+
 // --- Staff Management ---
 func (s *adminService) CreateStaff(ctx context.Context, req dtos.CreateStaffRequest) (*dtos.StaffResponse, error) {
 	existing, _ := s.staffRepo.FindByEmail(ctx, req.Email)
 	if existing != nil {
-		return nil, errors.New("staff with this email already exists")
+		return nil, ErrStaffExists
 	}
 	staff := &models.Staff{
 		Email:        req.Email,
@@ -694,6 +1188,260 @@ func (s *adminService) DeleteStaff(ctx context.Context, staffID uint) error {
 	return nil
 }
 
+// --- Staff Invitations ---
+
+// InviteStaff emails a single-use link with which the invitee sets up their own staff account,
+// so no administrator ever chooses or sees another staff member's password.
+func (s *adminService) InviteStaff(ctx context.Context, adminStaffID uint, req dtos.InviteStaffRequest) (*dtos.StaffInvitationResponse, error) {
+	if !models.IsStaffRole(req.Role) {
+		return nil, fmt.Errorf("invalid staff role %q", req.Role)
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if existing, _ := s.staffRepo.FindByEmail(ctx, email); existing != nil {
+		return nil, ErrStaffExists
+	}
+	if pending, _ := s.staffInviteRepo.FindPendingByEmail(ctx, email); pending != nil {
+		return nil, ErrInvitationAlreadyPending
+	}
+
+	token, tokenHash, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation := &models.StaffInvitation{
+		Email:       email,
+		Role:        req.Role,
+		TokenHash:   tokenHash,
+		InvitedByID: adminStaffID,
+		ExpiresAt:   time.Now().Add(s.cfg.StaffInvitationExpiry),
+	}
+	if err := s.staffInviteRepo.Create(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to create staff invitation: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_STAFF_INVITED", map[string]interface{}{"email": email, "role": req.Role}, "")
+	s.sendStaffInvitationEmail(invitation, token)
+
+	resp := localMapStaffInvitationToResponse(invitation)
+	return &resp, nil
+}
+
+// GetPendingStaffInvitations lists invitations that have not been accepted or revoked,
+// including expired ones so they can be resent.
+func (s *adminService) GetPendingStaffInvitations(ctx context.Context) ([]dtos.StaffInvitationResponse, error) {
+	invitations, err := s.staffInviteRepo.ListPending(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staff invitations: %w", err)
+	}
+	responses := make([]dtos.StaffInvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		responses = append(responses, localMapStaffInvitationToResponse(&invitations[i]))
+	}
+	return responses, nil
+}
+
+// ResendStaffInvitation issues a fresh token and expiry and emails it again. The link sent
+// previously stops working.
+func (s *adminService) ResendStaffInvitation(ctx context.Context, adminStaffID, invitationID uint) (*dtos.StaffInvitationResponse, error) {
+	invitation, err := s.staffInviteRepo.FindPendingByID(ctx, invitationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to find staff invitation: %w", err)
+	}
+
+	token, tokenHash, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation.TokenHash = tokenHash
+	invitation.ExpiresAt = time.Now().Add(s.cfg.StaffInvitationExpiry)
+	if err := s.staffInviteRepo.UpdateToken(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to reissue staff invitation: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_STAFF_INVITATION_RESENT", map[string]interface{}{"invitation_id": invitation.ID, "email": invitation.Email}, "")
+	s.sendStaffInvitationEmail(invitation, token)
+
+	resp := localMapStaffInvitationToResponse(invitation)
+	return &resp, nil
+}
+
+func (s *adminService) RevokeStaffInvitation(ctx context.Context, adminStaffID, invitationID uint) error {
+	deleted, err := s.staffInviteRepo.Delete(ctx, invitationID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke staff invitation: %w", err)
+	}
+	if !deleted {
+		return ErrInvitationNotFound
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_STAFF_INVITATION_REVOKED", map[string]interface{}{"invitation_id": invitationID}, "")
+	return nil
+}
+
+// BeginStaffInvitation2FA generates the authenticator secret an invitee scans before accepting
+// their invitation. The secret is held against the invitation until the acceptance confirms it.
+func (s *adminService) BeginStaffInvitation2FA(ctx context.Context, token string) (*dtos.StaffTwoFAEnrollmentResponse, error) {
+	invitation, err := s.findPendingStaffInvitation(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.totpService.GenerateEnrollment(invitation.Email)
+	if err != nil {
+		log.Printf("Failed to generate TOTP enrollment for staff invitation %d: %v", invitation.ID, err)
+		return nil, ErrFailedToInitiate2FA
+	}
+	if err := s.otpService.StorePendingTOTPSecret(ctx, staffInvitationSubject(invitation.ID), enrollment.Secret, s.cfg.TOTPEnrollmentExpiry); err != nil {
+		return nil, ErrFailedToInitiate2FA
+	}
+
+	return &dtos.StaffTwoFAEnrollmentResponse{
+		Method:     models.TwoFAMethodTOTP,
+		Message:    "Scan the QR code with your authenticator app and enter the first code it shows when accepting the invitation.",
+		Secret:     enrollment.Secret,
+		OTPAuthURL: enrollment.OTPAuthURL,
+		QRCodePNG:  enrollment.QRCodePNG,
+		ExpiresIn:  int(s.cfg.TOTPEnrollmentExpiry.Seconds()),
+	}, nil
+}
+
+// AcceptStaffInvitation creates the invitee's staff account with the password they chose and
+// the authenticator app set up through BeginStaffInvitation2FA. The account is only created
+// once the first authenticator code checks out.
+func (s *adminService) AcceptStaffInvitation(ctx context.Context, req dtos.AcceptStaffInvitationRequest) (*dtos.StaffResponse, error) {
+	if err := ValidatePasswordPolicy(req.Password); err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.findPendingStaffInvitation(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if existing, _ := s.staffRepo.FindByEmail(ctx, invitation.Email); existing != nil {
+		return nil, ErrStaffExists
+	}
+
+	enrollmentSubject := staffInvitationSubject(invitation.ID)
+	secret, err := s.otpService.GetPendingTOTPSecret(ctx, enrollmentSubject)
+	if err != nil {
+		return nil, fmt.Errorf("could not load pending TOTP enrollment: %w", err)
+	}
+	if secret == "" {
+		return nil, ErrTOTPEnrollmentExpired
+	}
+	step, valid := s.totpService.ValidateCode(req.TOTPCode, secret)
+	if !valid {
+		return nil, ErrOTPInvalidOrExpired
+	}
+
+	staff := &models.Staff{
+		Email:        invitation.Email,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		PasswordHash: req.Password, // Hashed by the BeforeCreate hook
+		Role:         invitation.Role,
+		IsActive:     true,
+		TwoFAEnabled: true,
+		TwoFAMethod:  models.TwoFAMethodTOTP,
+		TwoFASecret:  &secret,
+	}
+	if err := s.staffInviteRepo.Accept(ctx, invitation, staff); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid // Accepted concurrently
+		}
+		return nil, fmt.Errorf("failed to accept staff invitation: %w", err)
+	}
+
+	// The confirmation code must not also work for the first login
+	if _, err := s.otpService.ClaimTOTPStep(ctx, principalSubject(PrincipalTypeStaff, staff.ID), step, totpReplayWindow); err != nil {
+		log.Printf("Warning: Failed to record TOTP step for new staff %d: %v", staff.ID, err)
+	}
+	if err := s.otpService.DeletePendingTOTPSecret(ctx, enrollmentSubject); err != nil {
+		log.Printf("Warning: Failed to delete pending TOTP secret for staff invitation %d: %v", invitation.ID, err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &staff.ID, nil, "STAFF_INVITATION_ACCEPTED", map[string]interface{}{
+		"invitation_id": invitation.ID, "role": staff.Role, "invited_by": invitation.InvitedByID, "two_fa_method": staff.TwoFAMethod,
+	}, "")
+	resp := localMapModelStaffToStaffResponse(staff)
+	return &resp, nil
+}
+
+func (s *adminService) findPendingStaffInvitation(ctx context.Context, token string) (*models.StaffInvitation, error) {
+	sum := sha256.Sum256([]byte(token))
+	invitation, err := s.staffInviteRepo.FindPendingByTokenHash(ctx, hex.EncodeToString(sum[:]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, fmt.Errorf("failed to look up staff invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+// staffInvitationSubject scopes an invitee's pending authenticator secret to their invitation,
+// as there is no staff account to key it by yet.
+func staffInvitationSubject(invitationID uint) string {
+	return fmt.Sprintf("staff_invitation:%d", invitationID)
+}
+
+func (s *adminService) sendStaffInvitationEmail(invitation *models.StaffInvitation, token string) {
+	email, role := invitation.Email, invitation.Role
+	go func() {
+		link := fmt.Sprintf("%s/admin/accept-invitation?token=%s", s.cfg.FrontendBaseURL, token)
+		subject := "You've been invited to the admin console"
+		body := fmt.Sprintf("Hi,\n\nYou've been invited to join the invoice financing admin console as %s.\nSet up your account using the link below (valid for %d hours):\n%s\n\nIf you weren't expecting this invitation, you can ignore this email.\n\nThanks,\nThe Team",
+			role, int(s.cfg.StaffInvitationExpiry.Hours()), link)
+		if emailErr := s.emailService.SendEmail(email, subject, body); emailErr != nil {
+			log.Printf("Failed to send staff invitation email to %s: %v", email, emailErr)
+		}
+	}()
+}
+
+func localMapStaffInvitationToResponse(invitation *models.StaffInvitation) dtos.StaffInvitationResponse {
+	return dtos.StaffInvitationResponse{
+		ID:             invitation.ID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		InvitedByEmail: invitation.InvitedBy.Email,
+		ExpiresAt:      invitation.ExpiresAt,
+		Expired:        time.Now().After(invitation.ExpiresAt),
+		CreatedAt:      invitation.CreatedAt,
+	}
+}
+
+// --- Login Lockouts ---
+
+func (s *adminService) GetLockedAccounts(ctx context.Context) ([]dtos.LockedAccountResponse, error) {
+	locked, err := s.loginGuard.ListLocked(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locked accounts: %w", err)
+	}
+
+	responses := make([]dtos.LockedAccountResponse, 0, len(locked))
+	for _, entry := range locked {
+		responses = append(responses, dtos.LockedAccountResponse{Email: entry.Account, LockedUntil: entry.LockedUntil})
+	}
+	return responses, nil
+}
+
+// UnlockAccount lifts a login lockout early and clears the account's failure count.
+func (s *adminService) UnlockAccount(ctx context.Context, adminStaffID uint, email string) error {
+	account := NormalizeLoginAccount(email)
+	if err := s.loginGuard.Unlock(ctx, account); err != nil {
+		return fmt.Errorf("failed to unlock account %s: %w", account, err)
+	}
+
+	var userID *uint
+	if user, err := s.userRepo.FindByEmail(ctx, account); err == nil && user != nil {
+		userID = &user.ID
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, userID, "ADMIN_ACCOUNT_UNLOCKED", map[string]interface{}{"email": account}, "")
+	return nil
+}
+
 // --- Activity Logs & Analytics ---
 
 func (s *adminService) GetActivityLogs(ctx context.Context, page, pageSize int, filters map[string]string) ([]dtos.ActivityLogResponse, int64, error) {
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/repositories"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyInvalid      = errors.New("api key is invalid, expired or revoked")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrAPIKeyLimitReached = errors.New("maximum number of api keys reached")
+)
+
+const (
+	// APIKeyPrefix starts every key so that leaked keys are easy to recognise and scan for.
+	APIKeyPrefix = "ib2b_"
+
+	apiKeyPrefixBytes = 4
+	apiKeySecretBytes = 24
+	maxAPIKeysPerUser = 10
+	// Last-use tracking is written at most this often per key to keep busy integrations cheap.
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService manages users' personal API keys and authenticates requests made with them.
+type APIKeyService interface {
+	CreateKey(ctx context.Context, userID uint, req dtos.CreateAPIKeyRequest) (*dtos.CreateAPIKeyResponse, error)
+	ListKeys(ctx context.Context, userID uint) ([]dtos.APIKeyResponse, error)
+	RevokeKey(ctx context.Context, userID, keyID uint) error
+	Authenticate(ctx context.Context, rawKey, ipAddress string) (*models.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo     repositories.APIKeyRepository
+	activityLogSvc ActivityLogService
+}
+
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepository, activityLogSvc ActivityLogService) APIKeyService {
+	return &apiKeyService{apiKeyRepo: apiKeyRepo, activityLogSvc: activityLogSvc}
+}
+
+// CreateKey issues a new key. The full key is only ever returned here.
+func (s *apiKeyService) CreateKey(ctx context.Context, userID uint, req dtos.CreateAPIKeyRequest) (*dtos.CreateAPIKeyResponse, error) {
+	count, err := s.apiKeyRepo.CountByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count api keys: %w", err)
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, ErrAPIKeyLimitReached
+	}
+
+	rawKey, prefix, keyHash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{
+		UserID:  userID,
+		Name:    strings.TrimSpace(req.Name),
+		Prefix:  prefix,
+		KeyHash: keyHash,
+		Scopes:  strings.Join(normaliseScopes(req.Scopes), ","),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().Add(time.Duration(*req.ExpiresInDays) * 24 * time.Hour)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "USER_API_KEY_CREATED", map[string]interface{}{
+		"api_key_id": key.ID, "prefix": key.Prefix, "scopes": key.ScopeList(), "expires_at": key.ExpiresAt,
+	}, "")
+
+	return &dtos.CreateAPIKeyResponse{APIKeyResponse: mapAPIKeyToResponse(key), Key: rawKey}, nil
+}
+
+func (s *apiKeyService) ListKeys(ctx context.Context, userID uint) ([]dtos.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	responses := make([]dtos.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		responses = append(responses, mapAPIKeyToResponse(&keys[i]))
+	}
+	return responses, nil
+}
+
+func (s *apiKeyService) RevokeKey(ctx context.Context, userID, keyID uint) error {
+	revoked, err := s.apiKeyRepo.Delete(ctx, userID, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "USER_API_KEY_REVOKED", map[string]interface{}{"api_key_id": keyID}, "")
+	return nil
+}
+
+// Authenticate resolves a raw key to its unexpired, unrevoked record with the owner preloaded.
+// Keys of deactivated users are refused.
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey, ipAddress string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+	key, err := s.apiKeyRepo.FindByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+	if key.IsExpired() {
+		return nil, ErrAPIKeyInvalid
+	}
+	if !key.User.IsActive {
+		return nil, ErrAccountNotActive
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != ipAddress {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, key.ID, now, ipAddress); err != nil {
+			log.Printf("Warning: could not record use of API key %d: %v", key.ID, err)
+		}
+	}
+	return key, nil
+}
+
+// generateAPIKey returns a key of the form ib2b_<prefix>_<secret>, its displayable prefix and
+// the hash to store.
+func generateAPIKey() (string, string, string, error) {
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	secretBytes := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	prefix := APIKeyPrefix + hex.EncodeToString(prefixBytes)
+	rawKey := prefix + "_" + hex.EncodeToString(secretBytes)
+	return rawKey, prefix, hashAPIKey(rawKey), nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func normaliseScopes(scopes []string) []string {
+	unique := make(map[string]bool, len(scopes))
+	normalised := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !unique[scope] {
+			unique[scope] = true
+			normalised = append(normalised, scope)
+		}
+	}
+	sort.Strings(normalised)
+	return normalised
+}
+
+func mapAPIKeyToResponse(key *models.APIKey) dtos.APIKeyResponse {
+	return dtos.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		Expired:    key.IsExpired(),
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
@@ -11,30 +14,72 @@ import (
 	"invoiceB2B/internal/repositories"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeHalfLen  = 5
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789" // No 0/o, 1/l/i to avoid transcription mistakes
+)
+
 var (
-	ErrUserNotFound          = errors.New("user not found")
-	ErrInvalidCredentials    = errors.New("invalid email or password")
-	ErrEmailExists           = errors.New("user with this email already exists")
-	ErrOTPInvalidOrExpired   = errors.New("otp is invalid or has expired")
-	Err2FANotEnabled         = errors.New("2fa is not enabled for this user")
-	ErrAccountNotActive      = errors.New("user account is not active")
-	ErrKYCNotApproved        = errors.New("user kyc not approved")
-	ErrRefreshTokenInvalid   = errors.New("refresh token is invalid or expired")
-	ErrTokenBlacklisted      = errors.New("token has been blacklisted")
-	ErrFailedToGenerateToken = errors.New("failed to generate token")
-	ErrFailedToInitiate2FA   = errors.New("failed to initiate 2FA")
+	ErrUserNotFound           = errors.New("user not found")
+	ErrInvalidCredentials     = errors.New("invalid email or password")
+	ErrEmailExists            = errors.New("user with this email already exists")
+	ErrOTPInvalidOrExpired    = errors.New("otp is invalid or has expired")
+	Err2FANotEnabled          = errors.New("2fa is not enabled for this user")
+	ErrAccountNotActive       = errors.New("user account is not active")
+	ErrKYCNotApproved         = errors.New("user kyc not approved")
+	ErrRefreshTokenInvalid    = errors.New("refresh token is invalid or expired")
+	ErrTokenBlacklisted       = errors.New("token has been blacklisted")
+	ErrFailedToGenerateToken  = errors.New("failed to generate token")
+	ErrFailedToInitiate2FA    = errors.New("failed to initiate 2FA")
+	ErrTOTPNotEnrolled        = errors.New("authenticator app is not enrolled for this user")
+	ErrTOTPEnrollmentExpired  = errors.New("no authenticator enrollment in progress or it has expired")
+	ErrRecoveryCodeInvalid    = errors.New("recovery code is invalid or has already been used")
+	ErrResetTokenInvalid      = errors.New("password reset token is invalid or has expired")
+	ErrTokenRevoked           = errors.New("token has been revoked")
+	ErrCurrentPasswordWrong   = errors.New("current password is incorrect")
+	ErrPasswordUnchanged      = errors.New("new password must be different from the current password")
+	ErrWeakPassword           = errors.New("password does not meet the password policy")
+	ErrEmailNotVerified       = errors.New("email address has not been verified")
+	ErrEmailAlreadyVerified   = errors.New("email address is already verified")
+	ErrVerificationInvalid    = errors.New("email verification link is invalid or has expired")
+	ErrSessionNotFound        = errors.New("session not found")
+	ErrVerificationThrottled  = errors.New("verification email was sent recently, please wait before requesting another")
+	ErrStaffEnrollmentInvalid = errors.New("2fa enrollment session is invalid or has expired, please log in again")
+	Err2FALoginInvalid        = errors.New("2fa login session is invalid or has expired, please log in again")
 )
 
 type AuthService interface {
 	RegisterUser(ctx context.Context, user *models.User) (*models.User, error)
-	LoginUser(ctx context.Context, email, password string) (*dtos.LoginUserResponse, error)
-	VerifyOTP(ctx context.Context, email, otp string) (*dtos.LoginUserResponse, error)
-	RefreshToken(ctx context.Context, tokenStr string) (*dtos.RefreshTokenResponse, error)
+	LoginUser(ctx context.Context, email, password string, client ClientInfo) (*dtos.LoginUserResponse, error)
+	VerifyOTP(ctx context.Context, email, twoFAToken, otp string, client ClientInfo) (*dtos.LoginUserResponse, error)
+	VerifyRecoveryCode(ctx context.Context, email, twoFAToken, code string, client ClientInfo) (*dtos.LoginUserResponse, error)
+	RefreshToken(ctx context.Context, tokenStr string, client ClientInfo) (*dtos.RefreshTokenResponse, error)
 	LogoutUser(ctx context.Context, tokenStr string) error
-	Toggle2FA(ctx context.Context, userIDStr string, enable bool) error
+	Toggle2FA(ctx context.Context, userIDStr string, enable bool) ([]string, error)
+	BeginTOTPEnrollment(ctx context.Context, userIDStr string) (*dtos.TOTPSetupResponse, error)
+	ConfirmTOTPEnrollment(ctx context.Context, userIDStr, code string) ([]string, error)
+	Set2FAMethod(ctx context.Context, userIDStr, method string) error
+	RegenerateRecoveryCodes(ctx context.Context, userIDStr string) ([]string, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, userIDStr string) error
+	ChangeUserPassword(ctx context.Context, userIDStr, currentPassword, newPassword string, client ClientInfo) (*dtos.ChangePasswordResponse, error)
+	ChangeStaffPassword(ctx context.Context, staffID uint, currentPassword, newPassword string, client ClientInfo) (*dtos.ChangePasswordResponse, error)
+	ListSessions(ctx context.Context, principalType string, id uint, currentSessionID string) ([]dtos.SessionResponse, error)
+	RevokeSession(ctx context.Context, principalType string, id uint, sessionID string) error
+	RevokeAllUserSessions(ctx context.Context, staffID, userID uint) error
+	StartImpersonation(ctx context.Context, staffID, userID uint, client ClientInfo) (*dtos.ImpersonationResponse, error)
+	StopImpersonation(ctx context.Context, staffID, userID uint, sessionID string) error
+	UnlockAccount(ctx context.Context, token string) error
+	BeginStaff2FAEnrollment(ctx context.Context, enrollmentToken, method string) (*dtos.StaffTwoFAEnrollmentResponse, error)
+	ConfirmStaff2FAEnrollment(ctx context.Context, enrollmentToken, method, code string, client ClientInfo) (*dtos.LoginUserResponse, error)
+	ResetStaff2FA(ctx context.Context, adminStaffID, staffID uint) error
 	GetConfig() *config.Config
 }
 
@@ -42,9 +87,14 @@ type authService struct {
 	userRepo            repositories.UserRepository
 	staffRepo           repositories.StaffRepository
 	kycRepo             repositories.KYCRepository
+	organizationRepo    repositories.OrganizationRepository
+	recoveryCodeRepo    repositories.RecoveryCodeRepository
 	jwtService          JWTService
 	emailService        EmailService
 	otpService          OTPService
+	totpService         TOTPService
+	sessionService      SessionService
+	loginGuard          LoginGuardService
 	notificationService NotificationService
 	activityLogService  ActivityLogService
 	cfg                 *config.Config
@@ -54,9 +104,14 @@ func NewAuthService(
 	userRepo repositories.UserRepository,
 	staffRepo repositories.StaffRepository,
 	kycRepo repositories.KYCRepository,
+	organizationRepo repositories.OrganizationRepository,
+	recoveryCodeRepo repositories.RecoveryCodeRepository,
 	jwtService JWTService,
 	emailService EmailService,
 	otpService OTPService,
+	totpService TOTPService,
+	sessionService SessionService,
+	loginGuard LoginGuardService,
 	notificationService NotificationService,
 	activityLogService ActivityLogService,
 	cfg *config.Config,
@@ -65,9 +120,14 @@ func NewAuthService(
 		userRepo:            userRepo,
 		staffRepo:           staffRepo,
 		kycRepo:             kycRepo,
+		organizationRepo:    organizationRepo,
+		recoveryCodeRepo:    recoveryCodeRepo,
 		jwtService:          jwtService,
 		emailService:        emailService,
 		otpService:          otpService,
+		totpService:         totpService,
+		sessionService:      sessionService,
+		loginGuard:          loginGuard,
 		notificationService: notificationService,
 		activityLogService:  activityLogService,
 		cfg:                 cfg,
@@ -84,29 +144,25 @@ func (s *authService) RegisterUser(ctx context.Context, user *models.User) (*mod
 		return nil, ErrEmailExists
 	}
 
-	createdUser, err := s.userRepo.Create(ctx, user)
-	if err != nil {
-		log.Printf("Error creating user in DB: %v", err)
+	// Self-registration creates the company the user will act for, with the user as its owner
+	organization := &models.Organization{Name: user.CompanyName}
+	if err := s.organizationRepo.CreateWithOwner(ctx, organization, user); err != nil {
+		log.Printf("Error registering user %s: %v", user.Email, err)
 		return nil, fmt.Errorf("could not create user: %w", err)
 	}
+	createdUser := user
 
-	kycDetail := &models.KYCDetail{
-		UserID: createdUser.ID,
-		Status: models.KYCPending,
-	}
-	_, err = s.kycRepo.CreateOrUpdate(ctx, kycDetail)
+	// Without a link the email points the user to the resend option instead of an empty line
+	verificationStep := "Please confirm your email address using the link below, then complete your KYC to start using our services:"
+	verificationLink, err := s.newEmailVerificationLink(ctx, createdUser.ID)
 	if err != nil {
-		log.Printf("Error creating initial KYC record for user %d: %v", createdUser.ID, err)
-	} else {
-		createdUser.KYCID = &kycDetail.ID
-		if _, err := s.userRepo.Update(ctx, createdUser); err != nil {
-			log.Printf("Error updating user %d with KYCID: %v", createdUser.ID, err)
-		}
+		log.Printf("Failed to create email verification token for user %d: %v", createdUser.ID, err)
+		verificationStep = "Please log in and request a new email verification link from your account, then complete your KYC to start using our services."
 	}
 
 	go func() {
 		subject := "Welcome to Invoice Financing App!"
-		body := fmt.Sprintf("Hi %s,\n\nWelcome to our platform! Your account has been created successfully.\nPlease complete your KYC to start using our services.\n\nThanks,\nThe Team", createdUser.FirstName)
+		body := fmt.Sprintf("Hi %s,\n\nWelcome to our platform! Your account has been created successfully.\n%s\n%s\n\nThanks,\nThe Team", createdUser.FirstName, verificationStep, verificationLink)
 		err := s.emailService.SendEmail(createdUser.Email, subject, body)
 		if err != nil {
 			log.Printf("Failed to send welcome email to %s: %v", createdUser.Email, err)
@@ -127,7 +183,26 @@ func (s *authService) RegisterUser(ctx context.Context, user *models.User) (*mod
 	return createdUser, nil
 }
 
-func (s *authService) LoginUser(ctx context.Context, email, password string) (*dtos.LoginUserResponse, error) {
+// LoginUser authenticates a user or staff member, refusing the attempt while the account or
+// client IP is throttled and counting wrong passwords towards a lockout.
+func (s *authService) LoginUser(ctx context.Context, email, password string, client ClientInfo) (*dtos.LoginUserResponse, error) {
+	account := NormalizeLoginAccount(email)
+	if err := s.loginGuard.Check(ctx, account, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.authenticate(ctx, email, password, client)
+	if errors.Is(err, ErrInvalidCredentials) {
+		s.recordFailedLoginAttempt(ctx, account, client)
+		return nil, err
+	}
+	if err == nil && !resp.TwoFARequired {
+		_ = s.loginGuard.RecordSuccess(ctx, account)
+	}
+	return resp, err
+}
+
+func (s *authService) authenticate(ctx context.Context, email, password string, client ClientInfo) (*dtos.LoginUserResponse, error) {
 	// Attempt to find and authenticate as a regular user first
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err == nil && user != nil { // User found by email
@@ -140,13 +215,35 @@ func (s *authService) LoginUser(ctx context.Context, email, password string) (*d
 			}
 
 			userInfo := &dtos.UserResponse{
-				ID:           user.ID,
-				Email:        user.Email,
-				FirstName:    user.FirstName,
-				LastName:     user.LastName,
-				CompanyName:  user.CompanyName,
-				IsActive:     user.IsActive,
-				TwoFAEnabled: user.TwoFAEnabled,
+				ID:            user.ID,
+				Email:         user.Email,
+				FirstName:     user.FirstName,
+				LastName:      user.LastName,
+				CompanyName:   user.CompanyName,
+				IsActive:      user.IsActive,
+				EmailVerified: user.IsEmailVerified(),
+				TwoFAEnabled:  user.TwoFAEnabled,
+				TwoFAMethod:   user.TwoFAMethod,
+			}
+
+			var twoFAToken string
+			if user.TwoFAEnabled {
+				twoFAToken, err = s.issue2FALoginToken(ctx, PrincipalTypeUser, user.ID)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if user.TwoFAEnabled && user.TwoFAMethod == models.TwoFAMethodTOTP {
+				return &dtos.LoginUserResponse{
+					User:          userInfo,
+					Message:       "Enter the code from your authenticator app to complete login.",
+					TwoFARequired: true,
+					TwoFAMethod:   models.TwoFAMethodTOTP,
+					TwoFAToken:    twoFAToken,
+					Role:          "user",
+					RedirectPath:  "/2fa",
+				}, nil
 			}
 
 			if user.TwoFAEnabled {
@@ -169,27 +266,25 @@ func (s *authService) LoginUser(ctx context.Context, email, password string) (*d
 					User:          userInfo,
 					Message:       "OTP sent to your email for 2FA verification.",
 					TwoFARequired: true,
+					TwoFAMethod:   models.TwoFAMethodEmail,
+					TwoFAToken:    twoFAToken,
 					Role:          "user",
 					RedirectPath:  "/2fa",
 				}, nil
 			}
 
-			accessToken, accessExp, err := s.jwtService.GenerateAccessToken(user)
-			if err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateToken, err)
-			}
-			refreshToken, _, err := s.jwtService.GenerateRefreshToken(user)
+			tokens, err := s.startUserSession(ctx, user, client)
 			if err != nil {
-				return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateToken, err)
+				return nil, err
 			}
 
 			_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_SUCCESS", fmt.Sprintf("User %s logged in successfully.", user.Email), "")
 
 			return &dtos.LoginUserResponse{
 				User:                 userInfo,
-				AccessToken:          accessToken,
-				RefreshToken:         refreshToken,
-				AccessTokenExpiresAt: accessExp.Unix(),
+				AccessToken:          tokens.AccessToken,
+				RefreshToken:         tokens.RefreshToken,
+				AccessTokenExpiresAt: tokens.AccessExp.Unix(),
 				Message:              "Login successful.",
 				TwoFARequired:        false,
 				Role:                 "user",
@@ -209,31 +304,29 @@ func (s *authService) LoginUser(ctx context.Context, email, password string) (*d
 			return nil, ErrAccountNotActive
 		}
 
-		staffInfo := &dtos.UserResponse{
-			ID:        staff.ID,
-			Email:     staff.Email,
-			FirstName: staff.FirstName,
-			LastName:  staff.LastName,
-			IsActive:  staff.IsActive,
+		staffInfo := mapStaffToUserResponse(staff)
+		if staff.TwoFAEnabled {
+			return s.beginStaff2FAChallenge(ctx, staff, staffInfo)
 		}
-
-		accessToken, accessExp, err := s.jwtService.GenerateAccessTokenForStaff(staff)
-		if err != nil {
-			return nil, fmt.Errorf("%w for staff: %v", ErrFailedToGenerateToken, err)
+		if Staff2FARequired(s.cfg, staff.Role) {
+			return s.beginStaff2FAEnrollmentSession(ctx, staff, staffInfo)
 		}
-		refreshToken, _, err := s.jwtService.GenerateRefreshTokenForStaff(staff)
+
+		tokens, err := s.startStaffSession(ctx, staff, client, false)
 		if err != nil {
-			return nil, fmt.Errorf("%w for staff: %v", ErrFailedToGenerateToken, err)
+			return nil, err
 		}
 
+		s.touchStaffLastLogin(ctx, staff)
+
 		activityDetails := fmt.Sprintf("Staff %s (Role: %s) logged in successfully.", staff.Email, staff.Role)
 		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_LOGIN_SUCCESS", activityDetails, "")
 
 		return &dtos.LoginUserResponse{
 			User:                 staffInfo,
-			AccessToken:          accessToken,
-			RefreshToken:         refreshToken,
-			AccessTokenExpiresAt: accessExp.Unix(),
+			AccessToken:          tokens.AccessToken,
+			RefreshToken:         tokens.RefreshToken,
+			AccessTokenExpiresAt: tokens.AccessExp.Unix(),
 			Message:              "Staff login successful.",
 			TwoFARequired:        false,
 			Role:                 staff.Role,
@@ -308,182 +401,1454 @@ func (s *authService) LoginUser(ctx context.Context, email, password string) (*d
 //	}, nil
 //}
 
-func (s *authService) VerifyOTP(ctx context.Context, email, otp string) (*dtos.LoginUserResponse, error) {
+// VerifyOTP completes a login held back for 2FA. The 2FA token proves the password step
+// succeeded and is used up by the attempt, so every guess at a code needs the password again.
+func (s *authService) VerifyOTP(ctx context.Context, email, twoFAToken, otp string, client ClientInfo) (*dtos.LoginUserResponse, error) {
+	account := NormalizeLoginAccount(email)
+	if err := s.loginGuard.Check(ctx, account, client.IPAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil || user == nil {
+		if staff, _ := s.staffRepo.FindByEmail(ctx, email); staff != nil {
+			return s.verifyStaffOTP(ctx, staff, twoFAToken, otp, client)
+		}
 		return nil, ErrUserNotFound
 	}
 
 	if !user.TwoFAEnabled {
 		return nil, Err2FANotEnabled
 	}
+	if err := s.consume2FALoginToken(ctx, twoFAToken, PrincipalTypeUser, user.ID); err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrAccountNotActive
+	}
 
 	userIDStr := strconv.FormatUint(uint64(user.ID), 10)
-	valid, err := s.otpService.VerifyOTP(ctx, userIDStr, otp)
-	if err != nil {
-		log.Printf("Error verifying OTP for user %s: %v", user.Email, err)
-		return nil, ErrOTPInvalidOrExpired
+	var valid bool
+	if user.TwoFAMethod == models.TwoFAMethodTOTP {
+		if user.TwoFASecret == nil || *user.TwoFASecret == "" {
+			return nil, ErrTOTPNotEnrolled
+		}
+		valid, err = s.checkTOTPCode(ctx, principalSubject(PrincipalTypeUser, user.ID), otp, *user.TwoFASecret)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		valid, err = s.otpService.VerifyOTP(ctx, userIDStr, otp)
+		if err != nil {
+			log.Printf("Error verifying OTP for user %s: %v", user.Email, err)
+			return nil, ErrOTPInvalidOrExpired
+		}
 	}
 	if !valid {
-		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_2FA_FAILED", fmt.Sprintf("User %s failed 2FA OTP verification.", user.Email), "")
+		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_2FA_FAILED", fmt.Sprintf("User %s failed 2FA OTP verification.", user.Email), client.IPAddress)
+		s.recordFailedLoginAttempt(ctx, account, client)
 		return nil, ErrOTPInvalidOrExpired
 	}
 
-	accessToken, accessExp, err := s.jwtService.GenerateAccessToken(user)
+	if user.TwoFAMethod != models.TwoFAMethodTOTP {
+		if err := s.otpService.DeleteOTP(ctx, userIDStr); err != nil {
+			log.Printf("Warning: Failed to delete OTP for user %s after verification: %v", userIDStr, err)
+		}
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_2FA_SUCCESS", fmt.Sprintf("User %s logged in successfully via 2FA.", user.Email), "")
+
+	return s.completeUser2FALogin(ctx, user, client, "OTP verified successfully. Login complete.")
+}
+
+// VerifyRecoveryCode completes a 2FA login with one of the user's single-use recovery codes
+// instead of an email OTP or authenticator code. Like VerifyOTP it needs the 2FA token from the
+// password step.
+func (s *authService) VerifyRecoveryCode(ctx context.Context, email, twoFAToken, code string, client ClientInfo) (*dtos.LoginUserResponse, error) {
+	account := NormalizeLoginAccount(email)
+	if err := s.loginGuard.Check(ctx, account, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.TwoFAEnabled {
+		return nil, Err2FANotEnabled
+	}
+	if err := s.consume2FALoginToken(ctx, twoFAToken, PrincipalTypeUser, user.ID); err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrAccountNotActive
+	}
+
+	consumed, err := s.recoveryCodeRepo.Consume(ctx, user.ID, hashRecoveryCode(code))
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, fmt.Errorf("could not verify recovery code: %w", err)
 	}
-	refreshToken, _, err := s.jwtService.GenerateRefreshToken(user)
+	if !consumed {
+		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_2FA_FAILED", fmt.Sprintf("User %s entered an invalid 2FA recovery code.", user.Email), client.IPAddress)
+		s.recordFailedLoginAttempt(ctx, account, client)
+		return nil, ErrRecoveryCodeInvalid
+	}
+
+	remaining, err := s.recoveryCodeRepo.CountUnused(ctx, user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		log.Printf("Warning: Failed to count remaining recovery codes for user %d: %v", user.ID, err)
 	}
+	_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_2FA_RECOVERY_USED",
+		map[string]interface{}{"email": user.Email, "remaining_codes": remaining}, "")
+
+	go func() {
+		subject := "A 2FA recovery code was used"
+		body := fmt.Sprintf("Hi %s,\n\nA recovery code was just used to sign in to your account. You have %d recovery code(s) left.\nIf this wasn't you, reset your password and regenerate your recovery codes immediately.\n\nThanks,\nThe Team", user.FirstName, remaining)
+		if emailErr := s.emailService.SendEmail(user.Email, subject, body); emailErr != nil {
+			log.Printf("Failed to send recovery code usage email to %s: %v", user.Email, emailErr)
+		}
+	}()
+
+	return s.completeUser2FALogin(ctx, user, client, "Recovery code accepted. Login complete.")
+}
 
-	if err := s.otpService.DeleteOTP(ctx, userIDStr); err != nil {
-		log.Printf("Warning: Failed to delete OTP for user %s after verification: %v", userIDStr, err)
+func (s *authService) completeUser2FALogin(ctx context.Context, user *models.User, client ClientInfo, message string) (*dtos.LoginUserResponse, error) {
+	tokens, err := s.startUserSession(ctx, user, client)
+	if err != nil {
+		return nil, err
 	}
+	_ = s.loginGuard.RecordSuccess(ctx, NormalizeLoginAccount(user.Email))
 
 	userResponse := dtos.UserResponse{
-		ID:           user.ID,
-		Email:        user.Email,
-		FirstName:    user.FirstName,
-		LastName:     user.LastName,
-		CompanyName:  user.CompanyName,
-		IsActive:     user.IsActive,
-		TwoFAEnabled: user.TwoFAEnabled,
+		ID:            user.ID,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		CompanyName:   user.CompanyName,
+		IsActive:      user.IsActive,
+		EmailVerified: user.IsEmailVerified(),
+		TwoFAEnabled:  user.TwoFAEnabled,
+		TwoFAMethod:   user.TwoFAMethod,
 	}
 
-	_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_2FA_SUCCESS", fmt.Sprintf("User %s logged in successfully via 2FA.", user.Email), "")
-
 	return &dtos.LoginUserResponse{
 		User:                 &userResponse,
-		AccessToken:          accessToken,
-		RefreshToken:         refreshToken,
+		AccessToken:          tokens.AccessToken,
+		RefreshToken:         tokens.RefreshToken,
 		TwoFARequired:        false,
-		AccessTokenExpiresAt: accessExp.Unix(),
-		Message:              "OTP verified successfully. Login complete.",
+		AccessTokenExpiresAt: tokens.AccessExp.Unix(),
+		Message:              message,
 		Role:                 "user",
 		RedirectPath:         "/home",
 	}, nil
 }
 
-func (s *authService) RefreshToken(ctx context.Context, tokenStr string) (*dtos.RefreshTokenResponse, error) {
-	claims, err := s.jwtService.ValidateToken(tokenStr, true)
+// checkTOTPCode validates an authenticator code and refuses it if its time step was already used
+// by the principal, so a code seen once cannot be replayed within the skew window.
+func (s *authService) checkTOTPCode(ctx context.Context, subject, code, secret string) (bool, error) {
+	step, ok := s.totpService.ValidateCode(code, secret)
+	if !ok {
+		return false, nil
+	}
+	return s.otpService.ClaimTOTPStep(ctx, subject, step, totpReplayWindow)
+}
+
+// issue2FALoginToken records that a principal passed the password step of a login, for the
+// second-factor step to consume.
+func (s *authService) issue2FALoginToken(ctx context.Context, principalType string, id uint) (string, error) {
+	token, err := s.otpService.IssueActionToken(ctx, ActionToken2FALogin, principalSubject(principalType, id), s.cfg.OTPExpirationMinutes)
 	if err != nil {
-		return nil, ErrRefreshTokenInvalid
+		log.Printf("Failed to issue 2FA login token for %s %d: %v", principalType, id, err)
+		return "", ErrFailedToInitiate2FA
 	}
+	return token, nil
+}
 
-	isBlacklisted, _ := s.otpService.IsTokenBlacklisted(ctx, tokenStr)
-	if isBlacklisted {
-		return nil, ErrTokenBlacklisted
+// consume2FALoginToken uses up a 2FA login token and checks it was issued to the principal.
+func (s *authService) consume2FALoginToken(ctx context.Context, token, principalType string, id uint) error {
+	if token == "" {
+		return Err2FALoginInvalid
+	}
+	subject, err := s.otpService.ConsumeActionToken(ctx, ActionToken2FALogin, token)
+	if err != nil {
+		return fmt.Errorf("could not check 2FA login token: %w", err)
 	}
+	if subject != principalSubject(principalType, id) {
+		return Err2FALoginInvalid
+	}
+	return nil
+}
 
-	userIDStr, ok := claims["user_id"].(string)
-	if !ok {
-		return nil, ErrRefreshTokenInvalid
+// Staff2FARequired reports whether the 2FA policy (STAFF_2FA_REQUIRED_ROLES) covers the role.
+func Staff2FARequired(cfg *config.Config, role string) bool {
+	for _, required := range cfg.Staff2FARequiredRoles {
+		if required == role {
+			return true
+		}
 	}
-	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	return false
+}
+
+// beginStaff2FAChallenge asks an enrolled staff member for their second factor, emailing an OTP
+// unless they use an authenticator app. No tokens are issued until the code is verified, only
+// the 2FA token that proves the password step.
+func (s *authService) beginStaff2FAChallenge(ctx context.Context, staff *models.Staff, staffInfo *dtos.UserResponse) (*dtos.LoginUserResponse, error) {
+	twoFAToken, err := s.issue2FALoginToken(ctx, PrincipalTypeStaff, staff.ID)
 	if err != nil {
-		return nil, ErrRefreshTokenInvalid
+		return nil, err
+	}
+	resp := &dtos.LoginUserResponse{
+		User:          staffInfo,
+		TwoFARequired: true,
+		TwoFAMethod:   staff.TwoFAMethod,
+		TwoFAToken:    twoFAToken,
+		Role:          staff.Role,
+		RedirectPath:  "/admin/2fa",
+	}
+	if staff.TwoFAMethod == models.TwoFAMethodTOTP {
+		resp.Message = "Enter the code from your authenticator app to complete login."
+		return resp, nil
 	}
-	userID := uint(parsedUserID)
 
-	user, err := s.userRepo.FindByID(ctx, userID)
-	if err != nil || user == nil {
-		return nil, ErrUserNotFound
+	if err := s.sendStaffOTP(ctx, staff, "Your 2FA Login Code"); err != nil {
+		return nil, err
 	}
+	resp.TwoFAMethod = models.TwoFAMethodEmail
+	resp.Message = "OTP sent to your email for 2FA verification."
+	return resp, nil
+}
 
-	if !user.IsActive {
+// beginStaff2FAEnrollmentSession is used when the 2FA policy covers a staff member who has not
+// enrolled yet. Instead of tokens they get a short-lived enrollment token that only unlocks
+// the enrollment endpoints.
+func (s *authService) beginStaff2FAEnrollmentSession(ctx context.Context, staff *models.Staff, staffInfo *dtos.UserResponse) (*dtos.LoginUserResponse, error) {
+	enrollmentToken, err := s.otpService.IssueActionToken(ctx, ActionTokenStaff2FAEnroll, principalSubject(PrincipalTypeStaff, staff.ID), s.cfg.TOTPEnrollmentExpiry)
+	if err != nil {
+		return nil, ErrFailedToInitiate2FA
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_2FA_ENROLLMENT_REQUIRED", map[string]interface{}{"email": staff.Email, "role": staff.Role}, "")
+
+	return &dtos.LoginUserResponse{
+		User:                    staffInfo,
+		Message:                 "Your role requires two-factor authentication. Set it up to complete login.",
+		TwoFARequired:           true,
+		TwoFAEnrollmentRequired: true,
+		EnrollmentToken:         enrollmentToken,
+		Role:                    staff.Role,
+		RedirectPath:            "/admin/2fa/setup",
+	}, nil
+}
+
+func (s *authService) verifyStaffOTP(ctx context.Context, staff *models.Staff, twoFAToken, otp string, client ClientInfo) (*dtos.LoginUserResponse, error) {
+	if !staff.TwoFAEnabled {
+		return nil, Err2FANotEnabled
+	}
+	if err := s.consume2FALoginToken(ctx, twoFAToken, PrincipalTypeStaff, staff.ID); err != nil {
+		return nil, err
+	}
+	if !staff.IsActive {
 		return nil, ErrAccountNotActive
 	}
 
-	newAccessToken, accessExp, err := s.jwtService.GenerateAccessToken(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate new access token: %w", err)
+	subject := principalSubject(PrincipalTypeStaff, staff.ID)
+	var valid bool
+	if staff.TwoFAMethod == models.TwoFAMethodTOTP {
+		if staff.TwoFASecret == nil || *staff.TwoFASecret == "" {
+			return nil, ErrTOTPNotEnrolled
+		}
+		var err error
+		valid, err = s.checkTOTPCode(ctx, subject, otp, *staff.TwoFASecret)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		valid, err = s.otpService.VerifyOTP(ctx, subject, otp)
+		if err != nil {
+			log.Printf("Error verifying OTP for staff %s: %v", staff.Email, err)
+			return nil, ErrOTPInvalidOrExpired
+		}
 	}
+	if !valid {
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_LOGIN_2FA_FAILED", fmt.Sprintf("Staff %s failed 2FA verification.", staff.Email), client.IPAddress)
+		s.recordFailedLoginAttempt(ctx, NormalizeLoginAccount(staff.Email), client)
+		return nil, ErrOTPInvalidOrExpired
+	}
+
+	if staff.TwoFAMethod != models.TwoFAMethodTOTP {
+		if err := s.otpService.DeleteOTP(ctx, subject); err != nil {
+			log.Printf("Warning: Failed to delete OTP for staff %d after verification: %v", staff.ID, err)
+		}
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_LOGIN_2FA_SUCCESS", fmt.Sprintf("Staff %s verified their second factor.", staff.Email), "")
+	return s.completeStaff2FALogin(ctx, staff, client, "OTP verified successfully. Login complete.")
+}
 
-	newRefreshToken, _, err := s.jwtService.GenerateRefreshToken(user)
+// BeginStaff2FAEnrollment starts the forced enrollment for the staff member behind the
+// enrollment token: it emails a code for the email method, or returns a new authenticator
+// secret to scan for TOTP.
+func (s *authService) BeginStaff2FAEnrollment(ctx context.Context, enrollmentToken, method string) (*dtos.StaffTwoFAEnrollmentResponse, error) {
+	staff, err := s.staffForEnrollment(ctx, enrollmentToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate new refresh token: %w", err)
+		return nil, err
 	}
 
-	return &dtos.RefreshTokenResponse{
-		AccessToken:          newAccessToken,
-		RefreshToken:         newRefreshToken,
-		AccessTokenExpiresAt: accessExp.Unix(),
-		Message:              "Token refreshed successfully.",
+	if method == models.TwoFAMethodTOTP {
+		enrollment, err := s.totpService.GenerateEnrollment(staff.Email)
+		if err != nil {
+			log.Printf("Failed to generate TOTP enrollment for staff %s: %v", staff.Email, err)
+			return nil, ErrFailedToInitiate2FA
+		}
+		if err := s.otpService.StorePendingTOTPSecret(ctx, principalSubject(PrincipalTypeStaff, staff.ID), enrollment.Secret, s.cfg.TOTPEnrollmentExpiry); err != nil {
+			return nil, ErrFailedToInitiate2FA
+		}
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_2FA_TOTP_ENROLLMENT_STARTED", fmt.Sprintf("Staff %s started authenticator app enrollment.", staff.Email), "")
+
+		return &dtos.StaffTwoFAEnrollmentResponse{
+			Method:     models.TwoFAMethodTOTP,
+			Message:    "Scan the QR code with your authenticator app and confirm with the first code it shows.",
+			Secret:     enrollment.Secret,
+			OTPAuthURL: enrollment.OTPAuthURL,
+			QRCodePNG:  enrollment.QRCodePNG,
+			ExpiresIn:  int(s.cfg.TOTPEnrollmentExpiry.Seconds()),
+		}, nil
+	}
+
+	if err := s.sendStaffOTP(ctx, staff, "Confirm your 2FA setup"); err != nil {
+		return nil, err
+	}
+	return &dtos.StaffTwoFAEnrollmentResponse{
+		Method:    models.TwoFAMethodEmail,
+		Message:   "A confirmation code has been sent to your email.",
+		ExpiresIn: int(s.cfg.OTPExpirationMinutes.Seconds()),
 	}, nil
 }
 
-func (s *authService) LogoutUser(ctx context.Context, tokenStr string) error {
-	claims, err := s.jwtService.ValidateToken(tokenStr, false)
+// ConfirmStaff2FAEnrollment checks the first code, enables 2FA on the staff account and
+// completes the login that was held back for enrollment.
+func (s *authService) ConfirmStaff2FAEnrollment(ctx context.Context, enrollmentToken, method, code string, client ClientInfo) (*dtos.LoginUserResponse, error) {
+	staff, err := s.staffForEnrollment(ctx, enrollmentToken)
 	if err != nil {
-		log.Printf("Logout: Validating access token failed (possibly expired): %v", err)
+		return nil, err
+	}
+	account := NormalizeLoginAccount(staff.Email)
+	if err := s.loginGuard.Check(ctx, account, client.IPAddress); err != nil {
+		return nil, err
 	}
 
-	var expiryDuration time.Duration
-	if claims != nil {
-		if expFloat, ok := claims["exp"].(float64); ok {
-			expTime := time.Unix(int64(expFloat), 0)
-			if expTime.After(time.Now()) {
-				expiryDuration = time.Until(expTime)
-			} else {
-				expiryDuration = time.Minute
-			}
-		} else {
-			expiryDuration = s.cfg.JWTAccessTokenExpirationMinutes
+	subject := principalSubject(PrincipalTypeStaff, staff.ID)
+	var valid bool
+	var secret string
+	if method == models.TwoFAMethodTOTP {
+		secret, err = s.otpService.GetPendingTOTPSecret(ctx, subject)
+		if err != nil {
+			return nil, fmt.Errorf("could not load pending TOTP enrollment: %w", err)
+		}
+		if secret == "" {
+			return nil, ErrTOTPEnrollmentExpired
+		}
+		valid, err = s.checkTOTPCode(ctx, subject, code, secret)
+		if err != nil {
+			return nil, err
 		}
 	} else {
-		expiryDuration = s.cfg.JWTAccessTokenExpirationMinutes
+		valid, err = s.otpService.VerifyOTP(ctx, subject, code)
+		if err != nil {
+			log.Printf("Error verifying enrollment OTP for staff %s: %v", staff.Email, err)
+			return nil, ErrOTPInvalidOrExpired
+		}
+	}
+	if !valid {
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_2FA_ENROLLMENT_FAILED", fmt.Sprintf("Staff %s entered an invalid code while setting up 2FA.", staff.Email), client.IPAddress)
+		s.recordFailedLoginAttempt(ctx, account, client)
+		return nil, ErrOTPInvalidOrExpired
 	}
 
-	err = s.otpService.BlacklistToken(ctx, tokenStr, expiryDuration)
-	if err != nil {
-		log.Printf("Failed to blacklist access token on logout: %v", err)
-		return fmt.Errorf("failed to blacklist token: %w", err)
+	// Consuming the token last keeps it usable for a retry after a mistyped code
+	if consumed, err := s.otpService.ConsumeActionToken(ctx, ActionTokenStaff2FAEnroll, enrollmentToken); err != nil || consumed == "" {
+		return nil, ErrStaffEnrollmentInvalid
 	}
 
-	if claims != nil {
-		userIDStr, ok := claims["user_id"].(string)
-		if ok {
-			parsedUserID, pErr := strconv.ParseUint(userIDStr, 10, 64)
-			if pErr == nil {
-				uid := uint(parsedUserID)
-				_ = s.activityLogService.LogActivity(ctx, nil, &uid, "USER_LOGOUT", fmt.Sprintf("User ID %d logged out.", uid), "")
-			}
+	staff.TwoFAEnabled = true
+	staff.TwoFAMethod = method
+	staff.TwoFASecret = nil
+	if method == models.TwoFAMethodTOTP {
+		staff.TwoFASecret = &secret
+	}
+	if err := s.staffRepo.Update(ctx, staff); err != nil {
+		log.Printf("Failed to enable 2FA for staff %d: %v", staff.ID, err)
+		return nil, fmt.Errorf("could not save 2FA enrollment: %w", err)
+	}
+
+	if method == models.TwoFAMethodTOTP {
+		if err := s.otpService.DeletePendingTOTPSecret(ctx, subject); err != nil {
+			log.Printf("Warning: Failed to delete pending TOTP secret for staff %d after confirmation: %v", staff.ID, err)
 		}
+	} else if err := s.otpService.DeleteOTP(ctx, subject); err != nil {
+		log.Printf("Warning: Failed to delete enrollment OTP for staff %d: %v", staff.ID, err)
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_2FA_ENABLED", map[string]interface{}{"method": method}, "")
+	return s.completeStaff2FALogin(ctx, staff, client, "Two-factor authentication enabled. Login complete.")
+}
+
+// ResetStaff2FA clears a staff member's second factor, e.g. after a lost phone, and signs
+// them out everywhere. If their role requires 2FA they must enroll again at the next login.
+func (s *authService) ResetStaff2FA(ctx context.Context, adminStaffID, staffID uint) error {
+	staff, err := s.staffRepo.FindByID(ctx, staffID)
+	if err != nil || staff == nil {
+		return ErrStaffNotFound
+	}
+
+	staff.TwoFAEnabled = false
+	staff.TwoFAMethod = models.TwoFAMethodEmail
+	staff.TwoFASecret = nil
+	if err := s.staffRepo.Update(ctx, staff); err != nil {
+		log.Printf("Failed to reset 2FA for staff %d: %v", staffID, err)
+		return fmt.Errorf("could not reset 2FA: %w", err)
+	}
+	if err := s.sessionService.RevokeAllForSubject(ctx, principalSubject(PrincipalTypeStaff, staffID)); err != nil {
+		log.Printf("Failed to revoke sessions of staff %d after 2FA reset: %v", staffID, err)
 	}
+
+	_ = s.activityLogService.LogActivity(ctx, &adminStaffID, nil, "ADMIN_STAFF_2FA_RESET", map[string]interface{}{"staff_id": staffID, "email": staff.Email}, "")
 	return nil
 }
 
-func (s *authService) Toggle2FA(ctx context.Context, userIDStr string, enable bool) error {
-	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+// staffForEnrollment resolves an enrollment token to an active staff account without using
+// the token up.
+func (s *authService) staffForEnrollment(ctx context.Context, enrollmentToken string) (*models.Staff, error) {
+	subject, err := s.otpService.PeekActionToken(ctx, ActionTokenStaff2FAEnroll, enrollmentToken)
 	if err != nil {
-		return fmt.Errorf("invalid user ID format: %w", err)
+		return nil, fmt.Errorf("could not check enrollment token: %w", err)
 	}
-	userID := uint(parsedUserID)
+	principalType, staffID, ok := parsePrincipalSubject(subject)
+	if !ok || principalType != PrincipalTypeStaff {
+		return nil, ErrStaffEnrollmentInvalid
+	}
+	staff, err := s.staffRepo.FindByID(ctx, staffID)
+	if err != nil || staff == nil {
+		return nil, ErrStaffEnrollmentInvalid
+	}
+	if !staff.IsActive {
+		return nil, ErrAccountNotActive
+	}
+	return staff, nil
+}
 
-	user, err := s.userRepo.FindByID(ctx, userID)
-	if err != nil || user == nil {
-		return ErrUserNotFound
+func (s *authService) sendStaffOTP(ctx context.Context, staff *models.Staff, subject string) error {
+	otp, err := s.otpService.GenerateAndStoreOTP(ctx, principalSubject(PrincipalTypeStaff, staff.ID))
+	if err != nil {
+		log.Printf("Failed to generate OTP for staff %s: %v", staff.Email, err)
+		return ErrFailedToInitiate2FA
 	}
 
-	user.TwoFAEnabled = enable
-	if !enable {
-		user.EmailOTP = nil
-		user.EmailOTPExp = nil
+	go func() {
+		body := fmt.Sprintf("Hi %s,\n\nYour One-Time Password is: %s\nIt will expire in %d minutes.\n\nThanks,\nThe Team", staff.FirstName, otp, int(s.cfg.OTPExpirationMinutes.Minutes()))
+		if emailErr := s.emailService.SendEmail(staff.Email, subject, body); emailErr != nil {
+			log.Printf("Failed to send 2FA OTP email to staff %s: %v", staff.Email, emailErr)
+		}
+	}()
+	return nil
+}
+
+func (s *authService) completeStaff2FALogin(ctx context.Context, staff *models.Staff, client ClientInfo, message string) (*dtos.LoginUserResponse, error) {
+	tokens, err := s.startStaffSession(ctx, staff, client, true)
+	if err != nil {
+		return nil, err
 	}
+	_ = s.loginGuard.RecordSuccess(ctx, NormalizeLoginAccount(staff.Email))
+	s.touchStaffLastLogin(ctx, staff)
 
-	_, err = s.userRepo.Update(ctx, user)
+	activityDetails := fmt.Sprintf("Staff %s (Role: %s) logged in successfully.", staff.Email, staff.Role)
+	_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_LOGIN_SUCCESS", activityDetails, "")
+
+	return &dtos.LoginUserResponse{
+		User:                 mapStaffToUserResponse(staff),
+		AccessToken:          tokens.AccessToken,
+		RefreshToken:         tokens.RefreshToken,
+		AccessTokenExpiresAt: tokens.AccessExp.Unix(),
+		Message:              message,
+		TwoFARequired:        false,
+		Role:                 staff.Role,
+		RedirectPath:         "/admin",
+	}, nil
+}
+
+func mapStaffToUserResponse(staff *models.Staff) *dtos.UserResponse {
+	return &dtos.UserResponse{
+		ID:           staff.ID,
+		Email:        staff.Email,
+		FirstName:    staff.FirstName,
+		LastName:     staff.LastName,
+		IsActive:     staff.IsActive,
+		TwoFAEnabled: staff.TwoFAEnabled,
+		TwoFAMethod:  staff.TwoFAMethod,
+	}
+}
+
+func (s *authService) RefreshToken(ctx context.Context, tokenStr string, client ClientInfo) (*dtos.RefreshTokenResponse, error) {
+	claims, err := s.jwtService.ValidateToken(tokenStr, true)
 	if err != nil {
-		log.Printf("Failed to update 2FA status for user %d: %v", userID, err)
-		return fmt.Errorf("could not update 2FA status: %w", err)
+		return nil, ErrRefreshTokenInvalid
 	}
 
-	action := "USER_2FA_DISABLED"
-	if enable {
-		action = "USER_2FA_ENABLED"
+	isBlacklisted, _ := s.otpService.IsTokenBlacklisted(ctx, tokenStr)
+	if isBlacklisted {
+		return nil, ErrTokenBlacklisted
+	}
+	if revoked, _ := s.otpService.IsTokenRevoked(ctx, claims); revoked {
+		return nil, ErrTokenRevoked
 	}
-	_ = s.activityLogService.LogActivity(ctx, nil, &userID, action, fmt.Sprintf("User ID %d %s 2FA.", userID, action), "")
 
-	return nil
+	idStr, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+	parsedID, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	familyID, _ := claims["sid"].(string)
+	tokenID, _ := claims["jti"].(string)
+	if familyID == "" || tokenID == "" {
+		return nil, ErrRefreshTokenInvalid // Issued before session tracking; require a fresh login
+	}
+
+	principalType := PrincipalTypeFromClaims(claims)
+	if principalType == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	nextTokenID, err := s.sessionService.Rotate(ctx, familyID, tokenID, client)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			s.handleRefreshTokenReuse(ctx, principalType, uint(parsedID), familyID)
+		}
+		return nil, err
+	}
+
+	switch principalType {
+	case PrincipalTypeUser:
+		return s.refreshUserToken(ctx, uint(parsedID), familyID, nextTokenID)
+	case PrincipalTypeStaff:
+		return s.refreshStaffToken(ctx, uint(parsedID), familyID, nextTokenID, MFAVerifiedFromClaims(claims))
+	}
+	return nil, ErrRefreshTokenInvalid
+}
+
+func (s *authService) refreshUserToken(ctx context.Context, userID uint, familyID, tokenID string) (*dtos.RefreshTokenResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.IsActive {
+		_ = s.sessionService.RevokeFamily(ctx, familyID)
+		return nil, ErrAccountNotActive
+	}
+
+	tokens, err := s.userTokenPair(user, familyID, tokenID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.RefreshTokenResponse{
+		AccessToken:          tokens.AccessToken,
+		RefreshToken:         tokens.RefreshToken,
+		AccessTokenExpiresAt: tokens.AccessExp.Unix(),
+		Message:              "Token refreshed successfully.",
+		Role:                 "user",
+		RedirectPath:         "/home",
+	}, nil
+}
+
+// refreshStaffToken re-checks that the staff account is still active and holds a valid role
+// before issuing new tokens, so deactivated or demoted staff lose access at the next refresh.
+// Sessions opened without a second factor end once the staff member's role requires one.
+func (s *authService) refreshStaffToken(ctx context.Context, staffID uint, familyID, tokenID string, mfa bool) (*dtos.RefreshTokenResponse, error) {
+	staff, err := s.staffRepo.FindByID(ctx, staffID)
+	if err != nil || staff == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !staff.IsActive {
+		_ = s.sessionService.RevokeFamily(ctx, familyID)
+		return nil, ErrAccountNotActive
+	}
+	if !models.IsStaffRole(staff.Role) {
+		log.Printf("Refusing token refresh for staff %d with unknown role %q", staff.ID, staff.Role)
+		_ = s.sessionService.RevokeFamily(ctx, familyID)
+		return nil, ErrRefreshTokenInvalid
+	}
+	if !mfa && Staff2FARequired(s.cfg, staff.Role) {
+		_ = s.sessionService.RevokeFamily(ctx, familyID)
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	tokens, err := s.staffTokenPair(staff, familyID, tokenID, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	s.touchStaffLastLogin(ctx, staff)
+
+	return &dtos.RefreshTokenResponse{
+		AccessToken:          tokens.AccessToken,
+		RefreshToken:         tokens.RefreshToken,
+		AccessTokenExpiresAt: tokens.AccessExp.Unix(),
+		Message:              "Token refreshed successfully.",
+		Role:                 staff.Role,
+		RedirectPath:         "/admin",
+	}, nil
+}
+
+// handleRefreshTokenReuse runs after a replayed refresh token has revoked its family. A replay
+// means the token was most likely stolen, so the account owner is alerted.
+func (s *authService) handleRefreshTokenReuse(ctx context.Context, principalType string, id uint, familyID string) {
+	details := map[string]interface{}{"session_id": familyID}
+	var email, firstName string
+	switch principalType {
+	case PrincipalTypeUser:
+		_ = s.activityLogService.LogActivity(ctx, nil, &id, "USER_REFRESH_TOKEN_REUSE", details, "")
+		if user, err := s.userRepo.FindByID(ctx, id); err == nil && user != nil {
+			email, firstName = user.Email, user.FirstName
+		}
+	case PrincipalTypeStaff:
+		_ = s.activityLogService.LogActivity(ctx, &id, nil, "STAFF_REFRESH_TOKEN_REUSE", details, "")
+		if staff, err := s.staffRepo.FindByID(ctx, id); err == nil && staff != nil {
+			email, firstName = staff.Email, staff.FirstName
+		}
+	}
+	if email == "" {
+		return
+	}
+
+	go func() {
+		subject := "Security alert: a session was signed out"
+		body := fmt.Sprintf("Hi %s,\n\nWe detected an attempt to reuse an old sign-in token for your account, which can mean it was copied from one of your devices. As a precaution we signed that session out.\nIf you don't recognise this, change your password immediately.\n\nThanks,\nThe Team", firstName)
+		if emailErr := s.emailService.SendEmail(email, subject, body); emailErr != nil {
+			log.Printf("Failed to send refresh token reuse alert to %s: %v", email, emailErr)
+		}
+	}()
+}
+
+// ListSessions returns the active sessions of a user or staff account. currentSessionID marks
+// the session the request was made from.
+func (s *authService) ListSessions(ctx context.Context, principalType string, id uint, currentSessionID string) ([]dtos.SessionResponse, error) {
+	sessions, err := s.sessionService.ListForSubject(ctx, principalSubject(principalType, id))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dtos.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, dtos.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+	return responses, nil
+}
+
+// RevokeSession signs one of the account's own sessions out.
+func (s *authService) RevokeSession(ctx context.Context, principalType string, id uint, sessionID string) error {
+	revoked, err := s.sessionService.RevokeForSubject(ctx, principalSubject(principalType, id), sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+
+	details := map[string]interface{}{"session_id": sessionID}
+	if principalType == PrincipalTypeStaff {
+		_ = s.activityLogService.LogActivity(ctx, &id, nil, "STAFF_SESSION_REVOKED", details, "")
+	} else {
+		_ = s.activityLogService.LogActivity(ctx, nil, &id, "USER_SESSION_REVOKED", details, "")
+	}
+	return nil
+}
+
+// RevokeAllUserSessions lets an admin sign a customer out everywhere.
+func (s *authService) RevokeAllUserSessions(ctx context.Context, staffID, userID uint) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.sessionService.RevokeAllForSubject(ctx, principalSubject(PrincipalTypeUser, userID)); err != nil {
+		return err
+	}
+	_ = s.activityLogService.LogActivity(ctx, &staffID, &userID, "ADMIN_USER_SESSIONS_REVOKED",
+		fmt.Sprintf("Staff ID %d signed out all sessions of user %s.", staffID, user.Email), "")
+	return nil
+}
+
+// StartImpersonation lets a staff member see the dashboard exactly as the customer does. The
+// returned token is short-lived, read-only and carries the staff ID in its impersonated_by
+// claim; its session is kept apart from both accounts' regular sessions.
+func (s *authService) StartImpersonation(ctx context.Context, staffID, userID uint, client ClientInfo) (*dtos.ImpersonationResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	sessionID, err := s.sessionService.StartShortLived(ctx, impersonationSubject(staffID, userID), client, s.cfg.ImpersonationExpiry)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, expiresAt, err := s.jwtService.GenerateImpersonationToken(user, staffID, sessionID)
+	if err != nil {
+		_ = s.sessionService.RevokeFamily(ctx, sessionID)
+		return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateToken, err)
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, &staffID, &userID, "ADMIN_IMPERSONATION_STARTED", map[string]interface{}{
+		"session_id": sessionID,
+		"user_email": user.Email,
+		"expires_at": expiresAt,
+	}, client.IPAddress)
+
+	return &dtos.ImpersonationResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt.Unix(),
+		SessionID:            sessionID,
+		UserID:               user.ID,
+		ReadOnly:             true,
+	}, nil
+}
+
+// StopImpersonation ends an impersonation session early; its token stops working immediately.
+func (s *authService) StopImpersonation(ctx context.Context, staffID, userID uint, sessionID string) error {
+	revoked, err := s.sessionService.RevokeForSubject(ctx, impersonationSubject(staffID, userID), sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	_ = s.activityLogService.LogActivity(ctx, &staffID, &userID, "ADMIN_IMPERSONATION_STOPPED", map[string]interface{}{"session_id": sessionID}, "")
+	return nil
+}
+
+// recordFailedLoginAttempt counts a wrong password or 2FA code and, if that locks the account,
+// logs the lockout and emails the owner an unlock link.
+func (s *authService) recordFailedLoginAttempt(ctx context.Context, account string, client ClientInfo) {
+	locked, err := s.loginGuard.RecordFailure(ctx, account, client.IPAddress)
+	if err != nil || !locked {
+		return
+	}
+
+	var firstName string
+	details := map[string]interface{}{"email": account, "ip_address": client.IPAddress, "locked_for_minutes": int(s.cfg.LoginLockoutDuration.Minutes())}
+	if user, err := s.userRepo.FindByEmail(ctx, account); err == nil && user != nil {
+		firstName = user.FirstName
+		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_LOGIN_LOCKED", details, client.IPAddress)
+	} else if staff, err := s.staffRepo.FindByEmail(ctx, account); err == nil && staff != nil {
+		firstName = staff.FirstName
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_LOGIN_LOCKED", details, client.IPAddress)
+	} else {
+		return // Unknown email; the lock still slows down guessing but there is nobody to notify
+	}
+
+	token, err := s.otpService.IssueActionToken(ctx, ActionTokenAccountUnlock, account, s.cfg.LoginLockoutDuration)
+	if err != nil {
+		log.Printf("Failed to create unlock token for %s: %v", account, err)
+		return
+	}
+
+	go func() {
+		unlockLink := fmt.Sprintf("%s/unlock-account?token=%s", s.cfg.FrontendBaseURL, token)
+		subject := "Your account has been temporarily locked"
+		body := fmt.Sprintf("Hi %s,\n\nWe locked your account for %d minutes after several failed sign-in attempts.\nIf this was you, you can unlock it now using the link below:\n%s\n\nIf it wasn't you, we recommend resetting your password.\n\nThanks,\nThe Team", firstName, int(s.cfg.LoginLockoutDuration.Minutes()), unlockLink)
+		if emailErr := s.emailService.SendEmail(account, subject, body); emailErr != nil {
+			log.Printf("Failed to send account lockout email to %s: %v", account, emailErr)
+		}
+	}()
+}
+
+// UnlockAccount redeems the unlock link sent when an account was locked.
+func (s *authService) UnlockAccount(ctx context.Context, token string) error {
+	account, err := s.otpService.ConsumeActionToken(ctx, ActionTokenAccountUnlock, token)
+	if err != nil {
+		return fmt.Errorf("could not verify unlock token: %w", err)
+	}
+	if account == "" {
+		return ErrUnlockTokenInvalid
+	}
+	if err := s.loginGuard.Unlock(ctx, account); err != nil {
+		return err
+	}
+
+	if user, err := s.userRepo.FindByEmail(ctx, account); err == nil && user != nil {
+		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_ACCOUNT_UNLOCKED", fmt.Sprintf("User %s unlocked their account via email link.", account), "")
+	} else if staff, err := s.staffRepo.FindByEmail(ctx, account); err == nil && staff != nil {
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_ACCOUNT_UNLOCKED", fmt.Sprintf("Staff %s unlocked their account via email link.", account), "")
+	}
+	return nil
+}
+
+// tokenPair is an access/refresh token pair belonging to one session.
+type tokenPair struct {
+	AccessToken  string
+	AccessExp    time.Time
+	RefreshToken string
+}
+
+// startUserSession opens a new refresh-token family for the user and issues its first token pair.
+func (s *authService) startUserSession(ctx context.Context, user *models.User, client ClientInfo) (*tokenPair, error) {
+	familyID, tokenID, err := s.sessionService.StartFamily(ctx, principalSubject(PrincipalTypeUser, user.ID), client)
+	if err != nil {
+		return nil, err
+	}
+	return s.userTokenPair(user, familyID, tokenID)
+}
+
+// startStaffSession is the staff counterpart of startUserSession. mfa records whether the
+// login verified a second factor; the admin API requires it for roles under the 2FA policy.
+func (s *authService) startStaffSession(ctx context.Context, staff *models.Staff, client ClientInfo, mfa bool) (*tokenPair, error) {
+	familyID, tokenID, err := s.sessionService.StartFamily(ctx, principalSubject(PrincipalTypeStaff, staff.ID), client)
+	if err != nil {
+		return nil, err
+	}
+	return s.staffTokenPair(staff, familyID, tokenID, mfa)
+}
+
+func (s *authService) userTokenPair(user *models.User, familyID, tokenID string) (*tokenPair, error) {
+	accessToken, accessExp, err := s.jwtService.GenerateAccessToken(user, familyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateToken, err)
+	}
+	refreshToken, _, err := s.jwtService.GenerateRefreshToken(user, familyID, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFailedToGenerateToken, err)
+	}
+	return &tokenPair{AccessToken: accessToken, AccessExp: accessExp, RefreshToken: refreshToken}, nil
+}
+
+func (s *authService) staffTokenPair(staff *models.Staff, familyID, tokenID string, mfa bool) (*tokenPair, error) {
+	accessToken, accessExp, err := s.jwtService.GenerateAccessTokenForStaff(staff, familyID, mfa)
+	if err != nil {
+		return nil, fmt.Errorf("%w for staff: %v", ErrFailedToGenerateToken, err)
+	}
+	refreshToken, _, err := s.jwtService.GenerateRefreshTokenForStaff(staff, familyID, tokenID, mfa)
+	if err != nil {
+		return nil, fmt.Errorf("%w for staff: %v", ErrFailedToGenerateToken, err)
+	}
+	return &tokenPair{AccessToken: accessToken, AccessExp: accessExp, RefreshToken: refreshToken}, nil
+}
+
+func (s *authService) touchStaffLastLogin(ctx context.Context, staff *models.Staff) {
+	now := time.Now()
+	staff.LastLoginAt = &now
+	if err := s.staffRepo.Update(ctx, staff); err != nil {
+		log.Printf("Warning: Failed to update last login time for staff %d: %v", staff.ID, err)
+	}
+}
+
+func (s *authService) LogoutUser(ctx context.Context, tokenStr string) error {
+	claims, err := s.jwtService.ValidateToken(tokenStr, false)
+	if err != nil {
+		log.Printf("Logout: Validating access token failed (possibly expired): %v", err)
+	}
+
+	var expiryDuration time.Duration
+	if claims != nil {
+		if expFloat, ok := claims["exp"].(float64); ok {
+			expTime := time.Unix(int64(expFloat), 0)
+			if expTime.After(time.Now()) {
+				expiryDuration = time.Until(expTime)
+			} else {
+				expiryDuration = time.Minute
+			}
+		} else {
+			expiryDuration = s.cfg.JWTAccessTokenExpirationMinutes
+		}
+	} else {
+		expiryDuration = s.cfg.JWTAccessTokenExpirationMinutes
+	}
+
+	err = s.otpService.BlacklistToken(ctx, tokenStr, expiryDuration)
+	if err != nil {
+		log.Printf("Failed to blacklist access token on logout: %v", err)
+		return fmt.Errorf("failed to blacklist token: %w", err)
+	}
+
+	// End the session so its refresh token can no longer be rotated
+	if claims != nil {
+		if familyID, ok := claims["sid"].(string); ok && familyID != "" {
+			if err := s.sessionService.RevokeFamily(ctx, familyID); err != nil {
+				log.Printf("Failed to end session %s on logout: %v", familyID, err)
+			}
+		}
+	}
+
+	if claims != nil {
+		userIDStr, ok := claims["user_id"].(string)
+		if ok {
+			parsedUserID, pErr := strconv.ParseUint(userIDStr, 10, 64)
+			if pErr == nil {
+				uid := uint(parsedUserID)
+				switch PrincipalTypeFromClaims(claims) {
+				case PrincipalTypeUser:
+					_ = s.activityLogService.LogActivity(ctx, nil, &uid, "USER_LOGOUT", fmt.Sprintf("User ID %d logged out.", uid), "")
+				case PrincipalTypeStaff:
+					_ = s.activityLogService.LogActivity(ctx, &uid, nil, "STAFF_LOGOUT", fmt.Sprintf("Staff ID %d logged out.", uid), "")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *authService) Toggle2FA(ctx context.Context, userIDStr string, enable bool) ([]string, error) {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	userID := uint(parsedUserID)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	wasEnabled := user.TwoFAEnabled
+	user.TwoFAEnabled = enable
+	if enable && user.TwoFAMethod == "" {
+		user.TwoFAMethod = models.TwoFAMethodEmail
+	}
+	if !enable {
+		user.EmailOTP = nil
+		user.EmailOTPExp = nil
+		user.TwoFASecret = nil
+		user.TwoFAMethod = models.TwoFAMethodEmail
+	}
+
+	_, err = s.userRepo.Update(ctx, user)
+	if err != nil {
+		log.Printf("Failed to update 2FA status for user %d: %v", userID, err)
+		return nil, fmt.Errorf("could not update 2FA status: %w", err)
+	}
+
+	action := "USER_2FA_DISABLED"
+	if enable {
+		action = "USER_2FA_ENABLED"
+	}
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, action, fmt.Sprintf("User ID %d %s 2FA.", userID, action), "")
+
+	if !enable {
+		if err := s.recoveryCodeRepo.DeleteForUser(ctx, userID); err != nil {
+			log.Printf("Warning: Failed to delete recovery codes for user %d after disabling 2FA: %v", userID, err)
+		}
+		return nil, nil
+	}
+	if wasEnabled {
+		return nil, nil // Already enrolled; existing recovery codes stay valid
+	}
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// BeginTOTPEnrollment generates a new authenticator secret for the user. The secret is held
+// in Redis and only written to the user record once ConfirmTOTPEnrollment succeeds.
+func (s *authService) BeginTOTPEnrollment(ctx context.Context, userIDStr string) (*dtos.TOTPSetupResponse, error) {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	user, err := s.userRepo.FindByID(ctx, uint(parsedUserID))
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	enrollment, err := s.totpService.GenerateEnrollment(user.Email)
+	if err != nil {
+		log.Printf("Failed to generate TOTP enrollment for user %s: %v", user.Email, err)
+		return nil, ErrFailedToInitiate2FA
+	}
+	if err := s.otpService.StorePendingTOTPSecret(ctx, userIDStr, enrollment.Secret, s.cfg.TOTPEnrollmentExpiry); err != nil {
+		return nil, ErrFailedToInitiate2FA
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_2FA_TOTP_ENROLLMENT_STARTED", fmt.Sprintf("User %s started authenticator app enrollment.", user.Email), "")
+
+	return &dtos.TOTPSetupResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURL: enrollment.OTPAuthURL,
+		QRCodePNG:  enrollment.QRCodePNG,
+		ExpiresIn:  int(s.cfg.TOTPEnrollmentExpiry.Seconds()),
+		Message:    "Scan the QR code with your authenticator app and confirm with the first code it shows.",
+	}, nil
+}
+
+// ConfirmTOTPEnrollment verifies the first code from the authenticator app, persists the secret
+// and switches the user's second factor to TOTP.
+func (s *authService) ConfirmTOTPEnrollment(ctx context.Context, userIDStr, code string) ([]string, error) {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	userID := uint(parsedUserID)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	secret, err := s.otpService.GetPendingTOTPSecret(ctx, userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("could not load pending TOTP enrollment: %w", err)
+	}
+	if secret == "" {
+		return nil, ErrTOTPEnrollmentExpired
+	}
+	valid, err := s.checkTOTPCode(ctx, principalSubject(PrincipalTypeUser, userID), code, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_2FA_TOTP_ENROLLMENT_FAILED", fmt.Sprintf("User %s entered an invalid code while confirming authenticator enrollment.", user.Email), "")
+		return nil, ErrOTPInvalidOrExpired
+	}
+
+	wasEnabled := user.TwoFAEnabled
+	user.TwoFASecret = &secret
+	user.TwoFAMethod = models.TwoFAMethodTOTP
+	user.TwoFAEnabled = true
+	if _, err := s.userRepo.Update(ctx, user); err != nil {
+		log.Printf("Failed to persist TOTP secret for user %d: %v", userID, err)
+		return nil, fmt.Errorf("could not save authenticator enrollment: %w", err)
+	}
+
+	if err := s.otpService.DeletePendingTOTPSecret(ctx, userIDStr); err != nil {
+		log.Printf("Warning: Failed to delete pending TOTP secret for user %s after confirmation: %v", userIDStr, err)
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_2FA_TOTP_ENABLED", fmt.Sprintf("User ID %d enabled authenticator app 2FA.", userID), "")
+
+	if wasEnabled {
+		return nil, nil // Switching from email OTP; existing recovery codes stay valid
+	}
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// Set2FAMethod switches an already enrolled user between email OTP and authenticator codes.
+func (s *authService) Set2FAMethod(ctx context.Context, userIDStr, method string) error {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	userID := uint(parsedUserID)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	if !user.TwoFAEnabled {
+		return Err2FANotEnabled
+	}
+	if method == models.TwoFAMethodTOTP && (user.TwoFASecret == nil || *user.TwoFASecret == "") {
+		return ErrTOTPNotEnrolled
+	}
+
+	user.TwoFAMethod = method
+	if _, err := s.userRepo.Update(ctx, user); err != nil {
+		log.Printf("Failed to update 2FA method for user %d: %v", userID, err)
+		return fmt.Errorf("could not update 2FA method: %w", err)
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_2FA_METHOD_CHANGED", map[string]interface{}{"method": method}, "")
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates all of the user's recovery codes and returns a fresh set.
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userIDStr string) ([]string, error) {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	userID := uint(parsedUserID)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.TwoFAEnabled {
+		return nil, Err2FANotEnabled
+	}
+
+	codes, err := s.issueRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_2FA_RECOVERY_REGENERATED", fmt.Sprintf("User ID %d regenerated 2FA recovery codes.", userID), "")
+	return codes, nil
+}
+
+// issueRecoveryCodes generates a new set of recovery codes, replacing any existing ones.
+// The plaintext codes are returned once and never stored.
+func (s *authService) issueRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	if err := s.recoveryCodeRepo.ReplaceForUser(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("could not store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	buffer := make([]byte, recoveryCodeHalfLen*2)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code bytes: %w", err)
+	}
+	for i := range buffer {
+		buffer[i] = recoveryCodeAlphabet[int(buffer[i])%len(recoveryCodeAlphabet)]
+	}
+	return string(buffer[:recoveryCodeHalfLen]) + "-" + string(buffer[recoveryCodeHalfLen:]), nil
+}
+
+// hashRecoveryCode normalises user input (case, spaces, dashes) before hashing so that
+// "ABCDE-FGHJK", "abcde fghjk" and "abcdefghjk" all match the same stored code.
+func hashRecoveryCode(code string) string {
+	normalised := strings.ToLower(code)
+	normalised = strings.NewReplacer("-", "", " ", "").Replace(normalised)
+	sum := sha256.Sum256([]byte(normalised))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestPasswordReset emails a single-use reset link to the user or staff account with the
+// given email. It never reveals whether the email is registered.
+func (s *authService) RequestPasswordReset(ctx context.Context, email string) error {
+	var subject, firstName string
+	if user, err := s.userRepo.FindByEmail(ctx, email); err == nil && user != nil {
+		subject, firstName = principalSubject(PrincipalTypeUser, user.ID), user.FirstName
+		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_PASSWORD_RESET_REQUESTED", fmt.Sprintf("User %s requested a password reset.", user.Email), "")
+	} else if staff, err := s.staffRepo.FindByEmail(ctx, email); err == nil && staff != nil {
+		subject, firstName = principalSubject(PrincipalTypeStaff, staff.ID), staff.FirstName
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_PASSWORD_RESET_REQUESTED", fmt.Sprintf("Staff %s requested a password reset.", staff.Email), "")
+	} else {
+		log.Printf("Password reset requested for unknown email %s", email)
+		return nil
+	}
+
+	token, err := s.otpService.IssueActionToken(ctx, ActionTokenPasswordReset, subject, s.cfg.PasswordResetExpiry)
+	if err != nil {
+		return fmt.Errorf("could not create password reset token: %w", err)
+	}
+
+	go func() {
+		resetLink := fmt.Sprintf("%s/reset-password?token=%s", s.cfg.FrontendBaseURL, token)
+		mailSubject := "Reset your password"
+		body := fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Use the link below to choose a new one:\n%s\n\nThe link expires in %d minutes and can only be used once. If you didn't request this, you can ignore this email.\n\nThanks,\nThe Team", firstName, resetLink, int(s.cfg.PasswordResetExpiry.Minutes()))
+		if emailErr := s.emailService.SendEmail(email, mailSubject, body); emailErr != nil {
+			log.Printf("Failed to send password reset email to %s: %v", email, emailErr)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword redeems a reset token, sets the new password and revokes every refresh
+// token issued to the account before now.
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	// Check the policy first so that a rejected password does not burn the single-use link
+	if err := ValidatePasswordPolicy(newPassword); err != nil {
+		return err
+	}
+	subject, err := s.otpService.ConsumeActionToken(ctx, ActionTokenPasswordReset, token)
+	if err != nil {
+		return fmt.Errorf("could not verify password reset token: %w", err)
+	}
+	principalType, id, ok := parsePrincipalSubject(subject)
+	if !ok {
+		return ErrResetTokenInvalid
+	}
+
+	hashedPassword, err := models.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("could not hash password: %w", err)
+	}
+
+	var email, firstName string
+	switch principalType {
+	case PrincipalTypeUser:
+		user, err := s.userRepo.FindByID(ctx, id)
+		if err != nil || user == nil {
+			return ErrResetTokenInvalid
+		}
+		user.PasswordHash = hashedPassword
+		if _, err := s.userRepo.Update(ctx, user); err != nil {
+			log.Printf("Failed to update password for user %d: %v", id, err)
+			return fmt.Errorf("could not update password: %w", err)
+		}
+		email, firstName = user.Email, user.FirstName
+		_ = s.activityLogService.LogActivity(ctx, nil, &user.ID, "USER_PASSWORD_RESET", fmt.Sprintf("User %s reset their password.", user.Email), "")
+	case PrincipalTypeStaff:
+		staff, err := s.staffRepo.FindByID(ctx, id)
+		if err != nil || staff == nil {
+			return ErrResetTokenInvalid
+		}
+		staff.PasswordHash = hashedPassword
+		if err := s.staffRepo.Update(ctx, staff); err != nil {
+			log.Printf("Failed to update password for staff %d: %v", id, err)
+			return fmt.Errorf("could not update password: %w", err)
+		}
+		email, firstName = staff.Email, staff.FirstName
+		_ = s.activityLogService.LogActivity(ctx, &staff.ID, nil, "STAFF_PASSWORD_RESET", fmt.Sprintf("Staff %s reset their password.", staff.Email), "")
+	}
+
+	s.revokeIssuedTokens(ctx, subject)
+
+	go func() {
+		mailSubject := "Your password was changed"
+		body := fmt.Sprintf("Hi %s,\n\nThe password for your account was just reset and all other sessions have been signed out.\nIf you didn't do this, contact support immediately.\n\nThanks,\nThe Team", firstName)
+		if emailErr := s.emailService.SendEmail(email, mailSubject, body); emailErr != nil {
+			log.Printf("Failed to send password reset confirmation email to %s: %v", email, emailErr)
+		}
+	}()
+
+	return nil
+}
+
+// VerifyEmail redeems an email verification token and marks the user's email as verified.
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	subject, err := s.otpService.ConsumeActionToken(ctx, ActionTokenEmailVerification, token)
+	if err != nil {
+		return fmt.Errorf("could not verify email token: %w", err)
+	}
+	principalType, userID, ok := parsePrincipalSubject(subject)
+	if !ok || principalType != PrincipalTypeUser {
+		return ErrVerificationInvalid
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrVerificationInvalid
+	}
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if _, err := s.userRepo.Update(ctx, user); err != nil {
+		log.Printf("Failed to mark email verified for user %d: %v", userID, err)
+		return fmt.Errorf("could not verify email: %w", err)
+	}
+
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_EMAIL_VERIFIED", fmt.Sprintf("User %s verified their email address.", user.Email), "")
+	return nil
+}
+
+// ResendVerificationEmail issues a fresh verification link, at most once per cooldown window.
+func (s *authService) ResendVerificationEmail(ctx context.Context, userIDStr string) error {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid user ID format: %w", err)
+	}
+	userID := uint(parsedUserID)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	if user.IsEmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+
+	allowed, err := s.otpService.AcquireThrottle(ctx, ActionTokenEmailVerification, principalSubject(PrincipalTypeUser, userID), s.cfg.EmailVerificationResendCooldown)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrVerificationThrottled
+	}
+
+	verificationLink, err := s.newEmailVerificationLink(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		subject := "Confirm your email address"
+		body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address using the link below:\n%s\n\nThe link expires in %d hours.\n\nThanks,\nThe Team", user.FirstName, verificationLink, int(s.cfg.EmailVerificationExpiry.Hours()))
+		if emailErr := s.emailService.SendEmail(user.Email, subject, body); emailErr != nil {
+			log.Printf("Failed to send verification email to %s: %v", user.Email, emailErr)
+		}
+	}()
+
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_EMAIL_VERIFICATION_RESENT", fmt.Sprintf("Verification email resent to %s.", user.Email), "")
+	return nil
+}
+
+func (s *authService) newEmailVerificationLink(ctx context.Context, userID uint) (string, error) {
+	token, err := s.otpService.IssueActionToken(ctx, ActionTokenEmailVerification, principalSubject(PrincipalTypeUser, userID), s.cfg.EmailVerificationExpiry)
+	if err != nil {
+		return "", fmt.Errorf("could not create email verification token: %w", err)
+	}
+	return fmt.Sprintf("%s/verify-email?token=%s", s.cfg.FrontendBaseURL, token), nil
+}
+
+// ChangeUserPassword updates a logged-in user's password after checking the current one,
+// revokes every token issued before now and returns a fresh token pair for the caller.
+func (s *authService) ChangeUserPassword(ctx context.Context, userIDStr, currentPassword, newPassword string, client ClientInfo) (*dtos.ChangePasswordResponse, error) {
+	parsedUserID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	userID := uint(parsedUserID)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	hashedPassword, err := s.checkPasswordChange(user.PasswordHash, currentPassword, newPassword)
+	if err != nil {
+		if errors.Is(err, ErrCurrentPasswordWrong) {
+			_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_PASSWORD_CHANGE_FAILED", fmt.Sprintf("User %s entered an incorrect current password.", user.Email), "")
+		}
+		return nil, err
+	}
+
+	user.PasswordHash = hashedPassword
+	if _, err := s.userRepo.Update(ctx, user); err != nil {
+		log.Printf("Failed to update password for user %d: %v", userID, err)
+		return nil, fmt.Errorf("could not update password: %w", err)
+	}
+
+	s.revokeIssuedTokens(ctx, principalSubject(PrincipalTypeUser, userID))
+	_ = s.activityLogService.LogActivity(ctx, nil, &userID, "USER_PASSWORD_CHANGED", fmt.Sprintf("User %s changed their password.", user.Email), "")
+	s.sendPasswordChangedEmail(user.Email, user.FirstName)
+
+	tokens, err := s.startUserSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.ChangePasswordResponse{
+		Message:              "Password changed successfully. All other sessions have been signed out.",
+		AccessToken:          tokens.AccessToken,
+		RefreshToken:         tokens.RefreshToken,
+		AccessTokenExpiresAt: tokens.AccessExp.Unix(),
+	}, nil
+}
+
+// ChangeStaffPassword is the staff counterpart of ChangeUserPassword.
+func (s *authService) ChangeStaffPassword(ctx context.Context, staffID uint, currentPassword, newPassword string, client ClientInfo) (*dtos.ChangePasswordResponse, error) {
+	staff, err := s.staffRepo.FindByID(ctx, staffID)
+	if err != nil || staff == nil {
+		return nil, ErrUserNotFound
+	}
+
+	hashedPassword, err := s.checkPasswordChange(staff.PasswordHash, currentPassword, newPassword)
+	if err != nil {
+		if errors.Is(err, ErrCurrentPasswordWrong) {
+			_ = s.activityLogService.LogActivity(ctx, &staffID, nil, "STAFF_PASSWORD_CHANGE_FAILED", fmt.Sprintf("Staff %s entered an incorrect current password.", staff.Email), "")
+		}
+		return nil, err
+	}
+
+	staff.PasswordHash = hashedPassword
+	if err := s.staffRepo.Update(ctx, staff); err != nil {
+		log.Printf("Failed to update password for staff %d: %v", staffID, err)
+		return nil, fmt.Errorf("could not update password: %w", err)
+	}
+
+	s.revokeIssuedTokens(ctx, principalSubject(PrincipalTypeStaff, staffID))
+	_ = s.activityLogService.LogActivity(ctx, &staffID, nil, "STAFF_PASSWORD_CHANGED", fmt.Sprintf("Staff %s changed their password.", staff.Email), "")
+	s.sendPasswordChangedEmail(staff.Email, staff.FirstName)
+
+	// Staff with 2FA enabled can only have reached this point through a verified login
+	tokens, err := s.startStaffSession(ctx, staff, client, staff.TwoFAEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.ChangePasswordResponse{
+		Message:              "Password changed successfully. All other sessions have been signed out.",
+		AccessToken:          tokens.AccessToken,
+		RefreshToken:         tokens.RefreshToken,
+		AccessTokenExpiresAt: tokens.AccessExp.Unix(),
+	}, nil
+}
+
+// checkPasswordChange verifies the current password and policy and returns the new bcrypt hash.
+func (s *authService) checkPasswordChange(currentHash, currentPassword, newPassword string) (string, error) {
+	if !models.CheckPasswordHash(currentPassword, currentHash) {
+		return "", ErrCurrentPasswordWrong
+	}
+	if currentPassword == newPassword {
+		return "", ErrPasswordUnchanged
+	}
+	if err := ValidatePasswordPolicy(newPassword); err != nil {
+		return "", err
+	}
+	hashedPassword, err := models.HashPassword(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("could not hash password: %w", err)
+	}
+	return hashedPassword, nil
+}
+
+// revokeIssuedTokens invalidates every token issued to the subject so far. Tokens issued
+// afterwards (e.g. the fresh pair returned to the caller) are unaffected: the cut-off covers
+// its whole second, so this waits for the next one before returning.
+func (s *authService) revokeIssuedTokens(ctx context.Context, subject string) {
+	cutoff := time.Now()
+	if err := s.otpService.RevokeTokensIssuedBefore(ctx, subject, cutoff, s.cfg.JWTRefreshTokenExpirationDays); err != nil {
+		log.Printf("Warning: Failed to revoke outstanding tokens for %s after password update: %v", subject, err)
+	}
+	if err := s.sessionService.RevokeAllForSubject(ctx, subject); err != nil {
+		log.Printf("Warning: Failed to end sessions for %s after password update: %v", subject, err)
+	}
+	time.Sleep(time.Until(cutoff.Truncate(time.Second).Add(time.Second)))
+}
+
+func (s *authService) sendPasswordChangedEmail(email, firstName string) {
+	go func() {
+		subject := "Your password was changed"
+		body := fmt.Sprintf("Hi %s,\n\nThe password for your account was just changed and all other sessions have been signed out.\nIf you didn't do this, reset your password and contact support immediately.\n\nThanks,\nThe Team", firstName)
+		if emailErr := s.emailService.SendEmail(email, subject, body); emailErr != nil {
+			log.Printf("Failed to send password change email to %s: %v", email, emailErr)
+		}
+	}()
+}
+
+// principalSubject builds the "<type>:<id>" key used to scope Redis state to a user or staff account.
+func principalSubject(principalType string, id uint) string {
+	return fmt.Sprintf("%s:%d", principalType, id)
+}
+
+// impersonationSubject scopes impersonation sessions to one staff member and customer, so they
+// are neither listed nor revocable as sessions of either account.
+func impersonationSubject(staffID, userID uint) string {
+	return fmt.Sprintf("impersonation:%d:%d", staffID, userID)
+}
+
+func parsePrincipalSubject(subject string) (string, uint, bool) {
+	principalType, idStr, found := strings.Cut(subject, ":")
+	if !found || (principalType != PrincipalTypeUser && principalType != PrincipalTypeStaff) {
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return principalType, uint(id), true
 }
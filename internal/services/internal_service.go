@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"invoiceB2B/internal/dtos"
 	"invoiceB2B/internal/models"
@@ -32,18 +33,21 @@ func (s *internalService) UpdateInvoiceWithProcessedData(ctx context.Context, in
 		log.Printf("Error finding invoice %d for processed data update: %v", invoiceID, err)
 		return nil, ErrInvoiceNotFound // Assuming ErrInvoiceNotFound is defined
 	}
+	if !invoice.Status.AcceptsProcessedData() {
+		return nil, fmt.Errorf("%w: invoice %d is %s", ErrInvalidInvoiceStatusForOperation, invoiceID, invoice.Status)
+	}
 
 	// Update fields from request
 	invoice.JSONData = req.JSONData
 	if req.ExtractedInvoiceNumber != nil {
 		invoice.InvoiceNumber = *req.ExtractedInvoiceNumber
 	}
-	if req.ExtractedAmount != nil {
-		invoice.Amount = *req.ExtractedAmount
-	}
 	if req.ExtractedCurrency != nil {
 		invoice.Currency = *req.ExtractedCurrency
 	}
+	if req.ExtractedAmount != nil {
+		invoice.Amount = req.ExtractedAmount.Round(invoice.Currency)
+	}
 	if req.ExtractedDebtorName != nil {
 		invoice.DebtorName = *req.ExtractedDebtorName
 	}
@@ -66,19 +70,29 @@ func (s *internalService) UpdateInvoiceWithProcessedData(ctx context.Context, in
 		}
 	}
 
+	newStatus := invoice.Status
 	if req.ProcessingError != nil {
 		invoice.ProcessingError = req.ProcessingError
-		invoice.Status = models.InvoiceProcessingFailed // Or a similar status
+		newStatus = models.InvoiceProcessingFailed
 	} else if req.NewStatus != nil {
-		invoice.Status = *req.NewStatus // n8n can suggest a status like "PendingApproval"
+		newStatus = *req.NewStatus // n8n can suggest a status like "PendingApproval"
 	} else {
 		// Default status after successful processing if not specified by n8n
 		if invoice.Status == models.InvoicePendingReview { // Only if it was pending review
-			invoice.Status = models.InvoicePendingApproval
+			newStatus = models.InvoicePendingApproval
 		}
 	}
 
-	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
+	if newStatus != invoice.Status {
+		entry := models.InvoiceStatusHistory{ActorType: models.InvoiceActorSystem, Reason: req.ProcessingError}
+		if err := s.invoiceRepo.Transition(ctx, invoice, newStatus, entry); err != nil {
+			if errors.Is(err, models.ErrInvalidInvoiceTransition) {
+				return nil, err
+			}
+			log.Printf("Error updating invoice %d with processed data: %v", invoiceID, err)
+			return nil, fmt.Errorf("failed to update invoice: %w", err)
+		}
+	} else if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
 		log.Printf("Error updating invoice %d with processed data: %v", invoiceID, err)
 		return nil, fmt.Errorf("failed to update invoice: %w", err)
 	}
@@ -7,6 +7,7 @@ import (
 	"invoiceB2B/internal/config"
 	"invoiceB2B/internal/dtos"
 	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
 	"invoiceB2B/internal/repositories"
 	"log"
 	"path/filepath"
@@ -23,6 +24,11 @@ var (
 	ErrInvalidInvoiceStatusForOperation  = errors.New("invalid invoice status for this operation")
 	ErrInvoiceNotDisbursedForRepayment   = errors.New("invoice has not been disbursed, cannot process repayment")
 	ErrRepaymentAmountMismatch           = errors.New("repayment amount does not match financed amount")
+	ErrInvoiceUploadNotAllowed           = errors.New("your organization role does not allow uploading invoices")
+	ErrInvoiceNotQuotable                = errors.New("invoice is no longer awaiting financing")
+	ErrRepaymentNotAllowed               = errors.New("your organization role does not allow making repayments")
+	ErrRepaymentDeclined                 = errors.New("repayment was declined")
+	ErrRepaymentAmountTooSmall           = errors.New("repayment amount is less than the smallest unit of the invoice currency")
 )
 
 type InvoiceService interface {
@@ -30,12 +36,20 @@ type InvoiceService interface {
 	GetUserInvoices(ctx context.Context, userID uint, page, pageSize int) ([]dtos.InvoiceResponse, int64, error)
 	GetInvoiceByIDForUser(ctx context.Context, invoiceID, userID uint) (*dtos.InvoiceResponse, error)
 	GetReceiptPathForUser(ctx context.Context, invoiceID, userID uint) (string, string, error)
+	GetInvoiceHistoryForUser(ctx context.Context, invoiceID, userID uint) ([]dtos.InvoiceStatusHistoryResponse, error)
+	QuoteFinancing(ctx context.Context, userID uint, req dtos.FinancingQuoteRequest) (*dtos.FinancingQuoteResponse, error)
+	GetInvoiceQuoteForUser(ctx context.Context, invoiceID, userID uint, persist bool) (*dtos.FinancingQuoteResponse, error)
+	RepayInvoice(ctx context.Context, invoiceID, userID uint, req dtos.InvoiceRepaymentRequest) (*dtos.InvoiceRepaymentResponse, error)
 }
 
 type invoiceService struct {
 	invoiceRepo     repositories.InvoiceRepository
 	userRepo        repositories.UserRepository
 	transactionRepo repositories.TransactionRepository
+	kycRepo         repositories.KYCRepository
+	orgService      OrganizationService
+	pricingSvc      PricingService
+	paymentSvc      PaymentService
 	fileService     FileService
 	notificationSvc NotificationService
 	activityLogSvc  ActivityLogService
@@ -47,6 +61,10 @@ func NewInvoiceService(
 	invoiceRepo repositories.InvoiceRepository,
 	userRepo repositories.UserRepository,
 	transactionRepo repositories.TransactionRepository,
+	kycRepo repositories.KYCRepository,
+	orgService OrganizationService,
+	pricingSvc PricingService,
+	paymentSvc PaymentService,
 	fileService FileService,
 	notificationSvc NotificationService,
 	activityLogSvc ActivityLogService,
@@ -57,6 +75,10 @@ func NewInvoiceService(
 		invoiceRepo:     invoiceRepo,
 		userRepo:        userRepo,
 		transactionRepo: transactionRepo,
+		kycRepo:         kycRepo,
+		orgService:      orgService,
+		pricingSvc:      pricingSvc,
+		paymentSvc:      paymentSvc,
 		fileService:     fileService,
 		notificationSvc: notificationSvc,
 		activityLogSvc:  activityLogSvc,
@@ -65,6 +87,15 @@ func NewInvoiceService(
 	}
 }
 
+// roundedAmount rounds an optional amount to the minor unit of currency for display.
+func roundedAmount(amount *money.Amount, currency string) *money.Amount {
+	if amount == nil {
+		return nil
+	}
+	rounded := amount.Round(currency)
+	return &rounded
+}
+
 // mapInvoiceToResponse helper function
 func mapInvoiceToResponse(invoice *models.Invoice) dtos.InvoiceResponse {
 	var receiptPath string
@@ -74,12 +105,13 @@ func mapInvoiceToResponse(invoice *models.Invoice) dtos.InvoiceResponse {
 	return dtos.InvoiceResponse{
 		ID:                      invoice.ID,
 		UserID:                  invoice.UserID,
+		OrganizationID:          invoice.OrganizationID,
 		InvoiceNumber:           invoice.InvoiceNumber,
 		IssuerName:              invoice.IssuerName,
 		IssuerBankAccount:       invoice.IssuerBankAccount,
 		IssuerBankName:          invoice.IssuerBankName,
 		DebtorName:              invoice.DebtorName,
-		Amount:                  invoice.Amount,
+		Amount:                  invoice.Amount.Round(invoice.Currency),
 		Currency:                invoice.Currency,
 		DueDate:                 invoice.DueDate,
 		Status:                  invoice.Status,
@@ -89,7 +121,8 @@ func mapInvoiceToResponse(invoice *models.Invoice) dtos.InvoiceResponse {
 		ApprovedAt:              invoice.ApprovedAt,
 		DisbursedAt:             invoice.DisbursedAt,
 		FinancingFeePercentage:  invoice.FinancingFeePercentage,
-		FinancedAmount:          invoice.FinancedAmount,
+		FinancedAmount:          roundedAmount(invoice.FinancedAmount, invoice.Currency),
+		Pricing:                 mapPricingBreakdown(invoice.Pricing, invoice.Currency),
 		DisbursementReceiptPath: receiptPath,
 		CreatedAt:               invoice.CreatedAt,
 		UpdatedAt:               invoice.UpdatedAt,
@@ -97,32 +130,26 @@ func mapInvoiceToResponse(invoice *models.Invoice) dtos.InvoiceResponse {
 }
 
 func (s *invoiceService) CreateInvoice(ctx context.Context, userID uint, req dtos.InvoiceUploadRequest) (*dtos.InvoiceResponse, error) {
-	user, err := s.userRepo.FindByIDWithKYC(ctx, userID)
+	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
+	if !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
 
-	// --- START DEBUG LOGS --- (Can be removed after confirming fix)
-	log.Printf("CreateInvoice DEBUG: For UserID %d:", userID)
-	if user.KYCDetail == nil {
-		log.Printf("CreateInvoice DEBUG: user.KYCDetail IS NIL.")
-	} else {
-		log.Printf("CreateInvoice DEBUG: user.KYCDetail.ID is '%d', user.KYCDetail.Status is '%s'. models.KYCApproved is '%s'.", user.KYCDetail.ID, user.KYCDetail.Status, models.KYCApproved)
-		log.Printf("CreateInvoice DEBUG: Is user.KYCDetail.Status == models.KYCApproved? %t", user.KYCDetail.Status == models.KYCApproved)
-	}
-	// --- END DEBUG LOGS ---
-
-	if user.KYCDetail == nil || user.KYCDetail.Status != models.KYCApproved {
-		log.Printf("CreateInvoice INFO: KYC check failed for UserID %d. KYCDetail present: %t. Status from DB: '%s'. Expected status: '%s'.",
-			userID,
-			user.KYCDetail != nil,
-			func() string {
-				if user.KYCDetail != nil {
-					return string(user.KYCDetail.Status)
-				}
-				return "N/A"
-			}(),
-			models.KYCApproved)
+	member, err := s.orgService.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !models.CanUploadInvoices(member.Role) {
+		return nil, ErrInvoiceUploadNotAllowed
+	}
+
+	// KYC is held by the organisation, not by the individual member
+	kycDetail, err := s.kycRepo.FindByOrganizationID(ctx, member.OrganizationID)
+	if err != nil || kycDetail.Status != models.KYCApproved {
+		log.Printf("CreateInvoice INFO: KYC check failed for UserID %d in organization %d.", userID, member.OrganizationID)
 		return nil, ErrKYCNotApprovedForInvoiceUpload
 	}
 
@@ -137,6 +164,7 @@ func (s *invoiceService) CreateInvoice(ctx context.Context, userID uint, req dto
 	now := time.Now()
 	invoice := &models.Invoice{
 		UserID:           userID,
+		OrganizationID:   &member.OrganizationID,
 		Status:           models.InvoicePendingReview, // Ensure models.InvoicePendingReview is defined
 		OriginalFilePath: relativePath,
 		UploadedAt:       now,
@@ -171,7 +199,8 @@ func (s *invoiceService) CreateInvoice(ctx context.Context, userID uint, req dto
 		"invoice_id":        invoice.ID,
 		"user_id":           userID,
 		"user_email":        user.Email,
-		"company_name":      user.CompanyName,
+		"organization_id":   member.OrganizationID,
+		"company_name":      member.Organization.Name,
 		"file_path":         relativePath,
 		"original_filename": originalFileName, // Use the filename obtained from SaveFile
 		"uploaded_at":       invoice.UploadedAt.Format(time.RFC3339),
@@ -202,10 +231,15 @@ func (s *invoiceService) CreateInvoice(ctx context.Context, userID uint, req dto
 	return &resp, nil
 }
 
+// GetUserInvoices lists the invoices of the user's organisation, whichever member uploaded them.
 func (s *invoiceService) GetUserInvoices(ctx context.Context, userID uint, page, pageSize int) ([]dtos.InvoiceResponse, int64, error) {
-	invoices, total, err := s.invoiceRepo.FindByUserID(ctx, userID, page, pageSize)
+	member, err := s.orgService.GetMembership(ctx, userID)
 	if err != nil {
-		log.Printf("Error fetching invoices for user %d: %v", userID, err)
+		return nil, 0, err
+	}
+	invoices, total, err := s.invoiceRepo.FindByOrganizationID(ctx, member.OrganizationID, page, pageSize)
+	if err != nil {
+		log.Printf("Error fetching invoices for organization %d: %v", member.OrganizationID, err)
 		return nil, 0, fmt.Errorf("could not retrieve invoices: %w", err)
 	}
 
@@ -217,24 +251,201 @@ func (s *invoiceService) GetUserInvoices(ctx context.Context, userID uint, page,
 }
 
 func (s *invoiceService) GetInvoiceByIDForUser(ctx context.Context, invoiceID, userID uint) (*dtos.InvoiceResponse, error) {
+	invoice, err := s.findInvoiceForMember(ctx, invoiceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := mapInvoiceToResponse(invoice)
+	return &resp, nil
+}
+
+// findInvoiceForMember loads an invoice if it belongs to the user's organisation.
+func (s *invoiceService) findInvoiceForMember(ctx context.Context, invoiceID, userID uint) (*models.Invoice, error) {
+	member, err := s.orgService.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 	invoice, err := s.invoiceRepo.FindByID(ctx, invoiceID)
 	if err != nil {
 		return nil, ErrInvoiceNotFound
 	}
-	if invoice.UserID != userID {
+	if invoice.OrganizationID == nil || *invoice.OrganizationID != member.OrganizationID {
 		return nil, ErrInvoiceAccessDenied
 	}
-	resp := mapInvoiceToResponse(invoice)
-	return &resp, nil
+	return invoice, nil
 }
 
-func (s *invoiceService) GetReceiptPathForUser(ctx context.Context, invoiceID, userID uint) (string, string, error) {
+func (s *invoiceService) GetInvoiceHistoryForUser(ctx context.Context, invoiceID, userID uint) ([]dtos.InvoiceStatusHistoryResponse, error) {
+	if _, err := s.findInvoiceForMember(ctx, invoiceID, userID); err != nil {
+		return nil, err
+	}
+	history, err := s.invoiceRepo.ListStatusHistory(ctx, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve invoice history: %w", err)
+	}
+	return mapInvoiceStatusHistory(history), nil
+}
+
+// QuoteFinancing estimates the financing of an invoice that has not been uploaded yet, priced
+// at the caller's organisation's risk tier.
+func (s *invoiceService) QuoteFinancing(ctx context.Context, userID uint, req dtos.FinancingQuoteRequest) (*dtos.FinancingQuoteResponse, error) {
+	member, err := s.orgService.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	dueDate, err := time.Parse("2006-01-02", req.DueDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due date: %w", err)
+	}
+
+	quote, err := s.pricingSvc.Quote(ctx, userID, PricingInput{
+		OrganizationID: &member.OrganizationID,
+		DebtorName:     req.DebtorName,
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		DueDate:        &dueDate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapFinancingQuote(quote), nil
+}
+
+// GetInvoiceQuoteForUser quotes an uploaded invoice from its extracted details. It fails with
+// ErrPricingInputIncomplete until extraction has filled in the amount, currency and due date.
+// Read-only callers such as impersonating staff pass persist=false so that no quote is saved
+// in the customer's name.
+func (s *invoiceService) GetInvoiceQuoteForUser(ctx context.Context, invoiceID, userID uint, persist bool) (*dtos.FinancingQuoteResponse, error) {
+	invoice, err := s.findInvoiceForMember(ctx, invoiceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch invoice.Status {
+	case models.InvoicePendingReview, models.InvoiceProcessingFailed, models.InvoicePendingApproval, models.InvoiceApproved:
+	default:
+		return nil, ErrInvoiceNotQuotable
+	}
+
+	quote, err := s.pricingSvc.QuoteInvoice(ctx, userID, invoice, persist)
+	if err != nil {
+		return nil, err
+	}
+	return mapFinancingQuote(quote), nil
+}
+
+// RepayInvoice charges a repayment through the payment provider and records it against the
+// invoice. The repayment is reserved as a pending transaction before the charge, which also
+// checks it against the outstanding balance, and the provider is given that transaction's ID
+// as idempotency key. The invoice moves to repayment_pending after a partial repayment and to
+// repaid once the advance has been paid back in full.
+func (s *invoiceService) RepayInvoice(ctx context.Context, invoiceID, userID uint, req dtos.InvoiceRepaymentRequest) (*dtos.InvoiceRepaymentResponse, error) {
+	member, err := s.orgService.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !models.CanUploadInvoices(member.Role) {
+		return nil, ErrRepaymentNotAllowed
+	}
 	invoice, err := s.invoiceRepo.FindByID(ctx, invoiceID)
 	if err != nil {
-		return "", "", ErrInvoiceNotFound
+		return nil, ErrInvoiceNotFound
+	}
+	if invoice.OrganizationID == nil || *invoice.OrganizationID != member.OrganizationID {
+		return nil, ErrInvoiceAccessDenied
+	}
+	if invoice.Status != models.InvoiceDisbursed && invoice.Status != models.InvoiceRepaymentPending {
+		return nil, ErrInvoiceNotDisbursedForRepayment
+	}
+	amount := req.Amount.Round(invoice.Currency)
+	if !amount.IsPositive() {
+		return nil, ErrRepaymentAmountTooSmall
+	}
+
+	now := time.Now()
+	repayment := &models.Transaction{InvoiceID: invoice.ID, Amount: amount, TransactionDate: now}
+	if err := s.transactionRepo.CreatePendingRepayment(ctx, repayment); err != nil {
+		if errors.Is(err, models.ErrRepaymentExceedsBalance) || errors.Is(err, models.ErrInvalidInvoiceTransition) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("could not reserve repayment: %w", err)
 	}
-	if invoice.UserID != userID {
-		return "", "", ErrInvoiceAccessDenied
+
+	payment, err := s.paymentSvc.ProcessRepayment(ctx, dtos.RepaymentRequest{
+		InvoiceID:          invoice.ID,
+		UserID:             userID,
+		Amount:             amount,
+		Currency:           invoice.Currency,
+		PaymentMethodToken: req.PaymentMethodToken,
+		IdempotencyKey:     fmt.Sprintf("repayment-%d", repayment.ID),
+	})
+	if err != nil || payment.Status != "SUCCESS" {
+		if voidErr := s.transactionRepo.VoidRepayment(ctx, repayment); voidErr != nil {
+			log.Printf("Failed to void repayment %d on invoice %d after a failed payment: %v", repayment.ID, invoice.ID, voidErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("repayment could not be processed: %w", err)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrRepaymentDeclined, payment.Message)
+	}
+
+	entry := models.InvoiceStatusHistory{ActorType: models.InvoiceActorUser, ActorID: &userID}
+	status, outstanding, err := s.transactionRepo.CompleteRepayment(ctx, repayment, payment.TransactionID, entry)
+	if err != nil {
+		// The pending transaction keeps the amount reserved; it is reconciled using the reference
+		log.Printf("CRITICAL: repayment %d (reference %s) of %s %s on invoice %d was charged but could not be completed: %v",
+			repayment.ID, payment.TransactionID, amount.Format(invoice.Currency), invoice.Currency, invoice.ID, err)
+		return nil, fmt.Errorf("could not record repayment: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "INVOICE_REPAYMENT", map[string]interface{}{
+		"invoice_id":          invoice.ID,
+		"transaction_id":      repayment.ID,
+		"reference_id":        payment.TransactionID,
+		"amount":              amount,
+		"currency":            invoice.Currency,
+		"outstanding_balance": outstanding,
+		"invoice_status":      status,
+	}, "")
+	if status != invoice.Status && s.notificationSvc != nil {
+		_ = s.notificationSvc.PublishEvent(s.cfg.RabbitMQEventExchangeName, s.cfg.RabbitMQInvoiceStatusUpdatedRoutingKey, map[string]interface{}{
+			"user_id":    invoice.UserID,
+			"invoice_id": invoice.ID,
+			"status":     status,
+		})
+	}
+
+	return &dtos.InvoiceRepaymentResponse{
+		TransactionID:      repayment.ID,
+		ReferenceID:        payment.TransactionID,
+		InvoiceID:          invoice.ID,
+		Amount:             amount,
+		Currency:           invoice.Currency,
+		InvoiceStatus:      status,
+		OutstandingBalance: outstanding.Round(invoice.Currency),
+		PaidAt:             now,
+	}, nil
+}
+
+func mapInvoiceStatusHistory(history []models.InvoiceStatusHistory) []dtos.InvoiceStatusHistoryResponse {
+	responses := make([]dtos.InvoiceStatusHistoryResponse, 0, len(history))
+	for _, entry := range history {
+		responses = append(responses, dtos.InvoiceStatusHistoryResponse{
+			ID:         entry.ID,
+			FromStatus: entry.FromStatus,
+			ToStatus:   entry.ToStatus,
+			ActorType:  entry.ActorType,
+			ActorID:    entry.ActorID,
+			Reason:     entry.Reason,
+			CreatedAt:  entry.CreatedAt,
+		})
+	}
+	return responses
+}
+
+func (s *invoiceService) GetReceiptPathForUser(ctx context.Context, invoiceID, userID uint) (string, string, error) {
+	invoice, err := s.findInvoiceForMember(ctx, invoiceID, userID)
+	if err != nil {
+		return "", "", err
 	}
 	if invoice.DisbursementReceiptPath == nil || *invoice.DisbursementReceiptPath == "" {
 		return "", "", ErrReceiptNotFound
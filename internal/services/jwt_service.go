@@ -11,35 +11,66 @@ import (
 )
 
 type JWTService interface {
-	GenerateAccessToken(user *models.User) (string, time.Time, error)
-	GenerateRefreshToken(user *models.User) (string, time.Time, error)
-	GenerateAccessTokenForStaff(staff *models.Staff) (string, time.Time, error)
-	GenerateRefreshTokenForStaff(staff *models.Staff) (string, time.Time, error)
+	GenerateAccessToken(user *models.User, sessionID string) (string, time.Time, error)
+	GenerateRefreshToken(user *models.User, sessionID, tokenID string) (string, time.Time, error)
+	GenerateAccessTokenForStaff(staff *models.Staff, sessionID string, mfa bool) (string, time.Time, error)
+	GenerateRefreshTokenForStaff(staff *models.Staff, sessionID, tokenID string, mfa bool) (string, time.Time, error)
+	GenerateImpersonationToken(user *models.User, staffID uint, sessionID string) (string, time.Time, error)
 	ValidateToken(tokenString string, isRefreshToken bool) (jwt.MapClaims, error)
 }
 
 type jwtService struct {
-	cfg *config.Config
+	cfg        *config.Config
+	keyManager KeyManager // nil in legacy HS256 mode
 }
 
-func NewJWTService(cfg *config.Config) JWTService {
-	return &jwtService{cfg: cfg}
+// NewJWTService creates a JWTService that signs with the key manager's current key, or with
+// the shared JWTSecret when keyManager is nil.
+func NewJWTService(cfg *config.Config, keyManager KeyManager) JWTService {
+	return &jwtService{cfg: cfg, keyManager: keyManager}
 }
 
+// Principal types carried in the "principal_type" claim. User and staff IDs come from
+// different tables and can overlap, so the ID alone never identifies an account.
+const (
+	PrincipalTypeUser  = "user"
+	PrincipalTypeStaff = "staff"
+)
+
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID        string `json:"user_id"`
+	PrincipalType string `json:"principal_type"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	SessionID     string `json:"sid,omitempty"` // Refresh-token family this token belongs to
+	MFA           bool   `json:"mfa,omitempty"` // Staff only: the session was opened with a verified second factor
+	// ImpersonatedBy is set on read-only user tokens minted for a staff member viewing the
+	// customer's account; it holds the staff ID.
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (s *jwtService) generateToken(user *models.User, expirationTime time.Duration, isRefreshToken bool) (string, time.Time, error) {
+// PrincipalTypeFromClaims returns the principal type of a validated token, or "" if the
+// token predates the claim or carries an unknown value.
+func PrincipalTypeFromClaims(claims jwt.MapClaims) string {
+	principalType, _ := claims["principal_type"].(string)
+	if principalType != PrincipalTypeUser && principalType != PrincipalTypeStaff {
+		return ""
+	}
+	return principalType
+}
+
+func (s *jwtService) generateToken(user *models.User, sessionID, tokenID, impersonatedBy string, expirationTime time.Duration, isRefreshToken bool) (string, time.Time, error) {
 	expiration := time.Now().Add(expirationTime)
 	claims := &Claims{
-		UserID: strconv.FormatUint(uint64(user.ID), 10),
-		Email:  user.Email,
-		Role:   "user",
+		UserID:         strconv.FormatUint(uint64(user.ID), 10),
+		PrincipalType:  PrincipalTypeUser,
+		Email:          user.Email,
+		Role:           "user",
+		SessionID:      sessionID,
+		ImpersonatedBy: impersonatedBy,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(expiration),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "invoice-financing-app",
@@ -51,21 +82,24 @@ func (s *jwtService) generateToken(user *models.User, expirationTime time.Durati
 		claims.RegisteredClaims.Subject = "access_token"
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	tokenString, err := s.sign(claims)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
 	return tokenString, expiration, nil
 }
 
-func (s *jwtService) generateTokenForStaff(staff *models.Staff, expirationTime time.Duration, isRefreshToken bool) (string, time.Time, error) {
+func (s *jwtService) generateTokenForStaff(staff *models.Staff, sessionID, tokenID string, mfa bool, expirationTime time.Duration, isRefreshToken bool) (string, time.Time, error) {
 	expiration := time.Now().Add(expirationTime)
 	claims := &Claims{
-		UserID: strconv.FormatUint(uint64(staff.ID), 10),
-		Email:  staff.Email,
-		Role:   "staff",
+		UserID:        strconv.FormatUint(uint64(staff.ID), 10),
+		PrincipalType: PrincipalTypeStaff,
+		Email:         staff.Email,
+		Role:          "staff",
+		SessionID:     sessionID,
+		MFA:           mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(expiration),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "invoice-financing-app",
@@ -77,37 +111,96 @@ func (s *jwtService) generateTokenForStaff(staff *models.Staff, expirationTime t
 		claims.RegisteredClaims.Subject = "access_token"
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	tokenString, err := s.sign(claims)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
 	return tokenString, expiration, nil
 }
 
-func (s *jwtService) GenerateAccessTokenForStaff(staff *models.Staff) (string, time.Time, error) {
-	return s.generateTokenForStaff(staff, s.cfg.JWTAccessTokenExpirationMinutes, false)
+// sign signs the claims with the current key and records its ID in the "kid" header.
+func (s *jwtService) sign(claims *Claims) (string, error) {
+	if s.keyManager == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.JWTSecret))
+	}
+
+	kid, method, key, err := s.keyManager.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// verificationKey resolves the key for a token from its "kid" header. HS256 tokens are only
+// accepted in legacy mode, or while JWT_ACCEPT_LEGACY_HS256 is set during a migration.
+func (s *jwtService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if s.keyManager != nil && !s.cfg.JWTAcceptLegacyHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.cfg.JWTSecret), nil
+	}
+	if s.keyManager == nil {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	method, key, err := s.keyManager.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key, nil
+}
+
+func (s *jwtService) GenerateAccessTokenForStaff(staff *models.Staff, sessionID string, mfa bool) (string, time.Time, error) {
+	return s.generateTokenForStaff(staff, sessionID, "", mfa, s.cfg.JWTAccessTokenExpirationMinutes, false)
+}
+
+func (s *jwtService) GenerateRefreshTokenForStaff(staff *models.Staff, sessionID, tokenID string, mfa bool) (string, time.Time, error) {
+	return s.generateTokenForStaff(staff, sessionID, tokenID, mfa, s.cfg.JWTRefreshTokenExpirationDays, true)
+}
+
+// MFAVerifiedFromClaims reports whether a staff token was issued after second-factor verification.
+func MFAVerifiedFromClaims(claims jwt.MapClaims) bool {
+	mfa, _ := claims["mfa"].(bool)
+	return mfa
 }
 
-func (s *jwtService) GenerateRefreshTokenForStaff(staff *models.Staff) (string, time.Time, error) {
-	return s.generateTokenForStaff(staff, s.cfg.JWTRefreshTokenExpirationDays, true)
+func (s *jwtService) GenerateAccessToken(user *models.User, sessionID string) (string, time.Time, error) {
+	return s.generateToken(user, sessionID, "", "", s.cfg.JWTAccessTokenExpirationMinutes, false)
 }
 
-func (s *jwtService) GenerateAccessToken(user *models.User) (string, time.Time, error) {
-	return s.generateToken(user, s.cfg.JWTAccessTokenExpirationMinutes, false)
+func (s *jwtService) GenerateRefreshToken(user *models.User, sessionID, tokenID string) (string, time.Time, error) {
+	return s.generateToken(user, sessionID, tokenID, "", s.cfg.JWTRefreshTokenExpirationDays, true)
 }
 
-func (s *jwtService) GenerateRefreshToken(user *models.User) (string, time.Time, error) {
-	return s.generateToken(user, s.cfg.JWTRefreshTokenExpirationDays, true)
+// GenerateImpersonationToken mints a short-lived access token for the user on behalf of a
+// staff member. No refresh token is issued; the view ends when the token expires.
+func (s *jwtService) GenerateImpersonationToken(user *models.User, staffID uint, sessionID string) (string, time.Time, error) {
+	return s.generateToken(user, sessionID, "", strconv.FormatUint(uint64(staffID), 10), s.cfg.ImpersonationExpiry, false)
+}
+
+// ImpersonatorFromClaims returns the staff ID of an impersonation token, or false for a token
+// issued to the user themselves.
+func ImpersonatorFromClaims(claims jwt.MapClaims) (uint, bool) {
+	staffIDStr, _ := claims["impersonated_by"].(string)
+	if staffIDStr == "" {
+		return 0, false
+	}
+	staffID, err := strconv.ParseUint(staffIDStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(staffID), true
 }
 
 func (s *jwtService) ValidateToken(tokenString string, isRefreshToken bool) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
+	token, err := jwt.Parse(tokenString, s.verificationKey)
 
 	if err != nil {
 		return nil, fmt.Errorf("token validation failed: %w", err)
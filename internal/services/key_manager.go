@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported values for JWT_SIGNING_ALG. HS256 keeps the legacy shared-secret behaviour and
+// disables the key manager entirely.
+const (
+	SigningAlgRS256 = "RS256"
+	SigningAlgEdDSA = "EdDSA"
+	SigningAlgHS256 = "HS256"
+)
+
+const (
+	rsaKeyBits      = 2048
+	keyFileExt      = ".pem"
+	keyIDTimeLayout = "20060102T150405.000000Z"
+
+	// unknownKeyReloadInterval limits how often an unknown kid makes the key directory be
+	// re-read, so that forged kids cannot turn every request into a directory scan.
+	unknownKeyReloadInterval = 30 * time.Second
+)
+
+var ErrUnknownSigningKey = errors.New("token was signed with an unknown key")
+
+// JWK is the public half of a signing key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type signingKey struct {
+	id        string
+	createdAt time.Time
+	method    jwt.SigningMethod
+	private   crypto.Signer
+}
+
+// KeyManager owns the asymmetric JWT keys stored as PKCS#8 PEM files in a directory (one file
+// per key, named <kid>.pem). The newest key signs; every key that may still have live tokens
+// remains available for verification and is published in the JWKS. Several API instances can
+// share the directory: each reloads it periodically and rotation by any one is picked up by all.
+type KeyManager interface {
+	SigningKey() (kid string, method jwt.SigningMethod, key crypto.Signer, err error)
+	VerificationKey(kid string) (jwt.SigningMethod, crypto.PublicKey, error)
+	JWKS() JWKSet
+	Reload() error
+	Rotate() (string, error)
+	RunRotation(ctx context.Context, checkInterval time.Duration)
+}
+
+type keyManager struct {
+	mu               sync.RWMutex
+	dir              string
+	algorithm        string
+	rotationInterval time.Duration
+	retention        time.Duration // How long a replaced key stays valid; must cover the longest token lifetime
+	keys             []*signingKey // Oldest first
+
+	reloadMu         sync.Mutex
+	lastUnknownKeyAt time.Time // When an unknown kid last triggered a reload
+}
+
+// NewKeyManager loads the keys in dir, generating the first one if the directory is empty or
+// only holds keys for a different algorithm.
+func NewKeyManager(dir, algorithm string, rotationInterval, retention time.Duration) (KeyManager, error) {
+	if algorithm != SigningAlgRS256 && algorithm != SigningAlgEdDSA {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", algorithm)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create JWT key directory %s: %w", dir, err)
+	}
+
+	km := &keyManager{dir: dir, algorithm: algorithm, rotationInterval: rotationInterval, retention: retention}
+	if err := km.Reload(); err != nil {
+		return nil, err
+	}
+	if km.needsRotation() {
+		if _, err := km.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return km, nil
+}
+
+func (km *keyManager) SigningKey() (string, jwt.SigningMethod, crypto.Signer, error) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	if len(km.keys) == 0 {
+		return "", nil, nil, errors.New("no JWT signing key available")
+	}
+	current := km.keys[len(km.keys)-1]
+	return current.id, current.method, current.private, nil
+}
+
+// VerificationKey returns the public key for kid. An unknown kid may have just been created by
+// another instance sharing the key directory, so the directory is reloaded (at most once per
+// unknownKeyReloadInterval) before the kid is rejected.
+func (km *keyManager) VerificationKey(kid string) (jwt.SigningMethod, crypto.PublicKey, error) {
+	if method, public, ok := km.findVerificationKey(kid); ok {
+		return method, public, nil
+	}
+	if !km.reloadForUnknownKey() {
+		return nil, nil, ErrUnknownSigningKey
+	}
+	if method, public, ok := km.findVerificationKey(kid); ok {
+		return method, public, nil
+	}
+	return nil, nil, ErrUnknownSigningKey
+}
+
+func (km *keyManager) findVerificationKey(kid string) (jwt.SigningMethod, crypto.PublicKey, bool) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	for _, key := range km.keys {
+		if key.id == kid {
+			return key.method, key.private.Public(), true
+		}
+	}
+	return nil, nil, false
+}
+
+// reloadForUnknownKey reloads the key directory unless that was done recently. It reports
+// whether a reload happened.
+func (km *keyManager) reloadForUnknownKey() bool {
+	km.reloadMu.Lock()
+	defer km.reloadMu.Unlock()
+	if time.Since(km.lastUnknownKeyAt) < unknownKeyReloadInterval {
+		return false
+	}
+	km.lastUnknownKeyAt = time.Now()
+	if err := km.Reload(); err != nil {
+		log.Printf("Failed to reload JWT keys for an unknown key ID: %v", err)
+		return false
+	}
+	return true
+}
+
+func (km *keyManager) JWKS() JWKSet {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(km.keys))}
+	for i := len(km.keys) - 1; i >= 0; i-- { // Current key first
+		key := km.keys[i]
+		jwk := JWK{Use: "sig", Alg: key.method.Alg(), Kid: key.id}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Reload re-reads the key directory. Files that cannot be parsed are skipped and logged.
+func (km *keyManager) Reload() error {
+	entries, err := os.ReadDir(km.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read JWT key directory %s: %w", km.dir, err)
+	}
+
+	var keys []*signingKey
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExt {
+			continue
+		}
+		key, err := loadSigningKey(filepath.Join(km.dir, entry.Name()))
+		if err != nil {
+			log.Printf("Skipping JWT key file %s: %v", entry.Name(), err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].createdAt.Before(keys[j].createdAt) })
+
+	km.mu.Lock()
+	km.keys = keys
+	km.mu.Unlock()
+	return nil
+}
+
+// Rotate generates a new key, which becomes the signing key immediately. Older keys stay
+// published until the retention period has passed.
+func (km *keyManager) Rotate() (string, error) {
+	var private crypto.Signer
+	var err error
+	switch km.algorithm {
+	case SigningAlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate JWT signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT signing key: %w", err)
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+	kid := time.Now().UTC().Format(keyIDTimeLayout) + "-" + hex.EncodeToString(suffix)
+
+	// Write to a temporary name first so other instances never read a half-written key
+	path := filepath.Join(km.dir, kid+keyFileExt)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write JWT signing key: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to install JWT signing key: %w", err)
+	}
+
+	if err := km.Reload(); err != nil {
+		return "", err
+	}
+	log.Printf("Rotated JWT signing key; new key ID %s (%s)", kid, km.algorithm)
+	return kid, nil
+}
+
+// RunRotation checks the key set every checkInterval until ctx is cancelled: it reloads keys
+// written by other instances, rotates once the current key is older than the rotation
+// interval, and deletes keys whose retention period has ended.
+func (km *keyManager) RunRotation(ctx context.Context, checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := km.Reload(); err != nil {
+				log.Printf("Failed to reload JWT keys: %v", err)
+				continue
+			}
+			if km.needsRotation() {
+				if _, err := km.Rotate(); err != nil {
+					log.Printf("Failed to rotate JWT signing key: %v", err)
+				}
+			}
+			km.pruneRetiredKeys()
+		}
+	}
+}
+
+func (km *keyManager) needsRotation() bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	if len(km.keys) == 0 {
+		return true
+	}
+	current := km.keys[len(km.keys)-1]
+	return current.method.Alg() != km.algorithm || time.Since(current.createdAt) >= km.rotationInterval
+}
+
+// pruneRetiredKeys deletes keys that were replaced longer ago than the retention period; no
+// token signed with them can still be valid. The current key is never removed.
+func (km *keyManager) pruneRetiredKeys() {
+	km.mu.RLock()
+	var expired []string
+	for i := 0; i < len(km.keys)-1; i++ {
+		if time.Since(km.keys[i+1].createdAt) > km.retention {
+			expired = append(expired, km.keys[i].id)
+		}
+	}
+	km.mu.RUnlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	for _, kid := range expired {
+		if err := os.Remove(filepath.Join(km.dir, kid+keyFileExt)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove retired JWT key %s: %v", kid, err)
+		}
+	}
+	if err := km.Reload(); err != nil {
+		log.Printf("Failed to reload JWT keys after pruning: %v", err)
+	}
+}
+
+func loadSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("not a PKCS#8 PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{id: strings.TrimSuffix(filepath.Base(path), keyFileExt)}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private = jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.method, key.private = jwt.SigningMethodEdDSA, private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	// Key IDs start with their creation time; fall back to the file time for hand-placed keys
+	if createdAt, err := time.Parse(keyIDTimeLayout, strings.SplitN(key.id, "-", 2)[0]); err == nil {
+		key.createdAt = createdAt
+	} else if info, err := os.Stat(path); err == nil {
+		key.createdAt = info.ModTime()
+	}
+	return key, nil
+}
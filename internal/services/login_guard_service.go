@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	loginGuardFreeAttempts = 2                // Failures allowed before progressive delays start
+	loginGuardMaxDelay     = 30 * time.Second // Cap for the progressive delay between attempts
+	loginGuardLockedIndex  = "loginguard:locked"
+)
+
+var (
+	ErrTooManyLoginAttempts = errors.New("too many login attempts, please wait before trying again")
+	ErrAccountLocked        = errors.New("account is temporarily locked after repeated failed login attempts")
+	ErrUnlockTokenInvalid   = errors.New("unlock link is invalid or has expired")
+)
+
+// LoginAttemptError is returned when a login or 2FA attempt is refused before credentials are
+// checked. It unwraps to ErrAccountLocked or ErrTooManyLoginAttempts.
+type LoginAttemptError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginAttemptError) Error() string {
+	return fmt.Sprintf("%v (retry after %ds)", e.Unwrap(), int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+func (e *LoginAttemptError) Unwrap() error {
+	if e.Locked {
+		return ErrAccountLocked
+	}
+	return ErrTooManyLoginAttempts
+}
+
+// LockedAccount is an entry in the lockout list shown to admins.
+type LockedAccount struct {
+	Account     string
+	LockedUntil time.Time
+}
+
+// LoginGuardService counts failed password and 2FA attempts in Redis sliding windows, per
+// account (email) and per client IP, and locks accounts that exceed the threshold.
+type LoginGuardService interface {
+	Check(ctx context.Context, account, ipAddress string) error
+	RecordFailure(ctx context.Context, account, ipAddress string) (locked bool, err error)
+	RecordSuccess(ctx context.Context, account string) error
+	ListLocked(ctx context.Context) ([]LockedAccount, error)
+	Unlock(ctx context.Context, account string) error
+}
+
+type loginGuardService struct {
+	rdb                *redis.Client
+	window             time.Duration
+	maxAccountFailures int
+	maxIPFailures      int
+	lockoutDuration    time.Duration
+}
+
+func NewLoginGuardService(rdb *redis.Client, window time.Duration, maxAccountFailures, maxIPFailures int, lockoutDuration time.Duration) LoginGuardService {
+	return &loginGuardService{
+		rdb:                rdb,
+		window:             window,
+		maxAccountFailures: maxAccountFailures,
+		maxIPFailures:      maxIPFailures,
+		lockoutDuration:    lockoutDuration,
+	}
+}
+
+// NormalizeLoginAccount lower-cases and trims an email so that counters cannot be bypassed
+// by changing its case.
+func NormalizeLoginAccount(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountFailuresKey(account string) string {
+	return fmt.Sprintf("loginguard:fail:account:%s", account)
+}
+
+func ipFailuresKey(ipAddress string) string {
+	return fmt.Sprintf("loginguard:fail:ip:%s", ipAddress)
+}
+
+func accountLockKey(account string) string {
+	return fmt.Sprintf("loginguard:lock:%s", account)
+}
+
+// Check refuses the attempt if the account is locked, the IP has exceeded its budget, or the
+// progressive delay since the account's last failure has not yet elapsed.
+func (s *loginGuardService) Check(ctx context.Context, account, ipAddress string) error {
+	now := time.Now()
+	windowStart := strconv.FormatInt(now.Add(-s.window).UnixNano(), 10)
+
+	pipe := s.rdb.Pipeline()
+	lockTTL := pipe.PTTL(ctx, accountLockKey(account))
+	accountCount := pipe.ZCount(ctx, accountFailuresKey(account), windowStart, "+inf")
+	lastFailure := pipe.ZRevRangeWithScores(ctx, accountFailuresKey(account), 0, 0)
+	var ipCount *redis.IntCmd
+	var oldestIPFailure *redis.ZSliceCmd
+	if ipAddress != "" {
+		ipCount = pipe.ZCount(ctx, ipFailuresKey(ipAddress), windowStart, "+inf")
+		oldestIPFailure = pipe.ZRangeByScoreWithScores(ctx, ipFailuresKey(ipAddress), &redis.ZRangeBy{Min: windowStart, Max: "+inf", Count: 1})
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		log.Printf("Login guard check failed for %s: %v", account, err)
+		return nil // Fail open: Redis trouble should not lock everyone out
+	}
+
+	if ttl := lockTTL.Val(); ttl > 0 {
+		return &LoginAttemptError{Locked: true, RetryAfter: ttl}
+	}
+
+	if ipCount != nil && int(ipCount.Val()) >= s.maxIPFailures {
+		retryAfter := s.window
+		if oldest := oldestIPFailure.Val(); len(oldest) > 0 {
+			retryAfter = time.Until(time.Unix(0, int64(oldest[0].Score)).Add(s.window))
+		}
+		return &LoginAttemptError{RetryAfter: retryAfter}
+	}
+
+	failures := int(accountCount.Val())
+	if failures > loginGuardFreeAttempts {
+		if last := lastFailure.Val(); len(last) > 0 {
+			delay := time.Duration(math.Pow(2, float64(failures-loginGuardFreeAttempts-1))) * time.Second
+			if delay > loginGuardMaxDelay {
+				delay = loginGuardMaxDelay
+			}
+			if wait := time.Until(time.Unix(0, int64(last[0].Score)).Add(delay)); wait > 0 {
+				return &LoginAttemptError{RetryAfter: wait}
+			}
+		}
+	}
+	return nil
+}
+
+// RecordFailure adds a failed attempt to the account and IP windows and locks the account once
+// it reaches the threshold. It reports whether this failure caused a lockout.
+func (s *loginGuardService) RecordFailure(ctx context.Context, account, ipAddress string) (bool, error) {
+	now := time.Now()
+	member := &redis.Z{Score: float64(now.UnixNano()), Member: strconv.FormatInt(now.UnixNano(), 10)}
+	windowStart := strconv.FormatInt(now.Add(-s.window).UnixNano(), 10)
+
+	pipe := s.rdb.TxPipeline()
+	pipe.ZAdd(ctx, accountFailuresKey(account), member)
+	pipe.ZRemRangeByScore(ctx, accountFailuresKey(account), "-inf", "("+windowStart)
+	accountCount := pipe.ZCard(ctx, accountFailuresKey(account))
+	pipe.Expire(ctx, accountFailuresKey(account), s.window)
+	if ipAddress != "" {
+		pipe.ZAdd(ctx, ipFailuresKey(ipAddress), member)
+		pipe.ZRemRangeByScore(ctx, ipFailuresKey(ipAddress), "-inf", "("+windowStart)
+		pipe.Expire(ctx, ipFailuresKey(ipAddress), s.window)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to record login failure for %s: %v", account, err)
+		return false, fmt.Errorf("could not record login failure: %w", err)
+	}
+
+	if int(accountCount.Val()) < s.maxAccountFailures {
+		return false, nil
+	}
+
+	lockedUntil := now.Add(s.lockoutDuration)
+	pipe = s.rdb.TxPipeline()
+	pipe.Set(ctx, accountLockKey(account), lockedUntil.Unix(), s.lockoutDuration)
+	pipe.ZAdd(ctx, loginGuardLockedIndex, &redis.Z{Score: float64(lockedUntil.Unix()), Member: account})
+	pipe.Del(ctx, accountFailuresKey(account))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to lock account %s: %v", account, err)
+		return false, fmt.Errorf("could not lock account: %w", err)
+	}
+	return true, nil
+}
+
+// RecordSuccess clears the account's failure window after a complete, successful login.
+func (s *loginGuardService) RecordSuccess(ctx context.Context, account string) error {
+	if err := s.rdb.Del(ctx, accountFailuresKey(account)).Err(); err != nil && err != redis.Nil {
+		log.Printf("Failed to reset login failures for %s: %v", account, err)
+		return fmt.Errorf("could not reset login failures: %w", err)
+	}
+	return nil
+}
+
+func (s *loginGuardService) ListLocked(ctx context.Context) ([]LockedAccount, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	_ = s.rdb.ZRemRangeByScore(ctx, loginGuardLockedIndex, "-inf", "("+now).Err()
+
+	entries, err := s.rdb.ZRangeByScoreWithScores(ctx, loginGuardLockedIndex, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to list locked accounts: %v", err)
+		return nil, fmt.Errorf("could not list locked accounts: %w", err)
+	}
+
+	locked := make([]LockedAccount, 0, len(entries))
+	for _, entry := range entries {
+		account, _ := entry.Member.(string)
+		locked = append(locked, LockedAccount{Account: account, LockedUntil: time.Unix(int64(entry.Score), 0)})
+	}
+	return locked, nil
+}
+
+func (s *loginGuardService) Unlock(ctx context.Context, account string) error {
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, accountLockKey(account), accountFailuresKey(account))
+	pipe.ZRem(ctx, loginGuardLockedIndex, account)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to unlock account %s: %v", account, err)
+		return fmt.Errorf("could not unlock account: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"invoiceB2B/internal/config"
+	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/repositories"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const invitationTokenBytes = 32
+
+var (
+	ErrNotOrganizationMember        = errors.New("user does not belong to an organization")
+	ErrOrganizationPermissionDenied = errors.New("your organization role does not allow this action")
+	ErrMemberNotFound               = errors.New("organization member not found")
+	ErrLastOwner                    = errors.New("an organization must keep at least one owner")
+	ErrInvitationNotFound           = errors.New("invitation not found")
+	ErrInvitationInvalid            = errors.New("invitation is invalid or has expired")
+	ErrInvitationAlreadyPending     = errors.New("an invitation is already pending for this email")
+)
+
+// OrganizationService manages customer organisations and their members. Every user acts on
+// behalf of exactly one organisation, which owns KYC, invoices and bank details.
+type OrganizationService interface {
+	GetMembership(ctx context.Context, userID uint) (*models.OrganizationMember, error)
+	GetOrganization(ctx context.Context, userID uint) (*dtos.OrganizationResponse, error)
+	UpdateOrganization(ctx context.Context, userID uint, req dtos.UpdateOrganizationRequest) (*dtos.OrganizationResponse, error)
+
+	ListMembers(ctx context.Context, userID uint) ([]dtos.OrganizationMemberResponse, error)
+	UpdateMemberRole(ctx context.Context, userID, memberUserID uint, role string) (*dtos.OrganizationMemberResponse, error)
+	RemoveMember(ctx context.Context, userID, memberUserID uint) error
+
+	InviteMember(ctx context.Context, userID uint, req dtos.InviteMemberRequest) (*dtos.OrganizationInvitationResponse, error)
+	ListInvitations(ctx context.Context, userID uint) ([]dtos.OrganizationInvitationResponse, error)
+	RevokeInvitation(ctx context.Context, userID, invitationID uint) error
+	AcceptInvitation(ctx context.Context, req dtos.AcceptInvitationRequest) (*models.User, error)
+}
+
+type organizationService struct {
+	orgRepo        repositories.OrganizationRepository
+	userRepo       repositories.UserRepository
+	kycRepo        repositories.KYCRepository
+	sessionService SessionService
+	emailService   EmailService
+	activityLogSvc ActivityLogService
+	cfg            *config.Config
+}
+
+func NewOrganizationService(
+	orgRepo repositories.OrganizationRepository,
+	userRepo repositories.UserRepository,
+	kycRepo repositories.KYCRepository,
+	sessionService SessionService,
+	emailService EmailService,
+	activityLogSvc ActivityLogService,
+	cfg *config.Config,
+) OrganizationService {
+	return &organizationService{
+		orgRepo:        orgRepo,
+		userRepo:       userRepo,
+		kycRepo:        kycRepo,
+		sessionService: sessionService,
+		emailService:   emailService,
+		activityLogSvc: activityLogSvc,
+		cfg:            cfg,
+	}
+}
+
+func (s *organizationService) GetMembership(ctx context.Context, userID uint) (*models.OrganizationMember, error) {
+	member, err := s.orgRepo.FindMembershipByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrganizationMember
+		}
+		return nil, fmt.Errorf("could not load organization membership: %w", err)
+	}
+	return member, nil
+}
+
+// requireOwner returns the caller's membership if they are an owner of their organisation.
+func (s *organizationService) requireOwner(ctx context.Context, userID uint) (*models.OrganizationMember, error) {
+	member, err := s.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role != models.OrgRoleOwner {
+		return nil, ErrOrganizationPermissionDenied
+	}
+	return member, nil
+}
+
+func (s *organizationService) GetOrganization(ctx context.Context, userID uint) (*dtos.OrganizationResponse, error) {
+	member, err := s.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.mapOrganizationToResponse(ctx, &member.Organization, member.Role), nil
+}
+
+func (s *organizationService) UpdateOrganization(ctx context.Context, userID uint, req dtos.UpdateOrganizationRequest) (*dtos.OrganizationResponse, error) {
+	member, err := s.requireOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	org := &member.Organization
+	if req.Name != "" {
+		org.Name = req.Name
+	}
+	if req.BankAccountName != "" {
+		org.BankAccountName = req.BankAccountName
+	}
+	if req.BankAccountNumber != "" {
+		org.BankAccountNumber = req.BankAccountNumber
+	}
+	if req.BankName != "" {
+		org.BankName = req.BankName
+	}
+	if err := s.orgRepo.Update(ctx, org); err != nil {
+		return nil, fmt.Errorf("could not update organization: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "ORG_UPDATED", map[string]interface{}{"organization_id": org.ID}, "")
+	return s.mapOrganizationToResponse(ctx, org, member.Role), nil
+}
+
+func (s *organizationService) ListMembers(ctx context.Context, userID uint) ([]dtos.OrganizationMemberResponse, error) {
+	member, err := s.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.orgRepo.ListMembers(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list organization members: %w", err)
+	}
+
+	responses := make([]dtos.OrganizationMemberResponse, 0, len(members))
+	for i := range members {
+		responses = append(responses, mapMemberToResponse(&members[i]))
+	}
+	return responses, nil
+}
+
+func (s *organizationService) UpdateMemberRole(ctx context.Context, userID, memberUserID uint, role string) (*dtos.OrganizationMemberResponse, error) {
+	if !models.IsOrgRole(role) {
+		return nil, fmt.Errorf("invalid organization role %q", role)
+	}
+	owner, err := s.requireOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.findMember(ctx, owner.OrganizationID, memberUserID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role == role {
+		resp := mapMemberToResponse(member)
+		return &resp, nil
+	}
+	if err := s.ensureAnotherOwner(ctx, member); err != nil {
+		return nil, err
+	}
+
+	previousRole := member.Role
+	member.Role = role
+	if err := s.orgRepo.UpdateMember(ctx, member); err != nil {
+		return nil, fmt.Errorf("could not update member role: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "ORG_MEMBER_ROLE_CHANGED", map[string]interface{}{
+		"organization_id": owner.OrganizationID, "member_user_id": memberUserID, "from": previousRole, "to": role,
+	}, "")
+	resp := mapMemberToResponse(member)
+	return &resp, nil
+}
+
+// RemoveMember takes a member out of the organisation. Their login only exists to act for
+// this organisation, so it is deactivated and signed out everywhere.
+func (s *organizationService) RemoveMember(ctx context.Context, userID, memberUserID uint) error {
+	owner, err := s.requireOwner(ctx, userID)
+	if err != nil {
+		return err
+	}
+	member, err := s.findMember(ctx, owner.OrganizationID, memberUserID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureAnotherOwner(ctx, member); err != nil {
+		return err
+	}
+
+	if err := s.orgRepo.DeleteMember(ctx, member); err != nil {
+		return fmt.Errorf("could not remove member: %w", err)
+	}
+	member.User.IsActive = false
+	if _, err := s.userRepo.Update(ctx, &member.User); err != nil {
+		log.Printf("Failed to deactivate removed member %d: %v", memberUserID, err)
+	}
+	if err := s.sessionService.RevokeAllForSubject(ctx, principalSubject(PrincipalTypeUser, memberUserID)); err != nil {
+		log.Printf("Failed to revoke sessions of removed member %d: %v", memberUserID, err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "ORG_MEMBER_REMOVED", map[string]interface{}{
+		"organization_id": owner.OrganizationID, "member_user_id": memberUserID, "member_email": member.User.Email,
+	}, "")
+	return nil
+}
+
+// InviteMember emails a single-use link that lets the invitee create a login in the caller's
+// organisation. Existing accounts cannot be invited, since a user belongs to one organisation.
+func (s *organizationService) InviteMember(ctx context.Context, userID uint, req dtos.InviteMemberRequest) (*dtos.OrganizationInvitationResponse, error) {
+	if !models.IsOrgRole(req.Role) {
+		return nil, fmt.Errorf("invalid organization role %q", req.Role)
+	}
+	owner, err := s.requireOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if existing, _ := s.userRepo.FindByEmail(ctx, email); existing != nil {
+		return nil, ErrEmailExists
+	}
+	if pending, _ := s.orgRepo.FindPendingInvitationByEmail(ctx, owner.OrganizationID, email); pending != nil {
+		return nil, ErrInvitationAlreadyPending
+	}
+
+	token, tokenHash, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation := &models.OrganizationInvitation{
+		OrganizationID: owner.OrganizationID,
+		Email:          email,
+		Role:           req.Role,
+		TokenHash:      tokenHash,
+		InvitedByID:    userID,
+		ExpiresAt:      time.Now().Add(s.cfg.OrganizationInvitationExpiry),
+	}
+	if err := s.orgRepo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("could not create invitation: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "ORG_MEMBER_INVITED", map[string]interface{}{
+		"organization_id": owner.OrganizationID, "email": email, "role": req.Role,
+	}, "")
+
+	orgName := owner.Organization.Name
+	go func() {
+		link := fmt.Sprintf("%s/accept-invitation?token=%s", s.cfg.FrontendBaseURL, token)
+		subject := fmt.Sprintf("You've been invited to join %s", orgName)
+		body := fmt.Sprintf("Hi,\n\nYou've been invited to join %s on the invoice financing platform as %s.\nCreate your login using the link below (valid for %d hours):\n%s\n\nIf you weren't expecting this invitation, you can ignore this email.\n\nThanks,\nThe Team",
+			orgName, req.Role, int(s.cfg.OrganizationInvitationExpiry.Hours()), link)
+		if emailErr := s.emailService.SendEmail(email, subject, body); emailErr != nil {
+			log.Printf("Failed to send organization invitation email to %s: %v", email, emailErr)
+		}
+	}()
+
+	resp := mapInvitationToResponse(invitation)
+	return &resp, nil
+}
+
+func (s *organizationService) ListInvitations(ctx context.Context, userID uint) ([]dtos.OrganizationInvitationResponse, error) {
+	owner, err := s.requireOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err := s.orgRepo.ListPendingInvitations(ctx, owner.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list invitations: %w", err)
+	}
+
+	responses := make([]dtos.OrganizationInvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		responses = append(responses, mapInvitationToResponse(&invitations[i]))
+	}
+	return responses, nil
+}
+
+func (s *organizationService) RevokeInvitation(ctx context.Context, userID, invitationID uint) error {
+	owner, err := s.requireOwner(ctx, userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.orgRepo.DeleteInvitation(ctx, owner.OrganizationID, invitationID)
+	if err != nil {
+		return fmt.Errorf("could not revoke invitation: %w", err)
+	}
+	if !deleted {
+		return ErrInvitationNotFound
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "ORG_INVITATION_REVOKED", map[string]interface{}{
+		"organization_id": owner.OrganizationID, "invitation_id": invitationID,
+	}, "")
+	return nil
+}
+
+// AcceptInvitation creates the invitee's login and adds it to the organisation. Following the
+// emailed link proves ownership of the address, so the account starts out verified.
+func (s *organizationService) AcceptInvitation(ctx context.Context, req dtos.AcceptInvitationRequest) (*models.User, error) {
+	if err := ValidatePasswordPolicy(req.Password); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(req.Token))
+	invitation, err := s.orgRepo.FindPendingInvitationByTokenHash(ctx, hex.EncodeToString(sum[:]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, fmt.Errorf("could not look up invitation: %w", err)
+	}
+	if existing, _ := s.userRepo.FindByEmail(ctx, invitation.Email); existing != nil {
+		return nil, ErrEmailExists
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Email:           invitation.Email,
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		CompanyName:     invitation.Organization.Name,
+		PasswordHash:    req.Password, // Hashed by the BeforeCreate hook
+		IsActive:        true,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.orgRepo.AcceptInvitation(ctx, invitation, user); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid // Accepted concurrently
+		}
+		return nil, fmt.Errorf("could not accept invitation: %w", err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &user.ID, "ORG_INVITATION_ACCEPTED", map[string]interface{}{
+		"organization_id": invitation.OrganizationID, "role": invitation.Role, "invited_by": invitation.InvitedByID,
+	}, "")
+	return user, nil
+}
+
+func (s *organizationService) findMember(ctx context.Context, organizationID, memberUserID uint) (*models.OrganizationMember, error) {
+	member, err := s.orgRepo.FindMember(ctx, organizationID, memberUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("could not load organization member: %w", err)
+	}
+	return member, nil
+}
+
+// ensureAnotherOwner refuses to demote or remove the organisation's only owner.
+func (s *organizationService) ensureAnotherOwner(ctx context.Context, member *models.OrganizationMember) error {
+	if member.Role != models.OrgRoleOwner {
+		return nil
+	}
+	owners, err := s.orgRepo.CountMembersByRole(ctx, member.OrganizationID, models.OrgRoleOwner)
+	if err != nil {
+		return fmt.Errorf("could not count organization owners: %w", err)
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (s *organizationService) mapOrganizationToResponse(ctx context.Context, org *models.Organization, role string) *dtos.OrganizationResponse {
+	kycStatus := "not_submitted"
+	if kyc, err := s.kycRepo.FindByOrganizationID(ctx, org.ID); err == nil && kyc != nil {
+		kycStatus = string(kyc.Status)
+	}
+	return &dtos.OrganizationResponse{
+		ID:                org.ID,
+		Name:              org.Name,
+		BankAccountName:   org.BankAccountName,
+		BankAccountNumber: org.BankAccountNumber,
+		BankName:          org.BankName,
+		KYCStatus:         kycStatus,
+		Role:              role,
+		CreatedAt:         org.CreatedAt,
+		UpdatedAt:         org.UpdatedAt,
+	}
+}
+
+func mapMemberToResponse(member *models.OrganizationMember) dtos.OrganizationMemberResponse {
+	return dtos.OrganizationMemberResponse{
+		UserID:    member.UserID,
+		Email:     member.User.Email,
+		FirstName: member.User.FirstName,
+		LastName:  member.User.LastName,
+		Role:      member.Role,
+		JoinedAt:  member.CreatedAt,
+	}
+}
+
+func mapInvitationToResponse(invitation *models.OrganizationInvitation) dtos.OrganizationInvitationResponse {
+	return dtos.OrganizationInvitationResponse{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+// generateInvitationToken returns the token to email and the hash to store.
+func generateInvitationToken() (string, string, error) {
+	buffer := make([]byte, invitationTokenBytes)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", "", fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	token := hex.EncodeToString(buffer)
+	sum := sha256.Sum256([]byte(token))
+	return token, hex.EncodeToString(sum[:]), nil
+}
@@ -3,16 +3,41 @@ package services
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 const otpLength = 6
 const otpChars = "0123456789"
+const actionTokenBytes = 32
+
+// claimTOTPStepScript stores a TOTP time step as the subject's last used one unless the same or
+// a later step was already used, making each authenticator code single-use.
+var claimTOTPStepScript = redis.NewScript(`
+local last = redis.call('GET', KEYS[1])
+if last and tonumber(last) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// Purposes for single-use action tokens; each purpose gets its own key space.
+const (
+	ActionTokenPasswordReset     = "password_reset"
+	ActionTokenEmailVerification = "email_verification"
+	ActionTokenAccountUnlock     = "account_unlock"
+	ActionTokenStaff2FAEnroll    = "staff_2fa_enrollment"
+	ActionToken2FALogin          = "2fa_login"
+)
 
 type OTPService interface {
 	GenerateAndStoreOTP(ctx context.Context, userID string) (string, error)
@@ -20,6 +45,17 @@ type OTPService interface {
 	DeleteOTP(ctx context.Context, userID string) error
 	BlacklistToken(ctx context.Context, tokenStr string, expiry time.Duration) error // Added for Logout
 	IsTokenBlacklisted(ctx context.Context, tokenStr string) (bool, error)           // Added for RefreshToken
+	StorePendingTOTPSecret(ctx context.Context, userID string, secret string, expiry time.Duration) error
+	GetPendingTOTPSecret(ctx context.Context, userID string) (string, error)
+	DeletePendingTOTPSecret(ctx context.Context, userID string) error
+	IssueActionToken(ctx context.Context, purpose, subject string, expiry time.Duration) (string, error)
+	ConsumeActionToken(ctx context.Context, purpose, token string) (string, error)
+	PeekActionToken(ctx context.Context, purpose, token string) (string, error)
+	RevokeTokensIssuedBefore(ctx context.Context, subject string, cutoff time.Time, ttl time.Duration) error
+	GetTokenRevocationCutoff(ctx context.Context, subject string) (time.Time, error)
+	IsTokenRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error)
+	AcquireThrottle(ctx context.Context, scope, subject string, window time.Duration) (bool, error)
+	ClaimTOTPStep(ctx context.Context, subject string, step int64, window time.Duration) (bool, error)
 }
 
 type otpService struct {
@@ -113,3 +149,163 @@ func (s *otpService) IsTokenBlacklisted(ctx context.Context, token string) (bool
 	}
 	return val == "blacklisted", nil
 }
+
+// StorePendingTOTPSecret keeps a freshly generated authenticator secret until the user
+// confirms enrollment with a first valid code.
+func (s *otpService) StorePendingTOTPSecret(ctx context.Context, userID string, secret string, expiry time.Duration) error {
+	key := fmt.Sprintf("totp:pending:%s", userID)
+	if err := s.rdb.Set(ctx, key, secret, expiry).Err(); err != nil {
+		log.Printf("Failed to store pending TOTP secret in Redis for user %s: %v", userID, err)
+		return fmt.Errorf("could not store pending TOTP secret: %w", err)
+	}
+	return nil
+}
+
+func (s *otpService) GetPendingTOTPSecret(ctx context.Context, userID string) (string, error) {
+	key := fmt.Sprintf("totp:pending:%s", userID)
+	secret, err := s.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil // No enrollment in progress (expired or never started)
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve pending TOTP secret from Redis for user %s: %v", userID, err)
+		return "", fmt.Errorf("could not retrieve pending TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (s *otpService) DeletePendingTOTPSecret(ctx context.Context, userID string) error {
+	key := fmt.Sprintf("totp:pending:%s", userID)
+	err := s.rdb.Del(ctx, key).Err()
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to delete pending TOTP secret from Redis for user %s: %v", userID, err)
+		return fmt.Errorf("could not delete pending TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// IssueActionToken creates a random single-use token (password reset, email verification, ...)
+// bound to a subject such as "user:42". Only a hash of the token is stored in Redis.
+func (s *otpService) IssueActionToken(ctx context.Context, purpose, subject string, expiry time.Duration) (string, error) {
+	buffer := make([]byte, actionTokenBytes)
+	if _, err := io.ReadFull(rand.Reader, buffer); err != nil {
+		return "", fmt.Errorf("failed to generate action token bytes: %w", err)
+	}
+	token := hex.EncodeToString(buffer)
+
+	if err := s.rdb.Set(ctx, actionTokenKey(purpose, token), subject, expiry).Err(); err != nil {
+		log.Printf("Failed to store %s token in Redis for %s: %v", purpose, subject, err)
+		return "", fmt.Errorf("could not store action token: %w", err)
+	}
+	return token, nil
+}
+
+// ConsumeActionToken atomically reads and deletes an action token, returning the subject it
+// was issued for. An empty subject means the token is unknown, expired or already used.
+func (s *otpService) ConsumeActionToken(ctx context.Context, purpose, token string) (string, error) {
+	subject, err := s.rdb.GetDel(ctx, actionTokenKey(purpose, token)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		log.Printf("Failed to consume %s token from Redis: %v", purpose, err)
+		return "", fmt.Errorf("could not consume action token: %w", err)
+	}
+	return subject, nil
+}
+
+// PeekActionToken returns the subject of an action token without using it up, for flows that
+// take several steps before the token is finally consumed.
+func (s *otpService) PeekActionToken(ctx context.Context, purpose, token string) (string, error) {
+	subject, err := s.rdb.Get(ctx, actionTokenKey(purpose, token)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		log.Printf("Failed to read %s token from Redis: %v", purpose, err)
+		return "", fmt.Errorf("could not read action token: %w", err)
+	}
+	return subject, nil
+}
+
+// RevokeTokensIssuedBefore records a cut-off for a subject; any JWT issued before it is
+// considered revoked. The ttl should cover the longest-lived token (the refresh token).
+func (s *otpService) RevokeTokensIssuedBefore(ctx context.Context, subject string, cutoff time.Time, ttl time.Duration) error {
+	key := fmt.Sprintf("revoked_before:%s", subject)
+	if err := s.rdb.Set(ctx, key, cutoff.Unix(), ttl).Err(); err != nil {
+		log.Printf("Failed to store token revocation cut-off for %s: %v", subject, err)
+		return fmt.Errorf("could not revoke tokens: %w", err)
+	}
+	return nil
+}
+
+// GetTokenRevocationCutoff returns the zero time when no revocation is recorded for the subject.
+func (s *otpService) GetTokenRevocationCutoff(ctx context.Context, subject string) (time.Time, error) {
+	key := fmt.Sprintf("revoked_before:%s", subject)
+	val, err := s.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		log.Printf("Error checking token revocation cut-off for %s: %v", subject, err)
+		return time.Time{}, fmt.Errorf("could not check token revocation: %w", err)
+	}
+	unix, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid token revocation cut-off for %s: %w", subject, err)
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// IsTokenRevoked reports whether a validated token was issued before its principal's
+// revocation cut-off (set on password reset or change). "iat" only has one-second precision,
+// so tokens issued within the cut-off second count as revoked too.
+func (s *otpService) IsTokenRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	idStr, _ := claims["user_id"].(string)
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	principalType := PrincipalTypeFromClaims(claims)
+	if principalType == "" {
+		return false, nil
+	}
+	cutoff, err := s.GetTokenRevocationCutoff(ctx, principalSubject(principalType, uint(id)))
+	if err != nil || cutoff.IsZero() {
+		return false, err
+	}
+	issuedAt, ok := claims["iat"].(float64)
+	if !ok {
+		return true, nil
+	}
+	return int64(issuedAt) <= cutoff.Unix(), nil
+}
+
+// AcquireThrottle returns true if no other call for the same scope and subject happened within
+// the window, and starts a new window. It returns false while the caller is throttled.
+func (s *otpService) AcquireThrottle(ctx context.Context, scope, subject string, window time.Duration) (bool, error) {
+	key := fmt.Sprintf("throttle:%s:%s", scope, subject)
+	acquired, err := s.rdb.SetNX(ctx, key, 1, window).Result()
+	if err != nil {
+		log.Printf("Failed to check %s throttle for %s: %v", scope, subject, err)
+		return false, fmt.Errorf("could not check throttle: %w", err)
+	}
+	return acquired, nil
+}
+
+// ClaimTOTPStep records an accepted authenticator code's time step for the subject. It returns
+// false when that step or a later one was already used, i.e. the code is being replayed.
+func (s *otpService) ClaimTOTPStep(ctx context.Context, subject string, step int64, window time.Duration) (bool, error) {
+	key := fmt.Sprintf("totp:last_step:%s", subject)
+	claimed, err := claimTOTPStepScript.Run(ctx, s.rdb, []string{key}, step, window.Milliseconds()).Int()
+	if err != nil {
+		log.Printf("Failed to record TOTP step for %s: %v", subject, err)
+		return false, fmt.Errorf("could not record TOTP step: %w", err)
+	}
+	return claimed == 1, nil
+}
+
+func actionTokenKey(purpose, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("action:%s:%s", purpose, hex.EncodeToString(sum[:]))
+}
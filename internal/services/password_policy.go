@@ -0,0 +1,35 @@
+package services
+
+import (
+	"fmt"
+	"unicode"
+)
+
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 72 // bcrypt ignores everything past 72 bytes
+)
+
+// ValidatePasswordPolicy checks a new password against the account password policy.
+// The returned error wraps ErrWeakPassword with a human-readable reason.
+func ValidatePasswordPolicy(password string) error {
+	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
+		return fmt.Errorf("%w: must be between %d and %d characters", ErrWeakPassword, passwordMinLength, passwordMaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasUpper || !hasLower || !hasDigit {
+		return fmt.Errorf("%w: must contain at least one uppercase letter, one lowercase letter and one digit", ErrWeakPassword)
+	}
+	return nil
+}
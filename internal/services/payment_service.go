@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/money"
 	"log"
 
 	"github.com/google/uuid" // Keep for simulated external transaction IDs
@@ -24,10 +25,10 @@ func NewPaymentService() PaymentService {
 }
 
 func (s *paymentService) InitiateDisbursement(ctx context.Context, req dtos.DisbursementRequest) (*dtos.DisbursementResponse, error) {
-	log.Printf("Attempting to initiate disbursement for Invoice ID %d, Amount: %.2f %s to Account: %s",
-		req.InvoiceID, req.Amount, req.Currency, req.BankAccountNumber)
+	log.Printf("Attempting to initiate disbursement for Invoice ID %d, Amount: %s %s to Account: %s",
+		req.InvoiceID, req.Amount.Format(req.Currency), req.Currency, req.BankAccountNumber)
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		return nil, errors.New("disbursement amount must be positive")
 	}
 
@@ -42,10 +43,10 @@ func (s *paymentService) InitiateDisbursement(ctx context.Context, req dtos.Disb
 }
 
 func (s *paymentService) ProcessRepayment(ctx context.Context, req dtos.RepaymentRequest) (*dtos.RepaymentResponse, error) {
-	log.Printf("Attempting to process repayment for Invoice ID %d, Amount: %.2f %s from User ID: %d",
-		req.InvoiceID, req.Amount, req.Currency, req.UserID)
+	log.Printf("Attempting to process repayment for Invoice ID %d, Amount: %s %s from User ID: %d",
+		req.InvoiceID, req.Amount.Format(req.Currency), req.Currency, req.UserID)
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		return nil, errors.New("repayment amount must be positive")
 	}
 	if req.PaymentMethodToken == "INVALID_TOKEN_FOR_SIMULATION" {
@@ -56,7 +57,11 @@ func (s *paymentService) ProcessRepayment(ctx context.Context, req dtos.Repaymen
 		}, nil
 	}
 
+	// A real provider returns the original charge for a repeated idempotency key
 	simulatedTransactionID := "REPAY_" + uuid.New().String()
+	if req.IdempotencyKey != "" {
+		simulatedTransactionID = "REPAY_" + uuid.NewSHA1(uuid.NameSpaceOID, []byte(req.IdempotencyKey)).String()
+	}
 	log.Printf("Simulated repayment successful. Transaction ID: %s", simulatedTransactionID)
 
 	return &dtos.RepaymentResponse{
@@ -90,7 +95,7 @@ func (s *paymentService) CheckPaymentStatus(ctx context.Context, transactionID s
 		TransactionID: transactionID,
 		Status:        status,
 		Message:       message,
-		Amount:        100.00,
+		Amount:        money.MustParse("100.00"),
 		Currency:      "USD",
 	}, nil
 }
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/repositories"
+	"sort"
+)
+
+var (
+	ErrUnknownStaffRole  = errors.New("unknown staff role")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrPermissionLockout = errors.New("the admin role must keep the roles:manage permission")
+)
+
+// PermissionService resolves and edits the permissions granted to each staff role.
+type PermissionService interface {
+	EnsureDefaults(ctx context.Context) error
+	PermissionsForRole(ctx context.Context, role string) (map[string]bool, error)
+	GetCatalogue() []dtos.PermissionResponse
+	GetRolePermissions(ctx context.Context) ([]dtos.RolePermissionsResponse, error)
+	UpdateRolePermissions(ctx context.Context, adminStaffID uint, role string, permissions []string) (*dtos.RolePermissionsResponse, error)
+}
+
+type permissionService struct {
+	rolePermissionRepo repositories.RolePermissionRepository
+	activityLogSvc     ActivityLogService
+}
+
+func NewPermissionService(rolePermissionRepo repositories.RolePermissionRepository, activityLogSvc ActivityLogService) PermissionService {
+	return &permissionService{
+		rolePermissionRepo: rolePermissionRepo,
+		activityLogSvc:     activityLogSvc,
+	}
+}
+
+// EnsureDefaults seeds models.DefaultRolePermissions on first start.
+func (s *permissionService) EnsureDefaults(ctx context.Context) error {
+	if _, err := s.rolePermissionRepo.SeedIfEmpty(ctx, models.DefaultRolePermissions); err != nil {
+		return fmt.Errorf("failed to seed default role permissions: %w", err)
+	}
+	return nil
+}
+
+func (s *permissionService) PermissionsForRole(ctx context.Context, role string) (map[string]bool, error) {
+	permissions, err := s.rolePermissionRepo.ListByRole(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions of role %s: %w", role, err)
+	}
+	granted := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+	return granted, nil
+}
+
+func (s *permissionService) GetCatalogue() []dtos.PermissionResponse {
+	catalogue := make([]dtos.PermissionResponse, 0, len(models.PermissionCatalogue))
+	for _, permission := range models.PermissionCatalogue {
+		catalogue = append(catalogue, dtos.PermissionResponse{Key: permission.Key, Description: permission.Description})
+	}
+	return catalogue
+}
+
+// GetRolePermissions returns every staff role with its permissions, including roles that
+// currently have none.
+func (s *permissionService) GetRolePermissions(ctx context.Context) ([]dtos.RolePermissionsResponse, error) {
+	grants, err := s.rolePermissionRepo.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	byRole := map[string][]string{}
+	for _, grant := range grants {
+		byRole[grant.Role] = append(byRole[grant.Role], grant.Permission)
+	}
+
+	roles := []string{models.RoleAdmin, models.RoleKYCReviewer, models.RoleFinanceManager}
+	responses := make([]dtos.RolePermissionsResponse, 0, len(roles))
+	for _, role := range roles {
+		permissions := byRole[role]
+		if permissions == nil {
+			permissions = []string{}
+		}
+		responses = append(responses, dtos.RolePermissionsResponse{Role: role, Permissions: permissions})
+	}
+	return responses, nil
+}
+
+// UpdateRolePermissions replaces the permissions of a role. Changes apply to the role's staff
+// on their next request.
+func (s *permissionService) UpdateRolePermissions(ctx context.Context, adminStaffID uint, role string, permissions []string) (*dtos.RolePermissionsResponse, error) {
+	if !models.IsStaffRole(role) {
+		return nil, ErrUnknownStaffRole
+	}
+	unique := make(map[string]bool, len(permissions))
+	normalised := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if !models.IsPermission(permission) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, permission)
+		}
+		if !unique[permission] {
+			unique[permission] = true
+			normalised = append(normalised, permission)
+		}
+	}
+	sort.Strings(normalised)
+	// Without this an admin could remove the last way to edit permissions at all
+	if role == models.RoleAdmin && !unique[models.PermRolesManage] {
+		return nil, ErrPermissionLockout
+	}
+
+	previous, err := s.rolePermissionRepo.ListByRole(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions of role %s: %w", role, err)
+	}
+	if err := s.rolePermissionRepo.ReplaceForRole(ctx, role, normalised); err != nil {
+		return nil, fmt.Errorf("failed to update permissions of role %s: %w", role, err)
+	}
+
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_ROLE_PERMISSIONS_UPDATED", map[string]interface{}{
+		"role": role, "from": previous, "to": normalised,
+	}, "")
+	return &dtos.RolePermissionsResponse{Role: role, Permissions: normalised}, nil
+}
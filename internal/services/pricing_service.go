@@ -0,0 +1,435 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invoiceB2B/internal/config"
+	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
+	"invoiceB2B/internal/repositories"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoPricingPlan          = errors.New("no pricing plan covers this risk tier and currency")
+	ErrPricingInputIncomplete = errors.New("an amount, currency and due date are required for pricing")
+	ErrPricingNoNetPayout     = errors.New("fees exceed the advance, nothing would be disbursed")
+	ErrPricingPlanNotFound    = errors.New("pricing plan not found")
+	ErrDebtorRatingNotFound   = errors.New("debtor risk rating not found")
+	ErrOrganizationNotFound   = errors.New("organization not found")
+	ErrQuoteDueDatePassed     = errors.New("the due date must be after today")
+)
+
+// daysPerYear is the day count convention for discount fees (actual/365).
+var daysPerYear = decimal.NewFromInt(365)
+
+// PricingInput describes what to price. Override, if set, replaces some of the plan's terms.
+type PricingInput struct {
+	OrganizationID *uint
+	DebtorName     string
+	Amount         money.Amount
+	Currency       string
+	DueDate        *time.Time
+	Override       *dtos.PricingOverride
+}
+
+// PricingService prices invoice financing. The risk tier of an invoice is the riskier of its
+// customer's and its debtor's tier; the pricing plan for that tier and the invoice currency
+// then sets the advance rate, the annual discount rate charged until the due date and a flat
+// fee. The service also manages plans and risk tiers for admins.
+type PricingService interface {
+	Price(ctx context.Context, input PricingInput) (*models.PricingBreakdown, error)
+	PriceInvoice(ctx context.Context, invoice *models.Invoice, override *dtos.PricingOverride) (*models.PricingBreakdown, error)
+
+	ListPlans(ctx context.Context) ([]dtos.PricingPlanResponse, error)
+	SetPlan(ctx context.Context, adminStaffID uint, req dtos.SetPricingPlanRequest) (*dtos.PricingPlanResponse, error)
+	DeletePlan(ctx context.Context, adminStaffID, planID uint) error
+
+	ListDebtorRatings(ctx context.Context) ([]dtos.DebtorRiskRatingResponse, error)
+	SetDebtorRating(ctx context.Context, adminStaffID uint, req dtos.SetDebtorRiskRatingRequest) (*dtos.DebtorRiskRatingResponse, error)
+	DeleteDebtorRating(ctx context.Context, adminStaffID, ratingID uint) error
+
+	SetOrganizationRiskTier(ctx context.Context, adminStaffID, organizationID uint, riskTier string) error
+
+	Quote(ctx context.Context, userID uint, input PricingInput) (*models.FinancingQuote, error)
+	QuoteInvoice(ctx context.Context, userID uint, invoice *models.Invoice, persist bool) (*models.FinancingQuote, error)
+}
+
+type pricingService struct {
+	pricingRepo    repositories.PricingRepository
+	orgRepo        repositories.OrganizationRepository
+	activityLogSvc ActivityLogService
+	cfg            *config.Config
+}
+
+func NewPricingService(pricingRepo repositories.PricingRepository, orgRepo repositories.OrganizationRepository, activityLogSvc ActivityLogService, cfg *config.Config) PricingService {
+	return &pricingService{pricingRepo: pricingRepo, orgRepo: orgRepo, activityLogSvc: activityLogSvc, cfg: cfg}
+}
+
+func (s *pricingService) Price(ctx context.Context, input PricingInput) (*models.PricingBreakdown, error) {
+	currency := strings.ToUpper(strings.TrimSpace(input.Currency))
+	if !input.Amount.IsPositive() || len(currency) != 3 || input.DueDate == nil {
+		return nil, ErrPricingInputIncomplete
+	}
+
+	riskTier, err := s.riskTier(ctx, input.OrganizationID, input.DebtorName)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.pricingRepo.FindPlan(ctx, riskTier, currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoPricingPlan
+		}
+		return nil, fmt.Errorf("failed to load pricing plan: %w", err)
+	}
+
+	terms := *plan
+	if override := input.Override; override != nil {
+		if override.AdvanceRatePercentage != nil {
+			terms.AdvanceRatePercentage = *override.AdvanceRatePercentage
+		}
+		if override.DiscountRatePercentage != nil {
+			terms.DiscountRatePercentage = *override.DiscountRatePercentage
+		}
+		if override.FlatFee != nil {
+			terms.FlatFee = *override.FlatFee
+		}
+	}
+
+	pricing := calculatePricing(terms, input.Amount, currency, *input.DueDate, time.Now())
+	pricing.PlanID = &plan.ID
+	pricing.RiskTier = riskTier
+	if input.Override != nil {
+		reason := input.Override.Reason
+		pricing.Overridden = true
+		pricing.OverrideReason = &reason
+	}
+	if !pricing.NetDisbursement.IsPositive() {
+		return nil, ErrPricingNoNetPayout
+	}
+	return &pricing, nil
+}
+
+func (s *pricingService) PriceInvoice(ctx context.Context, invoice *models.Invoice, override *dtos.PricingOverride) (*models.PricingBreakdown, error) {
+	return s.Price(ctx, PricingInput{
+		OrganizationID: invoice.OrganizationID,
+		DebtorName:     invoice.DebtorName,
+		Amount:         invoice.Amount,
+		Currency:       invoice.Currency,
+		DueDate:        invoice.DueDate,
+		Override:       override,
+	})
+}
+
+// Quote prices the input on the current plans and records the result as a quote that stays
+// valid for the configured window. Quotes never carry overrides.
+func (s *pricingService) Quote(ctx context.Context, userID uint, input PricingInput) (*models.FinancingQuote, error) {
+	return s.createQuote(ctx, userID, nil, input, true)
+}
+
+// QuoteInvoice returns the invoice's newest quote while it is valid and the invoice terms are
+// unchanged, and otherwise quotes the invoice afresh. Without persist a fresh quote is only
+// priced, not saved or logged, and has no ID.
+func (s *pricingService) QuoteInvoice(ctx context.Context, userID uint, invoice *models.Invoice, persist bool) (*models.FinancingQuote, error) {
+	existing, err := s.pricingRepo.FindLatestInvoiceQuote(ctx, invoice.ID, time.Now())
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load financing quote: %w", err)
+	}
+	if existing != nil && invoice.DueDate != nil &&
+		existing.Matches(invoice.DebtorName, invoice.Amount, invoice.Currency, *invoice.DueDate) {
+		return existing, nil
+	}
+	return s.createQuote(ctx, userID, &invoice.ID, PricingInput{
+		OrganizationID: invoice.OrganizationID,
+		DebtorName:     invoice.DebtorName,
+		Amount:         invoice.Amount,
+		Currency:       invoice.Currency,
+		DueDate:        invoice.DueDate,
+	}, persist)
+}
+
+func (s *pricingService) createQuote(ctx context.Context, userID uint, invoiceID *uint, input PricingInput, persist bool) (*models.FinancingQuote, error) {
+	input.Override = nil
+	if input.DueDate != nil && daysUntil(*input.DueDate, time.Now()) == 0 {
+		return nil, ErrQuoteDueDatePassed
+	}
+	pricing, err := s.Price(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &models.FinancingQuote{
+		UserID:         userID,
+		OrganizationID: input.OrganizationID,
+		InvoiceID:      invoiceID,
+		DebtorName:     strings.TrimSpace(input.DebtorName),
+		Amount:         input.Amount,
+		Currency:       strings.ToUpper(strings.TrimSpace(input.Currency)),
+		DueDate:        *input.DueDate,
+		Pricing:        *pricing,
+		ExpiresAt:      pricing.PricedAt.Add(s.cfg.FinancingQuoteValidity),
+	}
+	if !persist {
+		quote.CreatedAt = *pricing.PricedAt
+		return quote, nil
+	}
+	if err := s.pricingRepo.CreateQuote(ctx, quote); err != nil {
+		return nil, fmt.Errorf("failed to save financing quote: %w", err)
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, nil, &userID, "FINANCING_QUOTE_CREATED", map[string]interface{}{
+		"quote_id":         quote.ID,
+		"invoice_id":       invoiceID,
+		"amount":           quote.Amount,
+		"currency":         quote.Currency,
+		"net_disbursement": pricing.NetDisbursement,
+	}, "")
+	return quote, nil
+}
+
+// riskTier returns the riskier of the customer's tier and the debtor's tier. A customer
+// without an organisation counts as medium; an unrated debtor leaves the customer's tier.
+func (s *pricingService) riskTier(ctx context.Context, organizationID *uint, debtorName string) (string, error) {
+	tier := models.RiskTierMedium
+	if organizationID != nil {
+		org, err := s.orgRepo.FindByID(ctx, *organizationID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("failed to load organization risk tier: %w", err)
+		}
+		if org != nil && models.IsRiskTier(org.RiskTier) {
+			tier = org.RiskTier
+		}
+	}
+	if strings.TrimSpace(debtorName) != "" {
+		rating, err := s.pricingRepo.FindDebtorRating(ctx, debtorName)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("failed to load debtor risk rating: %w", err)
+		}
+		if rating != nil {
+			tier = models.HigherRiskTier(tier, rating.RiskTier)
+		}
+	}
+	return tier, nil
+}
+
+// calculatePricing applies a plan's terms to an amount. The advance is the advance rate of
+// the amount; the discount fee is the annual discount rate on the advance for the days until
+// the due date (at least the plan's minimum); the flat fee is added on top. Every amount is
+// rounded to the currency's minor unit.
+func calculatePricing(plan models.PricingPlan, amount money.Amount, currency string, dueDate, asOf time.Time) models.PricingBreakdown {
+	days := daysUntil(dueDate, asOf)
+	if days < plan.MinimumDays {
+		days = plan.MinimumDays
+	}
+
+	advance := amount.Percent(plan.AdvanceRatePercentage).Round(currency)
+	discountFee := advance.Percent(plan.DiscountRatePercentage).
+		Mul(decimal.NewFromInt(int64(days)).Div(daysPerYear)).
+		Round(currency)
+	flatFee := plan.FlatFee.Round(currency)
+
+	return models.PricingBreakdown{
+		AdvanceRatePercentage:  plan.AdvanceRatePercentage,
+		DiscountRatePercentage: plan.DiscountRatePercentage,
+		DiscountDays:           days,
+		AdvanceAmount:          advance,
+		DiscountFee:            discountFee,
+		FlatFee:                flatFee,
+		NetDisbursement:        advance.Sub(discountFee).Sub(flatFee),
+		PricedAt:               &asOf,
+	}
+}
+
+// daysUntil counts calendar days from asOf to dueDate, never less than zero.
+func daysUntil(dueDate, asOf time.Time) int {
+	due := time.Date(dueDate.Year(), dueDate.Month(), dueDate.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(due.Sub(today).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
+// effectiveFeePercentage expresses the total fees as a percentage of the advance.
+func effectiveFeePercentage(pricing *models.PricingBreakdown) decimal.Decimal {
+	if pricing.AdvanceAmount.IsZero() {
+		return decimal.Zero
+	}
+	return pricing.TotalFees().Decimal().Div(pricing.AdvanceAmount.Decimal()).Mul(decimal.NewFromInt(100)).Round(2)
+}
+
+// mapPricingBreakdown returns nil for an invoice that has not been priced.
+func mapPricingBreakdown(pricing models.PricingBreakdown, currency string) *dtos.PricingBreakdownResponse {
+	if pricing.PricedAt == nil {
+		return nil
+	}
+	return &dtos.PricingBreakdownResponse{
+		PlanID:                 pricing.PlanID,
+		RiskTier:               pricing.RiskTier,
+		AdvanceRatePercentage:  pricing.AdvanceRatePercentage,
+		DiscountRatePercentage: pricing.DiscountRatePercentage,
+		DiscountDays:           pricing.DiscountDays,
+		AdvanceAmount:          pricing.AdvanceAmount.Round(currency),
+		DiscountFee:            pricing.DiscountFee.Round(currency),
+		FlatFee:                pricing.FlatFee.Round(currency),
+		TotalFees:              pricing.TotalFees().Round(currency),
+		NetDisbursement:        pricing.NetDisbursement.Round(currency),
+		Overridden:             pricing.Overridden,
+		OverrideReason:         pricing.OverrideReason,
+		PricedAt:               pricing.PricedAt,
+	}
+}
+
+func mapFinancingQuote(quote *models.FinancingQuote) *dtos.FinancingQuoteResponse {
+	pricing := quote.Pricing
+	currency := quote.Currency
+	return &dtos.FinancingQuoteResponse{
+		ID:                     quote.ID,
+		InvoiceID:              quote.InvoiceID,
+		DebtorName:             quote.DebtorName,
+		Amount:                 quote.Amount.Round(currency),
+		Currency:               currency,
+		AdvanceRatePercentage:  pricing.AdvanceRatePercentage,
+		AdvanceAmount:          pricing.AdvanceAmount.Round(currency),
+		DiscountRatePercentage: pricing.DiscountRatePercentage,
+		DiscountDays:           pricing.DiscountDays,
+		DiscountFee:            pricing.DiscountFee.Round(currency),
+		FlatFee:                pricing.FlatFee.Round(currency),
+		TotalFees:              pricing.TotalFees().Round(currency),
+		NetDisbursement:        pricing.NetDisbursement.Round(currency),
+		RepaymentDate:          quote.DueDate,
+		RepaymentAmount:        pricing.AdvanceAmount.Round(currency),
+		CreatedAt:              quote.CreatedAt,
+		ExpiresAt:              quote.ExpiresAt,
+	}
+}
+
+func mapPricingPlan(plan *models.PricingPlan) dtos.PricingPlanResponse {
+	return dtos.PricingPlanResponse{
+		ID:                     plan.ID,
+		Name:                   plan.Name,
+		RiskTier:               plan.RiskTier,
+		Currency:               plan.Currency,
+		AdvanceRatePercentage:  plan.AdvanceRatePercentage,
+		DiscountRatePercentage: plan.DiscountRatePercentage,
+		MinimumDays:            plan.MinimumDays,
+		FlatFee:                plan.FlatFee.Round(plan.Currency),
+		UpdatedAt:              plan.UpdatedAt,
+	}
+}
+
+func (s *pricingService) ListPlans(ctx context.Context) ([]dtos.PricingPlanResponse, error) {
+	plans, err := s.pricingRepo.ListPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pricing plans: %w", err)
+	}
+	responses := make([]dtos.PricingPlanResponse, 0, len(plans))
+	for i := range plans {
+		responses = append(responses, mapPricingPlan(&plans[i]))
+	}
+	return responses, nil
+}
+
+// SetPlan creates or replaces the plan for a risk tier and currency. Invoices that were
+// already disbursed keep the terms stored with them.
+func (s *pricingService) SetPlan(ctx context.Context, adminStaffID uint, req dtos.SetPricingPlanRequest) (*dtos.PricingPlanResponse, error) {
+	currency := strings.ToUpper(req.Currency)
+	plan := &models.PricingPlan{
+		Name:                   strings.TrimSpace(req.Name),
+		RiskTier:               req.RiskTier,
+		Currency:               currency,
+		AdvanceRatePercentage:  req.AdvanceRatePercentage,
+		DiscountRatePercentage: req.DiscountRatePercentage,
+		MinimumDays:            req.MinimumDays,
+		FlatFee:                req.FlatFee.Round(currency),
+	}
+	if err := s.pricingRepo.UpsertPlan(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to save pricing plan: %w", err)
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_PRICING_PLAN_SET", map[string]interface{}{
+		"pricing_plan_id":          plan.ID,
+		"risk_tier":                plan.RiskTier,
+		"currency":                 plan.Currency,
+		"advance_rate_percentage":  plan.AdvanceRatePercentage,
+		"discount_rate_percentage": plan.DiscountRatePercentage,
+		"minimum_days":             plan.MinimumDays,
+		"flat_fee":                 plan.FlatFee,
+	}, "")
+	resp := mapPricingPlan(plan)
+	return &resp, nil
+}
+
+func (s *pricingService) DeletePlan(ctx context.Context, adminStaffID, planID uint) error {
+	deleted, err := s.pricingRepo.DeletePlan(ctx, planID)
+	if err != nil {
+		return fmt.Errorf("failed to delete pricing plan: %w", err)
+	}
+	if !deleted {
+		return ErrPricingPlanNotFound
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_PRICING_PLAN_DELETED", map[string]interface{}{
+		"pricing_plan_id": planID,
+	}, "")
+	return nil
+}
+
+func (s *pricingService) ListDebtorRatings(ctx context.Context) ([]dtos.DebtorRiskRatingResponse, error) {
+	ratings, err := s.pricingRepo.ListDebtorRatings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get debtor risk ratings: %w", err)
+	}
+	responses := make([]dtos.DebtorRiskRatingResponse, 0, len(ratings))
+	for _, rating := range ratings {
+		responses = append(responses, dtos.DebtorRiskRatingResponse{ID: rating.ID, DebtorName: rating.DebtorName, RiskTier: rating.RiskTier, UpdatedAt: rating.UpdatedAt})
+	}
+	return responses, nil
+}
+
+func (s *pricingService) SetDebtorRating(ctx context.Context, adminStaffID uint, req dtos.SetDebtorRiskRatingRequest) (*dtos.DebtorRiskRatingResponse, error) {
+	rating := &models.DebtorRiskRating{DebtorName: req.DebtorName, RiskTier: req.RiskTier}
+	if err := s.pricingRepo.UpsertDebtorRating(ctx, rating); err != nil {
+		return nil, fmt.Errorf("failed to save debtor risk rating: %w", err)
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_DEBTOR_RISK_TIER_SET", map[string]interface{}{
+		"debtor_rating_id": rating.ID,
+		"debtor_name":      rating.DebtorName,
+		"risk_tier":        rating.RiskTier,
+	}, "")
+	return &dtos.DebtorRiskRatingResponse{ID: rating.ID, DebtorName: rating.DebtorName, RiskTier: rating.RiskTier, UpdatedAt: rating.UpdatedAt}, nil
+}
+
+func (s *pricingService) DeleteDebtorRating(ctx context.Context, adminStaffID, ratingID uint) error {
+	deleted, err := s.pricingRepo.DeleteDebtorRating(ctx, ratingID)
+	if err != nil {
+		return fmt.Errorf("failed to delete debtor risk rating: %w", err)
+	}
+	if !deleted {
+		return ErrDebtorRatingNotFound
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_DEBTOR_RISK_TIER_DELETED", map[string]interface{}{
+		"debtor_rating_id": ratingID,
+	}, "")
+	return nil
+}
+
+func (s *pricingService) SetOrganizationRiskTier(ctx context.Context, adminStaffID, organizationID uint, riskTier string) error {
+	updated, err := s.orgRepo.UpdateRiskTier(ctx, organizationID, riskTier)
+	if err != nil {
+		return fmt.Errorf("failed to update organization risk tier: %w", err)
+	}
+	if !updated {
+		return ErrOrganizationNotFound
+	}
+	_ = s.activityLogSvc.LogActivity(ctx, &adminStaffID, nil, "ADMIN_ORGANIZATION_RISK_TIER_SET", map[string]interface{}{
+		"organization_id": organizationID,
+		"risk_tier":       riskTier,
+	}, "")
+	return nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"invoiceB2B/internal/dtos"
+	"invoiceB2B/internal/models"
+	"invoiceB2B/internal/money"
+	"invoiceB2B/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func testPlan() models.PricingPlan {
+	plan := models.PricingPlan{
+		RiskTier:               models.RiskTierMedium,
+		Currency:               "USD",
+		AdvanceRatePercentage:  decimal.NewFromInt(80),
+		DiscountRatePercentage: decimal.NewFromInt(12),
+		MinimumDays:            10,
+		FlatFee:                money.FromInt(25),
+	}
+	plan.ID = 3
+	return plan
+}
+
+func TestDaysUntil(t *testing.T) {
+	asOf := time.Date(2026, time.January, 1, 18, 45, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		dueDate time.Time
+		want    int
+	}{
+		{"later this year", date(2026, time.March, 2), 60},
+		{"tomorrow", date(2026, time.January, 2), 1},
+		{"today", date(2026, time.January, 1), 0},
+		{"time of day is ignored", time.Date(2026, time.January, 2, 0, 5, 0, 0, time.UTC), 1},
+		{"past due date", date(2025, time.December, 1), 0},
+	}
+	for _, tt := range tests {
+		if got := daysUntil(tt.dueDate, asOf); got != tt.want {
+			t.Errorf("%s: daysUntil(%s) = %d, want %d", tt.name, tt.dueDate.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestCalculatePricing(t *testing.T) {
+	asOf := date(2026, time.January, 1)
+	noMinimum := testPlan()
+	noMinimum.MinimumDays = 0
+	jpyPlan := testPlan()
+	jpyPlan.Currency = "JPY"
+	jpyPlan.FlatFee = money.MustParse("500.5")
+
+	tests := []struct {
+		name        string
+		plan        models.PricingPlan
+		amount      string
+		currency    string
+		dueDate     time.Time
+		wantDays    int
+		wantAdvance string
+		wantFee     string
+		wantFlatFee string
+		wantNet     string
+	}{
+		{"discount for the days until due", testPlan(), "10000", "USD", date(2026, time.March, 2), 60, "8000", "157.81", "25", "7817.19"},
+		{"minimum days floor", testPlan(), "10000", "USD", date(2026, time.January, 5), 10, "8000", "26.30", "25", "7948.70"},
+		{"past due date", noMinimum, "10000", "USD", date(2025, time.December, 1), 0, "8000", "0", "25", "7975"},
+		{"zero-decimal currency", jpyPlan, "1000001", "JPY", date(2026, time.March, 2), 60, "800001", "15781", "501", "783719"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculatePricing(tt.plan, money.MustParse(tt.amount), tt.currency, tt.dueDate, asOf)
+			if got.DiscountDays != tt.wantDays {
+				t.Errorf("DiscountDays = %d, want %d", got.DiscountDays, tt.wantDays)
+			}
+			for _, check := range []struct {
+				field string
+				got   money.Amount
+				want  string
+			}{
+				{"AdvanceAmount", got.AdvanceAmount, tt.wantAdvance},
+				{"DiscountFee", got.DiscountFee, tt.wantFee},
+				{"FlatFee", got.FlatFee, tt.wantFlatFee},
+				{"NetDisbursement", got.NetDisbursement, tt.wantNet},
+			} {
+				if !check.got.Equal(money.MustParse(check.want)) {
+					t.Errorf("%s = %s, want %s", check.field, check.got, check.want)
+				}
+			}
+		})
+	}
+}
+
+type stubPricingRepository struct {
+	repositories.PricingRepository
+	plan models.PricingPlan
+}
+
+func (r stubPricingRepository) FindPlan(ctx context.Context, riskTier, currency string) (*models.PricingPlan, error) {
+	if riskTier != r.plan.RiskTier || currency != r.plan.Currency {
+		return nil, gorm.ErrRecordNotFound
+	}
+	plan := r.plan
+	return &plan, nil
+}
+
+func (r stubPricingRepository) FindDebtorRating(ctx context.Context, debtorName string) (*models.DebtorRiskRating, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func priceWithPlan(plan models.PricingPlan, override *dtos.PricingOverride) (*models.PricingBreakdown, error) {
+	svc := NewPricingService(stubPricingRepository{plan: plan}, nil, nil, nil)
+	dueDate := time.Now().AddDate(0, 0, 30)
+	return svc.Price(context.Background(), PricingInput{
+		DebtorName: "Acme Ltd",
+		Amount:     money.FromInt(10000),
+		Currency:   "usd",
+		DueDate:    &dueDate,
+		Override:   override,
+	})
+}
+
+func TestPriceRefusesFeesExceedingTheAdvance(t *testing.T) {
+	plan := testPlan()
+	plan.FlatFee = money.FromInt(8000)
+	if _, err := priceWithPlan(plan, nil); !errors.Is(err, ErrPricingNoNetPayout) {
+		t.Fatalf("Price() error = %v, want ErrPricingNoNetPayout", err)
+	}
+}
+
+func TestPriceAppliesOverride(t *testing.T) {
+	advanceRate := decimal.NewFromInt(50)
+	flatFee := money.Zero
+	override := &dtos.PricingOverride{AdvanceRatePercentage: &advanceRate, FlatFee: &flatFee, Reason: "Long-standing customer"}
+
+	pricing, err := priceWithPlan(testPlan(), override)
+	if err != nil {
+		t.Fatalf("Price() failed: %v", err)
+	}
+	if !pricing.Overridden || pricing.OverrideReason == nil || *pricing.OverrideReason != override.Reason {
+		t.Errorf("Overridden = %v, OverrideReason = %v, want the override recorded", pricing.Overridden, pricing.OverrideReason)
+	}
+	if !pricing.AdvanceRatePercentage.Equal(advanceRate) || !pricing.AdvanceAmount.Equal(money.FromInt(5000)) {
+		t.Errorf("advance = %s%% / %s, want 50%% / 5000", pricing.AdvanceRatePercentage, pricing.AdvanceAmount)
+	}
+	if !pricing.FlatFee.IsZero() {
+		t.Errorf("FlatFee = %s, want 0", pricing.FlatFee)
+	}
+	if !pricing.DiscountRatePercentage.Equal(decimal.NewFromInt(12)) {
+		t.Errorf("DiscountRatePercentage = %s, want the plan's 12", pricing.DiscountRatePercentage)
+	}
+	if pricing.PlanID == nil || *pricing.PlanID != 3 {
+		t.Errorf("PlanID = %v, want 3", pricing.PlanID)
+	}
+
+	plain, err := priceWithPlan(testPlan(), nil)
+	if err != nil {
+		t.Fatalf("Price() without override failed: %v", err)
+	}
+	if plain.Overridden || plain.OverrideReason != nil {
+		t.Errorf("pricing without override is marked overridden")
+	}
+}
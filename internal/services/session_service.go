@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrSessionRevoked       = errors.New("session has been revoked or has expired")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used; session revoked")
+	ErrFailedToStartSession = errors.New("failed to start session")
+)
+
+// rotateRefreshTokenScript atomically swaps the family's current refresh token ID.
+// Returns 1 on success, 0 if the family no longer exists and -1 if the presented
+// token is not the current one (a replay), in which case the family is deleted.
+var rotateRefreshTokenScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'current')
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'current', ARGV[2], 'last_seen_at', ARGV[4])
+if ARGV[5] ~= '' then
+	redis.call('HSET', KEYS[1], 'ip_address', ARGV[5])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionInfo is the registry entry for one login session (refresh-token family).
+type SessionInfo struct {
+	ID         string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// SessionService tracks refresh-token families in Redis. Every login starts a family whose ID
+// is the jti of its first refresh token; each refresh rotates it to a new one-time token ID,
+// and replaying an old token revokes the family. The family doubles as the session registry entry.
+type SessionService interface {
+	StartFamily(ctx context.Context, subject string, client ClientInfo) (familyID string, tokenID string, err error)
+	StartShortLived(ctx context.Context, subject string, client ClientInfo, ttl time.Duration) (familyID string, err error)
+	Rotate(ctx context.Context, familyID, presentedTokenID string, client ClientInfo) (string, error)
+	IsActive(ctx context.Context, familyID string) (bool, error)
+	ListForSubject(ctx context.Context, subject string) ([]SessionInfo, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeForSubject(ctx context.Context, subject, familyID string) (bool, error)
+	RevokeAllForSubject(ctx context.Context, subject string) error
+}
+
+type sessionService struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// NewSessionService creates a SessionService; ttl should match the refresh token lifetime.
+func NewSessionService(rdb *redis.Client, ttl time.Duration) SessionService {
+	return &sessionService{rdb: rdb, ttl: ttl}
+}
+
+func familyKey(familyID string) string {
+	return fmt.Sprintf("session:family:%s", familyID)
+}
+
+func subjectSessionsKey(subject string) string {
+	return fmt.Sprintf("session:subject:%s", subject)
+}
+
+func newSessionTokenID() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("failed to generate session identifier: %w", err)
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+func (s *sessionService) StartFamily(ctx context.Context, subject string, client ClientInfo) (string, string, error) {
+	return s.startFamily(ctx, subject, client, s.ttl)
+}
+
+// StartShortLived registers a session that expires after ttl and is never refreshed, such as
+// an impersonation view.
+func (s *sessionService) StartShortLived(ctx context.Context, subject string, client ClientInfo, ttl time.Duration) (string, error) {
+	familyID, _, err := s.startFamily(ctx, subject, client, ttl)
+	return familyID, err
+}
+
+func (s *sessionService) startFamily(ctx context.Context, subject string, client ClientInfo, ttl time.Duration) (string, string, error) {
+	tokenID, err := newSessionTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	familyID := tokenID // The session is identified by the jti issued at login
+
+	now := time.Now().Unix()
+	pipe := s.rdb.TxPipeline()
+	pipe.HSet(ctx, familyKey(familyID),
+		"subject", subject, "current", tokenID,
+		"user_agent", truncate(client.UserAgent, maxUserAgentLength), "ip_address", client.IPAddress,
+		"created_at", now, "last_seen_at", now)
+	pipe.Expire(ctx, familyKey(familyID), ttl)
+	pipe.SAdd(ctx, subjectSessionsKey(subject), familyID)
+	// The index must outlive the subject's regular sessions, so short-lived ones never shorten it
+	pipe.Expire(ctx, subjectSessionsKey(subject), maxDuration(ttl, s.ttl))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to start session family for %s: %v", subject, err)
+		return "", "", fmt.Errorf("%w: %v", ErrFailedToStartSession, err)
+	}
+	return familyID, tokenID, nil
+}
+
+// Rotate consumes the presented refresh token ID and returns the next one. It returns
+// ErrRefreshTokenReused if the token was already rotated away (the family is then revoked)
+// and ErrSessionRevoked if the family no longer exists.
+func (s *sessionService) Rotate(ctx context.Context, familyID, presentedTokenID string, client ClientInfo) (string, error) {
+	nextTokenID, err := newSessionTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := rotateRefreshTokenScript.Run(ctx, s.rdb, []string{familyKey(familyID)},
+		presentedTokenID, nextTokenID, s.ttl.Milliseconds(), time.Now().Unix(), client.IPAddress).Int()
+	if err != nil {
+		log.Printf("Failed to rotate session family %s: %v", familyID, err)
+		return "", fmt.Errorf("could not rotate refresh token: %w", err)
+	}
+
+	switch result {
+	case 1:
+		return nextTokenID, nil
+	case -1:
+		return "", ErrRefreshTokenReused
+	default:
+		return "", ErrSessionRevoked
+	}
+}
+
+func (s *sessionService) IsActive(ctx context.Context, familyID string) (bool, error) {
+	exists, err := s.rdb.Exists(ctx, familyKey(familyID)).Result()
+	if err != nil {
+		log.Printf("Error checking session family %s: %v", familyID, err)
+		return false, fmt.Errorf("could not check session: %w", err)
+	}
+	return exists == 1, nil
+}
+
+// ListForSubject returns the live sessions of a user or staff account, newest first.
+// Expired families are pruned from the subject index as a side effect.
+func (s *sessionService) ListForSubject(ctx context.Context, subject string) ([]SessionInfo, error) {
+	familyIDs, err := s.rdb.SMembers(ctx, subjectSessionsKey(subject)).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to list sessions for %s: %v", subject, err)
+		return nil, fmt.Errorf("could not list sessions: %w", err)
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(familyIDs))
+	for i, familyID := range familyIDs {
+		cmds[i] = pipe.HGetAll(ctx, familyKey(familyID))
+	}
+	if len(familyIDs) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			log.Printf("Failed to load sessions for %s: %v", subject, err)
+			return nil, fmt.Errorf("could not load sessions: %w", err)
+		}
+	}
+
+	sessions := make([]SessionInfo, 0, len(familyIDs))
+	var stale []interface{}
+	for i, familyID := range familyIDs {
+		fields := cmds[i].Val()
+		if len(fields) == 0 || fields["subject"] != subject {
+			stale = append(stale, familyID)
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:         familyID,
+			UserAgent:  fields["user_agent"],
+			IPAddress:  fields["ip_address"],
+			CreatedAt:  unixField(fields["created_at"]),
+			LastSeenAt: unixField(fields["last_seen_at"]),
+		})
+	}
+	if len(stale) > 0 {
+		_ = s.rdb.SRem(ctx, subjectSessionsKey(subject), stale...).Err()
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+// RevokeForSubject ends one session, but only if it belongs to the subject. It returns false
+// if no such session exists for that account.
+func (s *sessionService) RevokeForSubject(ctx context.Context, subject, familyID string) (bool, error) {
+	owner, err := s.rdb.HGet(ctx, familyKey(familyID), "subject").Result()
+	if err == redis.Nil || (err == nil && owner != subject) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Failed to look up session %s: %v", familyID, err)
+		return false, fmt.Errorf("could not look up session: %w", err)
+	}
+
+	if err := s.RevokeFamily(ctx, familyID); err != nil {
+		return false, err
+	}
+	_ = s.rdb.SRem(ctx, subjectSessionsKey(subject), familyID).Err()
+	return true, nil
+}
+
+func (s *sessionService) RevokeFamily(ctx context.Context, familyID string) error {
+	if err := s.rdb.Del(ctx, familyKey(familyID)).Err(); err != nil && err != redis.Nil {
+		log.Printf("Failed to revoke session family %s: %v", familyID, err)
+		return fmt.Errorf("could not revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllForSubject ends every session belonging to a user or staff account.
+func (s *sessionService) RevokeAllForSubject(ctx context.Context, subject string) error {
+	familyIDs, err := s.rdb.SMembers(ctx, subjectSessionsKey(subject)).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to list sessions for %s: %v", subject, err)
+		return fmt.Errorf("could not list sessions: %w", err)
+	}
+
+	keys := make([]string, 0, len(familyIDs)+1)
+	for _, familyID := range familyIDs {
+		keys = append(keys, familyKey(familyID))
+	}
+	keys = append(keys, subjectSessionsKey(subject))
+	if err := s.rdb.Del(ctx, keys...).Err(); err != nil && err != redis.Nil {
+		log.Printf("Failed to revoke sessions for %s: %v", subject, err)
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+	return nil
+}
+
+const maxUserAgentLength = 255
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func unixField(value string) time.Time {
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -13,6 +13,8 @@ import (
 	"gorm.io/gorm"
 )
 
+var ErrKYCNotSubmitted = errors.New("KYC record not found for this organization")
+
 type UserService interface {
 	GetUserProfile(ctx context.Context, userID uint) (*models.User, error)
 	UpdateUserProfile(ctx context.Context, userID uint, req dtos.UpdateUserProfileRequest) (*models.User, error)
@@ -23,13 +25,15 @@ type UserService interface {
 type userService struct {
 	userRepo           repositories.UserRepository
 	kycRepo            repositories.KYCRepository
+	orgService         OrganizationService
 	activityLogService ActivityLogService
 }
 
-func NewUserService(userRepo repositories.UserRepository, kycRepo repositories.KYCRepository, activityLogService ActivityLogService) UserService {
+func NewUserService(userRepo repositories.UserRepository, kycRepo repositories.KYCRepository, orgService OrganizationService, activityLogService ActivityLogService) UserService {
 	return &userService{
 		userRepo:           userRepo,
 		kycRepo:            kycRepo,
+		orgService:         orgService,
 		activityLogService: activityLogService, // Added
 	}
 }
@@ -85,21 +89,34 @@ func (s *userService) SubmitOrUpdateKYC(ctx context.Context, userID uint, req dt
 		log.Printf("SubmitOrUpdateKYC: User not found for ID %d: %v", userID, err)
 		return nil, ErrUserNotFound
 	}
+	if !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	// KYC belongs to the organisation; only its owners may submit it
+	member, err := s.orgService.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role != models.OrgRoleOwner {
+		return nil, ErrOrganizationPermissionDenied
+	}
 
-	kycDetail, err := s.kycRepo.FindByUserID(ctx, userID)
+	kycDetail, err := s.kycRepo.FindByOrganizationID(ctx, member.OrganizationID)
 	isNewSubmission := false
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			isNewSubmission = true
 			kycDetail = &models.KYCDetail{
-				UserID: userID,
+				OrganizationID: &member.OrganizationID,
 			}
 		} else {
-			log.Printf("SubmitOrUpdateKYC: Error finding KYC for user %d: %v", userID, err)
+			log.Printf("SubmitOrUpdateKYC: Error finding KYC for organization %d: %v", member.OrganizationID, err)
 			return nil, fmt.Errorf("could not retrieve existing KYC details: %w", err)
 		}
 	}
 
+	kycDetail.UserID = userID // The member submitting on the organisation's behalf
 	kycDetail.DocumentsInfo = req.DocumentsInfo
 	kycDetail.Status = models.KYCPending
 	now := time.Now()
@@ -137,13 +154,18 @@ func (s *userService) SubmitOrUpdateKYC(ctx context.Context, userID uint, req dt
 	return updatedKYC, nil
 }
 
+// GetKYCStatus returns the KYC record of the user's organisation.
 func (s *userService) GetKYCStatus(ctx context.Context, userID uint) (*models.KYCDetail, error) {
-	kycDetail, err := s.kycRepo.FindByUserID(ctx, userID)
+	member, err := s.orgService.GetMembership(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	kycDetail, err := s.kycRepo.FindByOrganizationID(ctx, member.OrganizationID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("KYC record not found for this user")
+			return nil, ErrKYCNotSubmitted
 		}
-		log.Printf("Error retrieving KYC status for user %d: %v", userID, err)
+		log.Printf("Error retrieving KYC status for organization %d: %v", member.OrganizationID, err)
 		return nil, fmt.Errorf("could not retrieve KYC status: %w", err)
 	}
 	return kycDetail, nil
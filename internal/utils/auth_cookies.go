@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"invoiceB2B/internal/config"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFTokenCookie    = "csrf_token"
+	CSRFTokenHeader    = "X-CSRF-Token"
+
+	// RefreshTokenCookiePath keeps the refresh token off every request except the auth endpoints.
+	RefreshTokenCookiePath = "/api/v1/auth"
+)
+
+// SetAuthCookies stores the token pair in HttpOnly cookies along with a fresh CSRF token that the
+// client must echo back in the X-CSRF-Token header on state-changing requests (double-submit).
+func SetAuthCookies(c *fiber.Ctx, cfg *config.Config, accessToken string, accessTokenExpiresAt int64, refreshToken string) error {
+	csrfBytes := make([]byte, 32)
+	if _, err := rand.Read(csrfBytes); err != nil {
+		return fmt.Errorf("failed to generate CSRF token: %w", err)
+	}
+	refreshExpiresAt := time.Now().Add(cfg.JWTRefreshTokenExpirationDays)
+
+	c.Cookie(authCookie(cfg, AccessTokenCookie, accessToken, "/", time.Unix(accessTokenExpiresAt, 0), true))
+	c.Cookie(authCookie(cfg, RefreshTokenCookie, refreshToken, RefreshTokenCookiePath, refreshExpiresAt, true))
+	// Readable by the frontend so it can copy the value into the CSRF header
+	c.Cookie(authCookie(cfg, CSRFTokenCookie, hex.EncodeToString(csrfBytes), "/", refreshExpiresAt, false))
+	return nil
+}
+
+// ClearAuthCookies expires all session cookies, e.g. on logout.
+func ClearAuthCookies(c *fiber.Ctx, cfg *config.Config) {
+	expired := time.Unix(0, 0)
+	c.Cookie(authCookie(cfg, AccessTokenCookie, "", "/", expired, true))
+	c.Cookie(authCookie(cfg, RefreshTokenCookie, "", RefreshTokenCookiePath, expired, true))
+	c.Cookie(authCookie(cfg, CSRFTokenCookie, "", "/", expired, false))
+}
+
+// ValidCSRFToken reports whether a cookie-authenticated request carries a CSRF header matching its
+// CSRF cookie. Safe methods are always allowed.
+func ValidCSRFToken(c *fiber.Ctx) bool {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	}
+	cookieToken := c.Cookies(CSRFTokenCookie)
+	headerToken := c.Get(CSRFTokenHeader)
+	if cookieToken == "" || headerToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) == 1
+}
+
+func authCookie(cfg *config.Config, name, value, path string, expires time.Time, httpOnly bool) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.AuthCookieDomain,
+		Expires:  expires,
+		Secure:   cfg.AuthCookieSecure,
+		HTTPOnly: httpOnly,
+		SameSite: cfg.AuthCookieSameSite,
+	}
+}
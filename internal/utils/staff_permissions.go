@@ -0,0 +1,13 @@
+package utils
+
+import "github.com/gofiber/fiber/v2"
+
+// StaffPermissionsLocal is the context key under which AdminMiddleware stores the permissions
+// of the authenticated staff member's role.
+const StaffPermissionsLocal = "staff_permissions"
+
+// HasStaffPermission reports whether the authenticated staff member holds permission.
+func HasStaffPermission(c *fiber.Ctx, permission string) bool {
+	granted, ok := c.Locals(StaffPermissionsLocal).(map[string]bool)
+	return ok && granted[permission]
+}
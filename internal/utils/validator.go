@@ -2,10 +2,13 @@ package utils
 
 import (
 	"fmt"
+	"invoiceB2B/internal/money"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
 )
 
 type CustomValidator struct {
@@ -13,7 +16,18 @@ type CustomValidator struct {
 }
 
 func NewCustomValidator() *CustomValidator {
-	return &CustomValidator{Validator: validator.New()}
+	v := validator.New()
+	// Validate amounts and rates by value so that numeric tags such as gt=0 apply to them
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		switch value := field.Interface().(type) {
+		case money.Amount:
+			return value.InexactFloat64()
+		case decimal.Decimal:
+			return value.InexactFloat64()
+		}
+		return nil
+	}, money.Amount{}, decimal.Decimal{})
+	return &CustomValidator{Validator: v}
 }
 
 // FormatValidationError formats validation errors into a readable structure.
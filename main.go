@@ -2,12 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"invoiceB2B/internal/dtos"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"invoiceB2B/internal/config"
@@ -26,6 +26,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	flogger "github.com/gofiber/fiber/v2/middleware/logger"
+	"gorm.io/gorm"
 )
 
 type NuxtProjectConfig struct {
@@ -61,13 +62,29 @@ func main() {
 	}
 	log.Info("Database connected successfully.")
 
+	// Accounts that existed before email verification was introduced are treated as verified
+	backfillEmailVerification := !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
 	err = db.AutoMigrate(
 		&models.User{}, &models.Staff{}, &models.KYCDetail{},
 		&models.Invoice{}, &models.Transaction{}, &models.ActivityLog{},
+		&models.TwoFARecoveryCode{}, &models.StaffInvitation{},
+		&models.Organization{}, &models.OrganizationMember{}, &models.OrganizationInvitation{},
+		&models.RolePermission{}, &models.DisbursementApproval{},
+		&models.ApprovalLimit{}, &models.InvoiceEscalation{}, &models.APIKey{}, &models.InvoiceStatusHistory{},
+		&models.PricingPlan{}, &models.DebtorRiskRating{}, &models.FinancingQuote{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+	if err := backfillOrganizations(db); err != nil {
+		log.Fatalf("Failed to backfill organizations for existing users: %v", err)
+	}
+	if backfillEmailVerification {
+		if err := db.Model(&models.User{}).Where("email_verified_at IS NULL").Update("email_verified_at", gorm.Expr("created_at")).Error; err != nil {
+			log.Fatalf("Failed to backfill email verification for existing users: %v", err)
+		}
+	}
 	log.Info("Database migrations completed.")
 
 	rdb := redis.NewClient(&redis.Options{
@@ -92,9 +109,25 @@ func main() {
 		}
 	}
 
-	jwtService := services.NewJWTService(cfg)
+	var keyManager services.KeyManager
+	if cfg.JWTSigningAlgorithm != services.SigningAlgHS256 {
+		// Keep replaced keys published for as long as tokens signed with them can live
+		keyRetention := cfg.JWTRefreshTokenExpirationDays + cfg.JWTAccessTokenExpirationMinutes
+		keyManager, err = services.NewKeyManager(cfg.JWTKeysDir, cfg.JWTSigningAlgorithm, cfg.JWTKeyRotationInterval, keyRetention)
+		if err != nil {
+			log.Fatalf("Failed to initialize JWT signing keys: %v", err)
+		}
+		go keyManager.RunRotation(context.Background(), time.Hour)
+		log.Infof("JWT signing keys loaded from %s (%s).", cfg.JWTKeysDir, cfg.JWTSigningAlgorithm)
+	} else {
+		log.Warn("JWT_SIGNING_ALG is HS256; tokens are signed with the shared JWT_SECRET and no JWKS is published.")
+	}
+	jwtService := services.NewJWTService(cfg, keyManager)
 	emailService := services.NewEmailService(cfg)
 	otpService := services.NewOTPService(rdb, cfg.OTPExpirationMinutes)
+	totpService := services.NewTOTPService(cfg.TOTPIssuer)
+	sessionService := services.NewSessionService(rdb, cfg.JWTRefreshTokenExpirationDays)
+	loginGuardService := services.NewLoginGuardService(rdb, cfg.LoginFailureWindow, cfg.LoginMaxAccountFailures, cfg.LoginMaxIPFailures, cfg.LoginLockoutDuration)
 	fileService := services.NewFileService(cfg.UploadsDir, cfg.MaxUploadSizeMB*1024*1024)
 
 	// Placeholder for PDFService initialization - it will be nil for now
@@ -106,38 +139,66 @@ func main() {
 	kycRepo := repositories.NewKYCRepository(db)
 	invoiceRepo := repositories.NewInvoiceRepository(db)
 	staffRepo := repositories.NewStaffRepository(db)
+	staffInvitationRepo := repositories.NewStaffInvitationRepository(db)
 	activityLogRepo := repositories.NewActivityLogRepository(db)
 	transactionRepo := repositories.NewTransactionRepository(db)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(db)
+	organizationRepo := repositories.NewOrganizationRepository(db)
+	disbursementApprovalRepo := repositories.NewDisbursementApprovalRepository(db)
+	approvalLimitRepo := repositories.NewApprovalLimitRepository(db)
+	invoiceEscalationRepo := repositories.NewInvoiceEscalationRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	pricingRepo := repositories.NewPricingRepository(db)
+	rolePermissionRepo := repositories.NewRolePermissionRepository(db)
 
 	activityLogSvc := services.NewActivityLogService(activityLogRepo)
-	authService := services.NewAuthService(userRepo, staffRepo, kycRepo, jwtService, emailService, otpService, notificationService, activityLogSvc, cfg)
-	userService := services.NewUserService(userRepo, kycRepo, activityLogSvc)
-	invoiceService := services.NewInvoiceService(invoiceRepo, userRepo, transactionRepo, fileService, notificationService, activityLogSvc, emailService, cfg)
+	organizationService := services.NewOrganizationService(organizationRepo, userRepo, kycRepo, sessionService, emailService, activityLogSvc, cfg)
+	authService := services.NewAuthService(userRepo, staffRepo, kycRepo, organizationRepo, recoveryCodeRepo, jwtService, emailService, otpService, totpService, sessionService, loginGuardService, notificationService, activityLogSvc, cfg)
+	userService := services.NewUserService(userRepo, kycRepo, organizationService, activityLogSvc)
+	pricingService := services.NewPricingService(pricingRepo, organizationRepo, activityLogSvc, cfg)
+	paymentService := services.NewPaymentService() // Simulated payment provider
+	invoiceService := services.NewInvoiceService(invoiceRepo, userRepo, transactionRepo, kycRepo, organizationService, pricingService, paymentService, fileService, notificationService, activityLogSvc, emailService, cfg)
 	internalService := services.NewInternalService(invoiceRepo, activityLogSvc)
+	permissionService := services.NewPermissionService(rolePermissionRepo, activityLogSvc)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, activityLogSvc)
+	if err := permissionService.EnsureDefaults(context.Background()); err != nil {
+		log.Fatalf("Failed to seed staff role permissions: %v", err)
+	}
 
 	// Initialize AdminService (pass all dependencies)
 	adminService := services.NewAdminService(
 		userRepo,
 		kycRepo,
+		organizationRepo,
 		staffRepo,
+		staffInvitationRepo,
 		invoiceRepo,
 		transactionRepo,
+		disbursementApprovalRepo,
+		approvalLimitRepo,
+		invoiceEscalationRepo,
 		activityLogSvc,
 		emailService,
 		notificationService, // Pass the initialized notificationService (can be nil)
 		fileService,
 		pdfService, // Pass the (nil) pdfService
-		cfg,        // Pass the config as the last argument
+		loginGuardService,
+		pricingService,
+		otpService,
+		totpService,
+		cfg, // Pass the config as the last argument
 	)
 
 	// --- Create Superadmin User (if not exists) ---
 	createSuperAdminIfNotExists(adminService, cfg)
 
-	authHandler := handlers.NewAuthHandler(authService, customValidator.Validator)
-	userHandler := handlers.NewUserHandler(userService, customValidator.Validator)
+	authHandler := handlers.NewAuthHandler(authService, customValidator.Validator, cfg)
+	userHandler := handlers.NewUserHandler(userService, authService, apiKeyService, customValidator.Validator, cfg)
 	invoiceHandler := handlers.NewInvoiceHandler(invoiceService, fileService, customValidator.Validator)
-	adminHandler := handlers.NewAdminHandler(adminService, fileService, customValidator.Validator)
+	adminHandler := handlers.NewAdminHandler(adminService, authService, permissionService, pricingService, fileService, customValidator.Validator, cfg)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, customValidator.Validator)
 	internalHandler := handlers.NewInternalHandler(internalService, customValidator.Validator)
+	jwksHandler := handlers.NewJWKSHandler(keyManager)
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: utils.GlobalErrorHandler,
@@ -146,12 +207,16 @@ func main() {
 	nuxtProjects := []NuxtProjectConfig{
 		{Name: "Dashboard", URLPath: "/", DistPath: "./client/dist"},
 	}
+	// Registered ahead of the frontend so the static file fallback cannot shadow it
+	routes.SetupWellKnownRoutes(app, jwksHandler)
+
 	setupNuxtFrontendServers(app, nuxtProjects)
 
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:5000,http://localhost:3000,http://localhost:3001",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowOrigins:     "http://localhost:5000,http://localhost:3000,http://localhost:3001",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-CSRF-Token",
+		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
+		AllowCredentials: true, // Needed for the HttpOnly session cookies in cookie mode
 	}))
 	app.Use(flogger.New(flogger.Config{
 		Format:     "[${time}] ${ip} ${status} - ${latency} ${method} ${path} ${error}\n",
@@ -159,13 +224,14 @@ func main() {
 		TimeZone:   "Local",
 	}))
 
-	authMiddleware := middleware.NewAuthMiddleware(jwtService)
-	adminMiddleware := middleware.NewAdminMiddleware(staffRepo)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, otpService, sessionService, apiKeyService)
+	adminMiddleware := middleware.NewAdminMiddleware(staffRepo, permissionService, cfg)
 	internalApiMiddleware := middleware.NewInternalAPIMiddleware(cfg.InternalAPIKey)
 
 	apiV1 := app.Group("/api/v1")
 	routes.SetupAuthRoutes(apiV1, authHandler, authMiddleware)
 	routes.SetupUserRoutes(apiV1, userHandler, authMiddleware)
+	routes.SetupOrganizationRoutes(apiV1, organizationHandler, authMiddleware)
 	routes.SetupInvoiceRoutes(apiV1, invoiceHandler, authMiddleware, adminMiddleware)
 	routes.SetupAdminRoutes(apiV1, adminHandler, authMiddleware, adminMiddleware)
 	routes.SetupInternalRoutes(apiV1, internalHandler, internalApiMiddleware)
@@ -247,6 +313,40 @@ func setupNuxtFrontendServers(app *fiber.App, projects []NuxtProjectConfig) {
 	}
 }
 
+// backfillOrganizations gives every user who predates organisation accounts a single-member
+// organisation they own, and moves their KYC record and invoices onto it. Users that already
+// have a membership are skipped, so this is safe to run on every start.
+func backfillOrganizations(db *gorm.DB) error {
+	var users []models.User
+	err := db.Where("id NOT IN (?)", db.Model(&models.OrganizationMember{}).Select("user_id")).Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			org := &models.Organization{Name: user.CompanyName}
+			if err := tx.Create(org).Error; err != nil {
+				return err
+			}
+			member := &models.OrganizationMember{OrganizationID: org.ID, UserID: user.ID, Role: models.OrgRoleOwner}
+			if err := tx.Create(member).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.KYCDetail{}).Where("user_id = ? AND organization_id IS NULL", user.ID).Update("organization_id", org.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Invoice{}).Where("user_id = ? AND organization_id IS NULL", user.ID).Update("organization_id", org.ID).Error
+		})
+		if err != nil {
+			return fmt.Errorf("user %d: %w", user.ID, err)
+		}
+	}
+	if len(users) > 0 {
+		log.Infof("Created organizations for %d existing users.", len(users))
+	}
+	return nil
+}
+
 // createSuperAdminIfNotExists function
 func createSuperAdminIfNotExists(adminService services.AdminService, cfg *config.Config) {
 	superAdminEmail := os.Getenv("SUPERADMIN_EMAIL")
@@ -277,7 +377,7 @@ func createSuperAdminIfNotExists(adminService services.AdminService, cfg *config
 	log.Infof("Attempting to create/verify superadmin: %s", superAdminEmail)
 	_, err := adminService.CreateStaff(context.Background(), superAdminReq)
 	if err != nil {
-		if strings.Contains(err.Error(), "staff with this email already exists") {
+		if errors.Is(err, services.ErrStaffExists) {
 			log.Infof("Superadmin with email %s already exists. No action taken.", superAdminEmail)
 		} else {
 			// Log more generally as other errors might occur (DB connection, etc.)